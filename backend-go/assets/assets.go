@@ -0,0 +1,10 @@
+// Package assets embeds the files the backend reads at run time, so the
+// binary does not depend on its working directory.
+package assets
+
+import "embed"
+
+// I18n holds the translations, one "i18n/{locale}.json" file per locale.
+//
+//go:embed i18n/*.json
+var I18n embed.FS
@@ -0,0 +1,63 @@
+// Command emailpreview renders an email template with sample data, to check
+// the layout and translations without sending anything. Run it from backend-go:
+//
+//	go run ./cmd/emailpreview -list
+//	go run ./cmd/emailpreview -template password_reset -lang ja -format html > reset.html
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/mail"
+)
+
+func main() {
+	list := flag.Bool("list", false, "list the available templates")
+	name := flag.String("template", "", "template to render")
+	lang := flag.String("lang", "en", "locale of the recipient, such as es or ca")
+	fallback := flag.String("fallback", "en", "locale used for missing translations")
+	format := flag.String("format", "text", "part to print: subject, text, html or mime")
+	flag.Parse()
+
+	templates, err := mail.EmbeddedTemplates()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *list {
+		for _, n := range templates.Names() {
+			fmt.Println(n)
+		}
+		return
+	}
+
+	if err := i18n.LoadMessages(*fallback); err != nil {
+		log.Fatal(err)
+	}
+	msg, err := templates.Render(*name, func(key string) string { return i18n.Lookup(*lang, key) }, mail.SampleData(*name))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch *format {
+	case "subject":
+		fmt.Println(msg.Subject)
+	case "text":
+		fmt.Print(msg.Text)
+	case "html":
+		fmt.Print(msg.HTML)
+	case "mime":
+		msg.From = "Task Manager <noreply@example.com>"
+		msg.To = []string{"preview@example.com"}
+		data, err := msg.Bytes()
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(data)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+}
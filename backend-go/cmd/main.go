@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"task-manager/backend-go/config"
 	"task-manager/backend-go/db"
 	"task-manager/backend-go/internal/auth"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/mail"
+	"task-manager/backend-go/internal/oidc"
+	"task-manager/backend-go/internal/outbox"
+	"task-manager/backend-go/internal/passwordpolicy"
+	"task-manager/backend-go/internal/ratelimit"
+	"task-manager/backend-go/internal/requestid"
 	"task-manager/backend-go/internal/task"
 	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/internal/validate"
 
 	"github.com/rs/cors"
 )
@@ -24,30 +35,198 @@ func main() {
 	fmt.Printf("Conectando a la BBDD en %s:%s como %s\n", cfg.DBHost, cfg.DBPort, cfg.DBUser)
 
 
-	// Connect to MySQL database
+	// Connect to the database of DB_DRIVER and create the missing tables
 	if err := db.ConnectDB(cfg); err != nil {
 		log.Fatalf("%s: %v", i18n.T("error.database.connection"), err)
 	}
 
-	// Load i18n messages (default: Spanish)
+	// Load every locale, Spanish being the default for requests that do not
+	// ask for another one with ?lang= or Accept-Language
 	if err := i18n.LoadMessages("es"); err != nil {
 		log.Fatalf("%s: %v", i18n.T("error.i18n.load"), err)
 	}
 
+	// Load the JWT signing keyring and rotate keys on schedule
+	keyring, err := auth.LoadKeyring(cfg.JWTAlgorithm, cfg.JWTKeysDir, []byte(cfg.JWTSecret))
+	if err != nil {
+		log.Fatalf("%s: %v", i18n.T("error.jwt.keyring_load"), err)
+	}
+	auth.Keys = keyring
+	if cfg.JWTRotationInterval > 0 {
+		// Rotating HS256 keys that are not stored would replace JWT_SECRET with
+		// a key of this process only
+		if cfg.JWTKeysDir == "" && keyring.Active().Algorithm == auth.AlgHS256 {
+			log.Fatalf("%s", i18n.T("error.jwt.rotation_without_keys_dir"))
+		}
+		go keyring.RotateEvery(cfg.JWTRotationInterval, nil)
+	}
+
+	// Refuse tokens of revoked sessions, track session activity, resolve
+	// personal access tokens and translate into the language of the user
+	accounts := user.NewService(db.DB)
+	auth.Revoked = user.NewSessionDenylist(accounts.Sessions)
+	auth.Sessions = accounts
+	auth.AccessTokens = accounts
+	auth.Locales = accounts
+
+	// Delete accounts at the end of their grace period and clean up data exports
+	user.DeletionGracePeriod = cfg.AccountDeletionGrace
+	if cfg.ExportDir != "" {
+		user.ExportDir = cfg.ExportDir
+	}
+	go accounts.PurgeEvery(time.Hour, nil)
+
+	// Deliver emails through SMTP, or to a maildir or the console in development
+	user.MailFrom = cfg.MailFrom
+	user.PublicAPIURL = cfg.PublicAPIURL
+	switch cfg.MailBackend {
+	case "smtp":
+		if cfg.MailFrom == "" {
+			log.Printf("MAIL_FROM is not set, emails will not be sent")
+			break
+		}
+		mailer, err := mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			TLS:      cfg.SMTPTLS,
+		})
+		if err != nil {
+			log.Fatalf("%s: %v", i18n.T("error.config.load"), err)
+		}
+		user.Mailer = mailer
+	case "file":
+		mailer, err := mail.NewFileMailer(cfg.MailDir)
+		if err != nil {
+			log.Fatalf("%s: %v", i18n.T("error.config.load"), err)
+		}
+		user.Mailer = mailer
+	case "console":
+		user.Mailer = &mail.ConsoleMailer{W: os.Stdout}
+	default:
+		log.Fatalf("%s: MAIL_BACKEND=%q", i18n.T("error.config.load"), cfg.MailBackend)
+	}
+	if user.MailFrom == "" {
+		user.MailFrom = "Task Manager <noreply@localhost>"
+	}
+	// Queued emails are delivered in the background, with retries
+	if user.Mailer != nil {
+		worker := outbox.NewWorker(db.DB, user.Mailer)
+		worker.MaxAttempts = cfg.OutboxMaxAttempts
+		go worker.Run(cfg.OutboxInterval, nil)
+	}
+
+	// Queue the task digests users opted into, at their local hour
+	user.AppURL = cfg.AppURL
+	go accounts.SendDigestsEvery(5*time.Minute, nil)
+
+	// Usernames allowed to use the /api/admin/ endpoints
+	user.Admins = cfg.AdminUsers
+
+	// Throttle failed logins, sharing counters through the database when asked to
+	var attempts loginguard.Store = loginguard.NewMemoryStore()
+	if cfg.LoginAttemptStore == "sql" {
+		attempts = loginguard.NewSQLStore(db.DB)
+	}
+	user.LoginGuard = user.NewLoginGuard(attempts, accounts)
+	user.LoginGuard.Account.MaxFailures = cfg.LoginMaxFailures
+	user.LoginGuard.Account.LockDuration = cfg.LoginLockoutDuration
+	user.LoginGuard.IP.LockDuration = cfg.LoginLockoutDuration
+	user.LoginGuard.MFA.LockDuration = cfg.LoginLockoutDuration
+
+	// Decide what accounts with an unverified email may do
+	switch cfg.EmailVerificationPolicy {
+	case user.VerificationOff, user.VerificationRestrict, user.VerificationBlockLogin:
+		user.EmailVerificationPolicy = cfg.EmailVerificationPolicy
+	default:
+		log.Fatalf("%s: EMAIL_VERIFICATION_POLICY=%q", i18n.T("error.config.load"), cfg.EmailVerificationPolicy)
+	}
+
+	// Rules for new passwords, optionally rejecting known breached passwords
+	user.PasswordPolicy = passwordpolicy.Policy{
+		MinLength:          cfg.PasswordMinLength,
+		MaxLength:          cfg.PasswordMaxLength,
+		RequireUpper:       cfg.PasswordRequireUpper,
+		RequireLower:       cfg.PasswordRequireLower,
+		RequireDigit:       cfg.PasswordRequireDigit,
+		RequireSpecial:     cfg.PasswordRequireSpecial,
+		ForbidPersonalInfo: cfg.PasswordForbidPersonal,
+	}
+	if cfg.PasswordBreachedList != "" {
+		breached, err := passwordpolicy.LoadBreachedList(cfg.PasswordBreachedList)
+		if err != nil {
+			log.Fatalf("%s: %v", i18n.T("error.password.breached_list_load"), err)
+		}
+		user.PasswordPolicy.Breached = breached
+	}
+
+	// Discover the external OpenID Connect provider when configured
+	if cfg.OIDCIssuer != "" {
+		provider, err := oidc.Discover(context.Background(), oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+		}, nil)
+		if err != nil {
+			log.Fatalf("%s: %v", i18n.T("error.oidc.discovery"), err)
+		}
+		user.OIDCProvider = provider
+		user.OIDCPostLoginURL = cfg.OIDCPostLoginURL
+	}
+
+	// Handlers constructed with their repositories
+	users := user.NewHandler(accounts)
+	tasks := task.NewHandler(task.NewSQLTaskRepository(db.DB), accounts)
+
 	mux := http.NewServeMux()
 
 	// Public endpoints
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(i18n.T("response.api.home")))
+		w.Write([]byte(i18n.Translate(r.Context(), "response.api.home")))
 	})
-	mux.HandleFunc("/register", user.RegisterHandler)
-	mux.HandleFunc("/login", user.LoginHandler)
-	mux.HandleFunc("/forgot-password", user.ForgotPasswordHandler)
-	mux.HandleFunc("/reset-password", user.ResetPasswordHandler)
+	mux.HandleFunc("/register", users.Register)
+	mux.HandleFunc("/login", users.Login)
+	mux.HandleFunc("/forgot-password", users.ForgotPassword)
+	mux.HandleFunc("/reset-password", users.ResetPassword)
+	mux.HandleFunc("/unlock-account", users.UnlockAccount)
+	mux.HandleFunc("/verify-email", users.VerifyEmail)
+	mux.HandleFunc("/verify-email/resend", users.ResendVerification)
+	mux.HandleFunc("/confirm-email-change", users.ConfirmEmailChange)
+	mux.HandleFunc("/export/download", users.ExportDownload)
+	mux.HandleFunc("/auth/logout", auth.AuthMiddleware(users.Logout))
+	mux.HandleFunc("/auth/mfa/verify", users.MFAVerify)
+	mux.HandleFunc("/auth/magic-link", users.MagicLink)
+	mux.HandleFunc("/auth/magic-link/verify", users.MagicLinkVerify)
+	mux.HandleFunc("/auth/oidc/login", users.OIDCLogin)
+	mux.HandleFunc("/auth/oidc/callback", users.OIDCCallback)
 
 	// Protected task and user routes
-	mux.HandleFunc("/api/tasks/", auth.AuthMiddleware(task.TasksRouter))
-	mux.HandleFunc("/api/user/", auth.AuthMiddleware(user.UserRouter))
+	// Personal access tokens are accepted on task routes and for reading the profile
+	// Accounts with an unverified email may be limited to reading
+	mux.HandleFunc("/api/tasks/", auth.ScopedAuthMiddleware(auth.ScopeTasksRead, auth.ScopeTasksWrite, users.RequireVerifiedEmail(tasks.Router)))
+	mux.HandleFunc("/api/user/", auth.ScopedAuthMiddleware(auth.ScopeUserRead, "", users.Router))
+	mux.HandleFunc("/api/user/sessions", auth.AuthMiddleware(users.Sessions))
+	mux.HandleFunc("/api/user/sessions/", auth.AuthMiddleware(users.Sessions))
+	mux.HandleFunc("/api/user/mfa", auth.AuthMiddleware(users.MFA))
+	mux.HandleFunc("/api/user/mfa/", auth.AuthMiddleware(users.MFA))
+	mux.HandleFunc("/api/user/password", auth.AuthMiddleware(users.ChangePassword))
+	mux.HandleFunc("/api/user/email", auth.AuthMiddleware(users.ChangeEmail))
+	mux.HandleFunc("/api/user/magic-link", auth.AuthMiddleware(users.MagicLinkSettings))
+	mux.HandleFunc("/api/user/digest", auth.AuthMiddleware(users.DigestSettings))
+	mux.HandleFunc("/api/user/preferences", auth.AuthMiddleware(users.Preferences))
+	mux.HandleFunc("/api/user/export", auth.AuthMiddleware(users.Export))
+	mux.HandleFunc("/api/user/export/", auth.AuthMiddleware(users.Export))
+	mux.HandleFunc("/api/user/tokens", auth.AuthMiddleware(users.RequireVerifiedEmail(users.AccessTokens)))
+	mux.HandleFunc("/api/user/tokens/", auth.AuthMiddleware(users.RequireVerifiedEmail(users.AccessTokens)))
+
+	// Admin routes, limited to the users listed in ADMIN_USERS
+	mux.HandleFunc("/api/admin/outbox", auth.AuthMiddleware(users.RequireAdmin(users.Outbox)))
+	mux.HandleFunc("/api/admin/outbox/", auth.AuthMiddleware(users.RequireAdmin(users.Outbox)))
+
+	// Public verification keys for other services
+	mux.HandleFunc("/.well-known/jwks.json", auth.JWKSHandler)
 
 	//safeCheck for docker connection
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -56,13 +235,46 @@ func main() {
 	})
 
 
+	// Larger JSON bodies are rejected before they are decoded
+	validate.MaxBodyBytes = int64(cfg.MaxBodyBytes)
+
+	// Client IPs come from X-Forwarded-For only behind a trusted proxy
+	auth.TrustedProxies, err = auth.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatalf("%s: %v", i18n.T("error.config.load"), err)
+	}
+
+	// Rate limit public auth endpoints, the task API and the account API, per user or per IP
+	authLimit, err := ratelimit.ParseLimit(cfg.RateLimitAuth)
+	if err != nil {
+		log.Fatalf("%s: %v", i18n.T("error.config.load"), err)
+	}
+	tasksLimit, err := ratelimit.ParseLimit(cfg.RateLimitTasks)
+	if err != nil {
+		log.Fatalf("%s: %v", i18n.T("error.config.load"), err)
+	}
+	userLimit, err := ratelimit.ParseLimit(cfg.RateLimitUser)
+	if err != nil {
+		log.Fatalf("%s: %v", i18n.T("error.config.load"), err)
+	}
+	limiter := ratelimit.New(auth.ClientKey,
+		ratelimit.Group{
+			Name:     "auth",
+			Prefixes: []string{"/login", "/register", "/forgot-password", "/reset-password", "/unlock-account", "/verify-email", "/confirm-email-change", "/auth/"},
+			Limit:    authLimit,
+		},
+		ratelimit.Group{Name: "tasks", Prefixes: []string{"/api/tasks/"}, Limit: tasksLimit},
+		ratelimit.Group{Name: "user", Prefixes: []string{"/api/user/"}, Limit: userLimit},
+	)
+
 	// Apply CORS policy
 	handler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173"},
 		AllowedMethods:   []string{"GET", "POST", "OPTIONS", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept-Language", requestid.Header},
+		ExposedHeaders:   []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", requestid.Header},
 		AllowCredentials: true,
-	}).Handler(mux)
+	}).Handler(requestid.Middleware(i18n.Middleware(limiter.Middleware(mux))))
 
 	log.Printf("%s http://localhost%s", i18n.T("server.start"), cfg.Port)
 	//log.Fatal(http.ListenAndServe(cfg.Port, handler)) used for localhost with apache
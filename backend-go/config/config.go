@@ -3,12 +3,20 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds application environment variables
 type Config struct {
+	// DBDriver is the database: "mysql" (default), "postgres" or "sqlite".
+	DBDriver string
+	// DBDSN overrides the DSN built from the DB_* variables; for SQLite,
+	// DBName is the database file.
+	DBDSN      string
 	DBUser     string
 	DBPassword string
 	DBHost     string
@@ -16,6 +24,86 @@ type Config struct {
 	DBName     string
 	Port       string
 	JWTSecret  string
+
+	// JWTAlgorithm selects how new tokens are signed: HS256, RS256 or EdDSA.
+	JWTAlgorithm string
+	// JWTKeysDir stores the signing keys so they survive restarts and can be shared between replicas.
+	JWTKeysDir string
+	// JWTRotationInterval is how often a new signing key is generated (0 disables rotation).
+	JWTRotationInterval time.Duration
+
+	// OpenID Connect login; disabled when OIDCIssuer is empty.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	// OIDCPostLoginURL is the frontend page receiving the token after an OIDC login.
+	OIDCPostLoginURL string
+
+	// LoginMaxFailures is the number of failed logins that locks an account.
+	LoginMaxFailures int
+	// LoginLockoutDuration is how long a locked account or IP must wait.
+	LoginLockoutDuration time.Duration
+	// LoginAttemptStore keeps failed login counters in "memory" or in the "sql" database.
+	LoginAttemptStore string
+
+	// RateLimitAuth, RateLimitTasks and RateLimitUser limit public auth endpoints,
+	// /api/tasks/ and /api/user/, written as "<requests>/<window>" such as
+	// "20/1m"; "off" disables them.
+	RateLimitAuth  string
+	RateLimitTasks string
+	RateLimitUser  string
+
+	// TrustedProxies lists the reverse proxies, as IPs or CIDR ranges, whose
+	// X-Forwarded-For header gives the client IP.
+	TrustedProxies []string
+
+	// MaxBodyBytes bounds the size of JSON request bodies.
+	MaxBodyBytes int
+
+	// EmailVerificationPolicy applies to unverified accounts: "restrict" (default), "block" or "off".
+	EmailVerificationPolicy string
+
+	// Password policy for new passwords.
+	PasswordMinLength      int
+	PasswordMaxLength      int
+	PasswordRequireUpper   bool
+	PasswordRequireLower   bool
+	PasswordRequireDigit   bool
+	PasswordRequireSpecial bool
+	// PasswordForbidPersonal rejects passwords containing the username or email.
+	PasswordForbidPersonal bool
+	// PasswordBreachedList is a file or directory of breached password hashes (optional).
+	PasswordBreachedList string
+
+	// AccountDeletionGrace is how long a deleted account can be recovered by logging in.
+	AccountDeletionGrace time.Duration
+	// ExportDir stores the generated data export archives.
+	ExportDir string
+
+	// MailBackend delivers emails through "smtp" (default), a "file" maildir or the "console".
+	MailBackend string
+	// MailFrom is the sender of outgoing emails.
+	MailFrom string
+	// MailDir is the maildir written by the file backend.
+	MailDir string
+	// SMTP server; SMTPTLS is "starttls" (default), "tls" or "none".
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPTLS      string
+	// PublicAPIURL is the base URL used in email links.
+	PublicAPIURL string
+	// AppURL is the base URL of the frontend, linked from digest emails.
+	AppURL string
+	// OutboxInterval is how often queued emails are delivered.
+	OutboxInterval time.Duration
+	// OutboxMaxAttempts is the number of failed deliveries before an email is dead.
+	OutboxMaxAttempts int
+
+	// AdminUsers lists the usernames allowed to use the /api/admin/ endpoints.
+	AdminUsers []string
 }
 /* NEED TO BE OPTIMIZED
 func Load() (*Config, error) {
@@ -48,6 +136,8 @@ func Load() (*Config, error) {
 	}
 
 	return &Config{
+		DBDriver:   os.Getenv("DB_DRIVER"),
+		DBDSN:      os.Getenv("DB_DSN"),
 		DBUser:     os.Getenv("DB_USER"),
 		DBPassword: os.Getenv("DB_PASSWORD"),
 		DBHost:     os.Getenv("DB_HOST"),
@@ -55,10 +145,115 @@ func Load() (*Config, error) {
 		DBName:     os.Getenv("DB_NAME"),
 		JWTSecret:  os.Getenv("JWT_SECRET"),
 		Port:       getPort(),
+
+		JWTAlgorithm:        os.Getenv("JWT_ALGORITHM"),
+		JWTKeysDir:          os.Getenv("JWT_KEYS_DIR"),
+		JWTRotationInterval: getDuration("JWT_ROTATION_INTERVAL", 0),
+
+		OIDCIssuer:       os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCPostLoginURL: os.Getenv("OIDC_POST_LOGIN_URL"),
+
+		LoginMaxFailures:     getInt("LOGIN_MAX_FAILURES", 5),
+		LoginLockoutDuration: getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginAttemptStore:    os.Getenv("LOGIN_ATTEMPT_STORE"),
+
+		RateLimitAuth:  getEnv("RATE_LIMIT_AUTH", "20/1m"),
+		RateLimitTasks: getEnv("RATE_LIMIT_TASKS", "300/1m"),
+		RateLimitUser:  getEnv("RATE_LIMIT_USER", "60/1m"),
+		MaxBodyBytes:   getInt("MAX_BODY_BYTES", 1<<20),
+		TrustedProxies: getList("TRUSTED_PROXIES"),
+
+		EmailVerificationPolicy: getEnv("EMAIL_VERIFICATION_POLICY", "restrict"),
+
+		PasswordMinLength:      getInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:      getInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordRequireUpper:   getBool("PASSWORD_REQUIRE_UPPER", true),
+		PasswordRequireLower:   getBool("PASSWORD_REQUIRE_LOWER", true),
+		PasswordRequireDigit:   getBool("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSpecial: getBool("PASSWORD_REQUIRE_SPECIAL", true),
+		PasswordForbidPersonal: getBool("PASSWORD_FORBID_PERSONAL", true),
+		PasswordBreachedList:   os.Getenv("PASSWORD_BREACHED_LIST"),
+
+		AccountDeletionGrace: getDuration("ACCOUNT_DELETION_GRACE", 14*24*time.Hour),
+		ExportDir:            os.Getenv("EXPORT_DIR"),
+
+		// GOOGLE_EMAIL and GOOGLE_PWD are the former Gmail-only settings
+		MailBackend:  getEnv("MAIL_BACKEND", "smtp"),
+		MailFrom:     getEnv("MAIL_FROM", os.Getenv("GOOGLE_EMAIL")),
+		MailDir:      getEnv("MAIL_DIR", "mail"),
+		SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:     getInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", os.Getenv("GOOGLE_EMAIL")),
+		SMTPPassword: getEnv("SMTP_PASSWORD", os.Getenv("GOOGLE_PWD")),
+		SMTPTLS:      getEnv("SMTP_TLS", "starttls"),
+		PublicAPIURL: publicAPIURL(),
+		AppURL:       getEnv("APP_URL", "http://localhost:5173"),
+
+		OutboxInterval:    getDuration("OUTBOX_INTERVAL", 5*time.Second),
+		OutboxMaxAttempts: getInt("OUTBOX_MAX_ATTEMPTS", 8),
+
+		AdminUsers: getList("ADMIN_USERS"),
 	}, nil
 
-	
 }
+
+// getEnv returns the environment variable, or def when unset.
+func getEnv(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
+
+// getList splits a comma-separated environment variable, skipping empty items.
+func getList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getDuration parses a duration such as "720h" from the environment, returning def when unset or invalid.
+func getDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// getInt parses an integer from the environment, returning def when unset or invalid.
+func getInt(key string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// getBool parses a boolean such as "true" or "0" from the environment, returning def when unset or invalid.
+func getBool(key string, def bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// publicAPIURL joins PUBLIC_API_URL and the optional PUBLIC_API_PORT.
+func publicAPIURL() string {
+	if port := os.Getenv("PUBLIC_API_PORT"); port != "" {
+		return os.Getenv("PUBLIC_API_URL") + ":" + port
+	}
+	return os.Getenv("PUBLIC_API_URL")
+}
+
 // getPort returns the server port, defaulting to :8080 if not set
 func getPort() string {
 	port := os.Getenv("PORT")
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"task-manager/backend-go/config"
+	"task-manager/backend-go/internal/i18n"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+var DB *sql.DB
+
+// ConnectDB opens the database of DB_DRIVER using environment variables and
+// creates the tables that are missing
+func ConnectDB(cfg *config.Config) error {
+	dialect, err := ParseDialect(cfg.DBDriver)
+	if err != nil {
+		return err
+	}
+
+	dsn := cfg.DBDSN
+	if dsn == "" {
+		dsn = defaultDSN(dialect, cfg)
+	}
+
+	DB, err = Open(dialect, dsn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("db.open"), err)
+
+	}
+
+	if err = DB.Ping(); err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("db.ping"), err)
+	}
+
+	if err = CreateSchema(context.Background(), DB); err != nil {
+		return fmt.Errorf("%s: %w", i18n.T("db.schema"), err)
+	}
+
+	return nil
+}
+
+// defaultDSN builds the DSN of the dialect from DB_HOST, DB_PORT, DB_USER,
+// DB_PASSWORD and DB_NAME, which is the database file for SQLite.
+func defaultDSN(dialect Dialect, cfg *config.Config) string {
+	switch dialect {
+	case PostgreSQL:
+		port := cfg.DBPort
+		if port == "" {
+			port = "5432"
+		}
+		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s",
+			quoteValue(cfg.DBHost), quoteValue(port), quoteValue(cfg.DBUser), quoteValue(cfg.DBPassword), quoteValue(cfg.DBName),
+		)
+	case SQLite:
+		return cfg.DBName
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+			cfg.DBUser,
+			cfg.DBPassword,
+			cfg.DBHost,
+			cfg.DBPort,
+			cfg.DBName,
+		)
+	}
+}
+
+// quoteValue quotes a value of a PostgreSQL key=value connection string.
+func quoteValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Open connects to a database of the dialect without checking that it is
+// reachable. dsn is a MySQL DSN, a PostgreSQL URL or key=value string, or the
+// path of a SQLite file (":memory:" for a private in-memory database).
+func Open(dialect Dialect, dsn string) (*sql.DB, error) {
+	switch dialect {
+	case MySQL:
+		// Dates are scanned into time.Time everywhere
+		mysqlConfig, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		mysqlConfig.ParseTime = true
+		// UPDATEs report the rows they match, like the other dialects, so that
+		// an update leaving a row as it was is not taken for a missing row
+		mysqlConfig.ClientFoundRows = true
+		return sql.Open("mysql", mysqlConfig.FormatDSN())
+	case PostgreSQL:
+		pgConfig, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(postgresConnector{stdlib.GetConnector(*pgConfig)}), nil
+	case SQLite:
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		conn, err := sql.Open("sqlite", dsn+separator+
+			"_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite")
+		if err != nil {
+			return nil, err
+		}
+		// SQLite has a single writer; one connection also keeps a ":memory:"
+		// database alive, since every connection to it is a separate database
+		conn.SetMaxOpenConns(1)
+		return conn, nil
+	}
+	return nil, fmt.Errorf("%s: %q", i18n.T("db.driver"), dialect)
+}
@@ -0,0 +1,64 @@
+// Package dbtest opens the databases of tests, so the same suites run on every
+// dialect:
+//
+//	go test ./...
+//	TEST_DB_DRIVER=postgres TEST_DB_DSN=postgres://localhost/task_manager_test go test -p 1 ./...
+//	TEST_DB_DRIVER=mysql TEST_DB_DSN='root:secret@tcp(localhost:3306)/task_manager_test' go test -p 1 ./...
+//
+// The tables of a PostgreSQL or MySQL test database are dropped and created
+// again by every test, so packages must not run in parallel (-p 1).
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"regexp"
+	"slices"
+	"testing"
+
+	"task-manager/backend-go/db"
+)
+
+// tableName finds the table of a CREATE TABLE statement.
+var tableName = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+)`)
+
+// Open returns a database with the empty schema of the application, closed
+// when the test ends: a private in-memory SQLite database, or the database
+// named by TEST_DB_DRIVER and TEST_DB_DSN when they are set.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	dialect, dsn := db.SQLite, ":memory:"
+	if driver := os.Getenv("TEST_DB_DRIVER"); driver != "" && os.Getenv("TEST_DB_DSN") != "" {
+		var err error
+		if dialect, err = db.ParseDialect(driver); err != nil {
+			t.Fatal(err)
+		}
+		dsn = os.Getenv("TEST_DB_DSN")
+	}
+
+	conn, err := db.Open(dialect, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	ctx := context.Background()
+	if dialect != db.SQLite {
+		// Referencing tables come after the tables they reference
+		statements := db.Schema(dialect)
+		slices.Reverse(statements)
+		for _, statement := range statements {
+			if match := tableName.FindStringSubmatch(statement); match != nil {
+				if _, err := conn.ExecContext(ctx, "DROP TABLE IF EXISTS "+match[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if err := db.CreateSchema(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"task-manager/backend-go/internal/i18n"
+
+	"modernc.org/sqlite"
+)
+
+// Dialect is the SQL flavour of a database. Queries are written once with ?
+// placeholders in the SQL shared by MySQL, PostgreSQL and SQLite; the dialect
+// covers what differs between them.
+type Dialect string
+
+const (
+	MySQL      Dialect = "mysql"
+	PostgreSQL Dialect = "postgres"
+	SQLite     Dialect = "sqlite"
+)
+
+// ParseDialect returns the dialect of a DB_DRIVER value, MySQL when empty.
+func ParseDialect(name string) (Dialect, error) {
+	switch dialect := Dialect(strings.ToLower(name)); dialect {
+	case "":
+		return MySQL, nil
+	case MySQL, PostgreSQL, SQLite:
+		return dialect, nil
+	}
+	return "", fmt.Errorf("%s: %q", i18n.T("db.driver"), name)
+}
+
+// DialectOf returns the dialect of an open database from its driver, so that
+// databases opened without Open, such as in tests, are recognised too.
+func DialectOf(conn *sql.DB) Dialect {
+	switch conn.Driver().(type) {
+	case postgresDriver:
+		return PostgreSQL
+	case *sqlite.Driver:
+		return SQLite
+	default:
+		return MySQL
+	}
+}
+
+// Queryer runs queries on a *sql.DB or inside a *sql.Tx.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Insert runs an INSERT into a table with an id column and returns the id of
+// the new row. PostgreSQL has no LastInsertId, so it is read with RETURNING.
+func (d Dialect) Insert(ctx context.Context, q Queryer, query string, args ...any) (int64, error) {
+	if d == PostgreSQL {
+		var id int64
+		err := q.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Upsert returns an INSERT of columns into table that, when a row with the
+// same key columns exists, sets its update columns to the inserted values
+// instead. The key must be the primary key or a unique index.
+func (d Dialect) Upsert(table string, key, columns, update []string) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + placeholders + ")"
+
+	set := make([]string, len(update))
+	for i, column := range update {
+		if d == MySQL {
+			set[i] = column + " = VALUES(" + column + ")"
+		} else {
+			set[i] = column + " = excluded." + column
+		}
+	}
+
+	if d == MySQL {
+		if len(set) == 0 {
+			// Assigning the key to itself leaves the row as it is
+			set = []string{key[0] + " = " + key[0]}
+		}
+		return query + " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	}
+	if len(set) == 0 {
+		return query + " ON CONFLICT (" + strings.Join(key, ", ") + ") DO NOTHING"
+	}
+	return query + " ON CONFLICT (" + strings.Join(key, ", ") + ") DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// Rebind rewrites the ? placeholders of query into the syntax of the dialect,
+// $1, $2... on PostgreSQL. Question marks in quoted strings, quoted
+// identifiers and comments are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != PostgreSQL || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// postgresConnector opens pgx connections that accept the ? placeholders
+// used by every query of the application.
+type postgresConnector struct {
+	driver.Connector
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return postgresConn{conn.(*stdlib.Conn)}, nil
+}
+
+func (c postgresConnector) Driver() driver.Driver {
+	return postgresDriver{}
+}
+
+// postgresDriver is the driver of databases opened with postgresConnector,
+// which DialectOf recognises.
+type postgresDriver struct{}
+
+func (postgresDriver) Open(name string) (driver.Conn, error) {
+	conn, err := stdlib.GetDefaultDriver().Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return postgresConn{conn.(*stdlib.Conn)}, nil
+}
+
+// postgresConn rebinds the placeholders of queries before pgx runs them.
+type postgresConn struct {
+	*stdlib.Conn
+}
+
+func (c postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(PostgreSQL.Rebind(query))
+}
+
+func (c postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.PrepareContext(ctx, PostgreSQL.Rebind(query))
+}
+
+func (c postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.ExecContext(ctx, PostgreSQL.Rebind(query), args)
+}
+
+func (c postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.QueryContext(ctx, PostgreSQL.Rebind(query), args)
+}
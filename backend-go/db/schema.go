@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"strings"
+)
+
+// schemas holds the tables of each dialect. Enums are ENUM columns on MySQL
+// and CHECK constraints elsewhere.
+//
+//go:embed schema/*.sql
+var schemas embed.FS
+
+// Schema returns the CREATE statements of the dialect, in order.
+func Schema(dialect Dialect) []string {
+	data, err := schemas.ReadFile("schema/" + string(dialect) + ".sql")
+	if err != nil {
+		panic(err)
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(string(data), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// addedColumns are the columns added to tables after their first release, in
+// order. CREATE TABLE IF NOT EXISTS leaves older tables as they are, so
+// CreateSchema adds them with the definition of the schema file, then runs
+// backfill, if any, to fill them in for the existing rows.
+var addedColumns = []struct{ table, column, backfill string }{
+	{"users", "totp_secret", ""},
+	{"users", "totp_enabled_at", ""},
+	{"users", "totp_last_counter", ""},
+	// Accounts created before email verification existed keep working under
+	// every EMAIL_VERIFICATION policy
+	{"users", "email_verified_at", "UPDATE users SET email_verified_at = COALESCE(created_at, CURRENT_TIMESTAMP)"},
+	{"users", "verification_sent_at", ""},
+	{"users", "deletion_scheduled_at", ""},
+	{"users", "magic_link_enabled", ""},
+	{"tasks", "due_date", ""},
+	{"tasks", "completed_at", ""},
+}
+
+// droppedColumns are the columns that older versions created and the current
+// one no longer uses. CreateSchema drops them from existing tables.
+var droppedColumns = []struct{ table, column string }{
+	// Reset tokens were stored in plaintext on the user; password_resets
+	// keeps their hashes instead, so the old ones must not survive
+	{"users", "password_reset_token"},
+	{"users", "password_reset_expiration"},
+}
+
+// CreateSchema creates the tables and indexes missing from the database, adds
+// the columns missing from tables created by older versions and drops the
+// columns they no longer use.
+func CreateSchema(ctx context.Context, conn *sql.DB) error {
+	dialect := DialectOf(conn)
+	statements := Schema(dialect)
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	for _, added := range addedColumns {
+		exists, err := hasColumn(ctx, conn, dialect, added.table, added.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		definition, err := columnDefinition(statements, added.table, added.column)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, "ALTER TABLE "+added.table+" ADD COLUMN "+definition); err != nil {
+			return err
+		}
+		if added.backfill != "" {
+			if _, err := conn.ExecContext(ctx, added.backfill); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, dropped := range droppedColumns {
+		exists, err := hasColumn(ctx, conn, dialect, dropped.table, dropped.column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, "ALTER TABLE "+dropped.table+" DROP COLUMN "+dropped.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasColumn reports whether a table of the database has a column.
+func hasColumn(ctx context.Context, conn *sql.DB, dialect Dialect, table, column string) (bool, error) {
+	var query string
+	switch dialect {
+	case PostgreSQL:
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?"
+	case SQLite:
+		query = "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?"
+	default:
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?"
+	}
+
+	var count int
+	err := conn.QueryRowContext(ctx, query, table, column).Scan(&count)
+	return count > 0, err
+}
+
+// columnDefinition returns the line defining a column in the CREATE TABLE
+// statement of a table, such as "due_date DATE".
+func columnDefinition(statements []string, table, column string) (string, error) {
+	for _, statement := range statements {
+		if !strings.HasPrefix(statement, "CREATE TABLE IF NOT EXISTS "+table+" (") {
+			continue
+		}
+		for _, line := range strings.Split(statement, "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, column+" ") {
+				return strings.TrimSuffix(line, ","), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("column %s.%s is not in the schema", table, column)
+}
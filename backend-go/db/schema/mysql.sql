@@ -0,0 +1,179 @@
+CREATE TABLE IF NOT EXISTS users (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  username VARCHAR(50) NOT NULL,
+  password VARCHAR(60),
+  name VARCHAR(20) NOT NULL,
+  surname VARCHAR(50) NOT NULL,
+  email VARCHAR(25) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  totp_secret VARCHAR(64),
+  totp_enabled_at DATETIME,
+  totp_last_counter BIGINT,
+  email_verified_at DATETIME,
+  verification_sent_at DATETIME,
+  deletion_scheduled_at DATETIME,
+  magic_link_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  INDEX idx_username (username),
+  INDEX idx_email (email)
+);
+
+CREATE TABLE IF NOT EXISTS tasks (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  description TEXT,
+  status ENUM('pending', 'completed') DEFAULT 'pending',
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  due_date DATE,
+  completed_at DATETIME,
+  INDEX idx_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id VARCHAR(64) PRIMARY KEY,
+  user_id INT NOT NULL,
+  user_agent VARCHAR(255),
+  ip VARCHAR(45),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  last_seen_at DATETIME,
+  expires_at DATETIME NOT NULL,
+  revoked_at DATETIME,
+  INDEX idx_sessions_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  code_hash CHAR(64) NOT NULL,
+  used_at DATETIME,
+  INDEX idx_mfa_recovery_user (user_id, code_hash),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  scopes VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expires_at DATETIME NOT NULL,
+  last_used_at DATETIME,
+  revoked_at DATETIME,
+  UNIQUE INDEX idx_pat_token_hash (token_hash),
+  INDEX idx_pat_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  provider VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE INDEX idx_identity_provider_subject (provider, subject),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+  attempt_key VARCHAR(191) PRIMARY KEY,
+  failures INT NOT NULL DEFAULT 0,
+  last_failure DATETIME NOT NULL,
+  locked_until DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  kind VARCHAR(16) NOT NULL,
+  identifier VARCHAR(191) NOT NULL,
+  user_id INT,
+  ip VARCHAR(45),
+  failures INT NOT NULL,
+  locked_until DATETIME NOT NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  unlock_token_hash CHAR(64),
+  unlocked_at DATETIME,
+  INDEX idx_lockouts_identifier (kind, identifier),
+  UNIQUE INDEX idx_lockouts_unlock_token (unlock_token_hash),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  file_path VARCHAR(255),
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expires_at DATETIME NOT NULL,
+  downloaded_at DATETIME,
+  UNIQUE INDEX idx_exports_token_hash (token_hash),
+  INDEX idx_exports_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS magic_links (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  ip VARCHAR(45),
+  created_at DATETIME NOT NULL,
+  expires_at DATETIME NOT NULL,
+  used_at DATETIME,
+  UNIQUE INDEX idx_magic_links_token_hash (token_hash),
+  INDEX idx_magic_links_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+  id INT PRIMARY KEY AUTO_INCREMENT,
+  user_id INT NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  ip VARCHAR(45),
+  created_at DATETIME NOT NULL,
+  expires_at DATETIME NOT NULL,
+  used_at DATETIME,
+  UNIQUE INDEX idx_password_resets_token_hash (token_hash),
+  INDEX idx_password_resets_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  idempotency_key VARCHAR(128) NOT NULL,
+  sender VARCHAR(255) NOT NULL,
+  recipients TEXT NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  text_body MEDIUMTEXT NOT NULL,
+  html_body MEDIUMTEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME NOT NULL,
+  last_error TEXT,
+  created_at DATETIME NOT NULL,
+  sent_at DATETIME,
+  UNIQUE INDEX idx_outbox_idempotency_key (idempotency_key),
+  INDEX idx_outbox_status_next (status, next_attempt_at)
+);
+
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+  user_id INT PRIMARY KEY,
+  frequency ENUM('off', 'daily', 'weekly') NOT NULL DEFAULT 'off',
+  send_hour TINYINT NOT NULL DEFAULT 8,
+  time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  last_sent_on CHAR(10),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+  user_id INT PRIMARY KEY,
+  locale VARCHAR(16) NOT NULL DEFAULT '',
+  time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  week_start ENUM('monday', 'sunday') NOT NULL DEFAULT 'monday',
+  date_format VARCHAR(16) NOT NULL DEFAULT 'YYYY-MM-DD',
+  task_sort VARCHAR(16) NOT NULL DEFAULT 'created_asc',
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,168 @@
+CREATE TABLE IF NOT EXISTS users (
+  id SERIAL PRIMARY KEY,
+  username VARCHAR(50) NOT NULL,
+  password VARCHAR(60),
+  name VARCHAR(20) NOT NULL,
+  surname VARCHAR(50) NOT NULL,
+  email VARCHAR(25) NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  totp_secret VARCHAR(64),
+  totp_enabled_at TIMESTAMPTZ,
+  totp_last_counter BIGINT,
+  email_verified_at TIMESTAMPTZ,
+  verification_sent_at TIMESTAMPTZ,
+  deletion_scheduled_at TIMESTAMPTZ,
+  magic_link_enabled BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS idx_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS tasks (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  title VARCHAR(255) NOT NULL,
+  description TEXT,
+  status VARCHAR(9) DEFAULT 'pending' CHECK (status IN ('pending', 'completed')),
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  due_date DATE,
+  completed_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_user_id ON tasks (user_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id VARCHAR(64) PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_agent VARCHAR(255),
+  ip VARCHAR(45),
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  last_seen_at TIMESTAMPTZ,
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash CHAR(64) NOT NULL,
+  used_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_user ON mfa_recovery_codes (user_id, code_hash);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  scopes VARCHAR(255) NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMPTZ NOT NULL,
+  last_used_at TIMESTAMPTZ,
+  revoked_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pat_token_hash ON personal_access_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_pat_user_id ON personal_access_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255),
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_provider_subject ON user_identities (provider, subject);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+  attempt_key VARCHAR(191) PRIMARY KEY,
+  failures INT NOT NULL DEFAULT 0,
+  last_failure TIMESTAMPTZ NOT NULL,
+  locked_until TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+  id SERIAL PRIMARY KEY,
+  kind VARCHAR(16) NOT NULL,
+  identifier VARCHAR(191) NOT NULL,
+  user_id INT REFERENCES users(id) ON DELETE SET NULL,
+  ip VARCHAR(45),
+  failures INT NOT NULL,
+  locked_until TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  unlock_token_hash CHAR(64),
+  unlocked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_lockouts_identifier ON login_lockouts (kind, identifier);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lockouts_unlock_token ON login_lockouts (unlock_token_hash);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status VARCHAR(16) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  file_path VARCHAR(255),
+  created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMPTZ NOT NULL,
+  downloaded_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exports_token_hash ON data_exports (token_hash);
+CREATE INDEX IF NOT EXISTS idx_exports_user_id ON data_exports (user_id);
+
+CREATE TABLE IF NOT EXISTS magic_links (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash CHAR(64) NOT NULL,
+  ip VARCHAR(45),
+  created_at TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_magic_links_token_hash ON magic_links (token_hash);
+CREATE INDEX IF NOT EXISTS idx_magic_links_user_id ON magic_links (user_id);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash CHAR(64) NOT NULL,
+  ip VARCHAR(45),
+  created_at TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_token_hash ON password_resets (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+  id BIGSERIAL PRIMARY KEY,
+  idempotency_key VARCHAR(128) NOT NULL,
+  sender VARCHAR(255) NOT NULL,
+  recipients TEXT NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  text_body TEXT NOT NULL,
+  html_body TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL,
+  sent_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_idempotency_key ON email_outbox (idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_outbox_status_next ON email_outbox (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+  user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  frequency VARCHAR(6) NOT NULL DEFAULT 'off' CHECK (frequency IN ('off', 'daily', 'weekly')),
+  send_hour SMALLINT NOT NULL DEFAULT 8,
+  time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  last_sent_on CHAR(10)
+);
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+  user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  locale VARCHAR(16) NOT NULL DEFAULT '',
+  time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  week_start VARCHAR(6) NOT NULL DEFAULT 'monday' CHECK (week_start IN ('monday', 'sunday')),
+  date_format VARCHAR(16) NOT NULL DEFAULT 'YYYY-MM-DD',
+  task_sort VARCHAR(16) NOT NULL DEFAULT 'created_asc'
+);
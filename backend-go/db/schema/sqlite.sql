@@ -0,0 +1,168 @@
+CREATE TABLE IF NOT EXISTS users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  username TEXT NOT NULL,
+  password TEXT,
+  name TEXT NOT NULL,
+  surname TEXT NOT NULL,
+  email TEXT NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  totp_secret TEXT,
+  totp_enabled_at DATETIME,
+  totp_last_counter INTEGER,
+  email_verified_at DATETIME,
+  verification_sent_at DATETIME,
+  deletion_scheduled_at DATETIME,
+  magic_link_enabled BOOLEAN NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS tasks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  title TEXT NOT NULL,
+  description TEXT,
+  status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'completed')),
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  due_date DATE,
+  completed_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_user_id ON tasks (user_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  user_agent TEXT,
+  ip TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  last_seen_at DATETIME,
+  expires_at DATETIME NOT NULL,
+  revoked_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_user ON mfa_recovery_codes (user_id, code_hash);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  scopes TEXT NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  expires_at DATETIME NOT NULL,
+  last_used_at DATETIME,
+  revoked_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pat_token_hash ON personal_access_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_pat_user_id ON personal_access_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_provider_subject ON user_identities (provider, subject);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+  attempt_key TEXT PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failure DATETIME NOT NULL,
+  locked_until DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  kind TEXT NOT NULL,
+  identifier TEXT NOT NULL,
+  user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  ip TEXT,
+  failures INTEGER NOT NULL,
+  locked_until DATETIME NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  unlock_token_hash TEXT,
+  unlocked_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_lockouts_identifier ON login_lockouts (kind, identifier);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lockouts_unlock_token ON login_lockouts (unlock_token_hash);
+
+CREATE TABLE IF NOT EXISTS data_exports (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  status TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  file_path TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  expires_at DATETIME NOT NULL,
+  downloaded_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exports_token_hash ON data_exports (token_hash);
+CREATE INDEX IF NOT EXISTS idx_exports_user_id ON data_exports (user_id);
+
+CREATE TABLE IF NOT EXISTS magic_links (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL,
+  ip TEXT,
+  created_at DATETIME NOT NULL,
+  expires_at DATETIME NOT NULL,
+  used_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_magic_links_token_hash ON magic_links (token_hash);
+CREATE INDEX IF NOT EXISTS idx_magic_links_user_id ON magic_links (user_id);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL,
+  ip TEXT,
+  created_at DATETIME NOT NULL,
+  expires_at DATETIME NOT NULL,
+  used_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_resets_token_hash ON password_resets (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  idempotency_key TEXT NOT NULL,
+  sender TEXT NOT NULL,
+  recipients TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  text_body TEXT NOT NULL,
+  html_body TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME NOT NULL,
+  last_error TEXT,
+  created_at DATETIME NOT NULL,
+  sent_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_idempotency_key ON email_outbox (idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_outbox_status_next ON email_outbox (status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+  user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  frequency TEXT NOT NULL DEFAULT 'off' CHECK (frequency IN ('off', 'daily', 'weekly')),
+  send_hour INTEGER NOT NULL DEFAULT 8,
+  time_zone TEXT NOT NULL DEFAULT 'UTC',
+  last_sent_on TEXT
+);
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+  user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  locale TEXT NOT NULL DEFAULT '',
+  time_zone TEXT NOT NULL DEFAULT 'UTC',
+  week_start TEXT NOT NULL DEFAULT 'monday' CHECK (week_start IN ('monday', 'sunday')),
+  date_format TEXT NOT NULL DEFAULT 'YYYY-MM-DD',
+  task_sort TEXT NOT NULL DEFAULT 'created_asc'
+);
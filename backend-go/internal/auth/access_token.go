@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// AccessTokenPrefix identifies personal access tokens so they can be told apart from JWTs.
+const AccessTokenPrefix = "tmpat_"
+
+// Scopes that can be granted to personal access tokens.
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+	ScopeUserRead   = "user:read"
+)
+
+// ValidScopes lists every scope accepted when creating a personal access token.
+var ValidScopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeUserRead}
+
+// AccessToken describes a verified personal access token.
+type AccessToken struct {
+	ID     int
+	UserID int
+	Scopes []string
+}
+
+// HasScope reports whether the token was granted the scope.
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessTokenVerifier resolves personal access tokens presented as Bearer credentials.
+type AccessTokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, token string) (*AccessToken, error)
+}
+
+// AccessTokens is the verifier used by AuthMiddleware. When nil, personal
+// access tokens are rejected.
+var AccessTokens AccessTokenVerifier
+
+// IsAccessToken reports whether the bearer credential looks like a personal access token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// IsValidScope reports whether scope can be granted to a personal access token.
+func IsValidScope(scope string) bool {
+	for _, s := range ValidScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
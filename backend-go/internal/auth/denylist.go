@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Denylist keeps the IDs (jti) of revoked tokens until they would have expired anyway.
+type Denylist interface {
+	Revoke(tokenID string, until time.Time)
+	IsRevoked(tokenID string) bool
+}
+
+// Revoked is the denylist consulted by ParseClaims and AuthMiddleware.
+var Revoked Denylist = NewMemoryDenylist()
+
+// MemoryDenylist is an in-process Denylist whose entries expire after their TTL.
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	now     func() time.Time
+}
+
+// NewMemoryDenylist creates an empty in-memory denylist.
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: make(map[string]time.Time), now: time.Now}
+}
+
+// Revoke adds the token ID to the denylist until the given time.
+// Entries already past their expiration are ignored.
+func (d *MemoryDenylist) Revoke(tokenID string, until time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if !until.After(now) {
+		return
+	}
+	d.entries[tokenID] = until
+	d.prune(now)
+}
+
+// IsRevoked reports whether the token ID is on the denylist and not yet expired.
+func (d *MemoryDenylist) IsRevoked(tokenID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	until, ok := d.entries[tokenID]
+	if !ok {
+		return false
+	}
+	if !until.After(d.now()) {
+		delete(d.entries, tokenID)
+		return false
+	}
+	return true
+}
+
+// prune removes expired entries so the map does not grow without bound.
+func (d *MemoryDenylist) prune(now time.Time) {
+	for id, until := range d.entries {
+		if !until.After(now) {
+			delete(d.entries, id)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"task-manager/backend-go/internal/problem"
+)
+
+// JWK is the public part of a signing key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicKeys returns the verification keys that can be published. HMAC
+// secrets are never included.
+func (k *Keyring) PublicKeys() JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+
+	return set
+}
+
+// JWKSHandler serves the public verification keys at /.well-known/jwks.json
+// so other services can verify tokens without sharing a secret.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(Keys.PublicKeys())
+}
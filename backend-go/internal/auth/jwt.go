@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"os"
 	"time"
@@ -11,36 +13,175 @@ import (
 	"github.com/joho/godotenv"
 )
 
-var jwtKey []byte
+// Keys is the keyring used to sign and verify tokens. It starts with the
+// JWT_SECRET from the environment and is replaced by main from configuration.
+var Keys *Keyring
 
-// Initialize JWT secret key from environment variables
+// tokenTTL is how long an issued JWT remains valid.
+const tokenTTL = 72 * time.Hour
+
+// ChallengeTTL is how long an MFA challenge token remains valid.
+const ChallengeTTL = 5 * time.Minute
+
+// purposeMFA marks tokens that only allow completing a two-factor login.
+const purposeMFA = "mfa"
+
+// Initialize the keyring from the JWT secret in the environment
 func init() {
 	_ = godotenv.Load()
-	jwtKey = []byte(os.Getenv("JWT_SECRET"))
+	ring, err := LoadKeyring(AlgHS256, "", []byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		panic(err)
+	}
+	Keys = ring
 }
 
-// GenerateJWT creates a signed JWT token containing the user ID and expiration
-func GenerateJWT(userID int) (string, error) {
+// IssuedToken holds a signed JWT together with its ID (jti) and expiration,
+// which callers need to track and revoke the session it belongs to.
+type IssuedToken struct {
+	Token     string
+	ID        string
+	ExpiresAt time.Time
+}
+
+// Claims holds the values extracted from a validated JWT.
+type Claims struct {
+	UserID    int
+	ID        string
+	ExpiresAt time.Time
+}
+
+// newTokenID creates a random identifier used as the jti claim.
+func newTokenID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// IssueJWT creates a signed JWT token for the user with a unique jti claim
+func IssueJWT(userID int) (*IssuedToken, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(tokenTTL)
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(72 * time.Hour).Unix(),
+		"jti":     id,
+		"exp":     expiresAt.Unix(),
+	}
+	signed, err := Keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IssuedToken{Token: signed, ID: id, ExpiresAt: time.Unix(expiresAt.Unix(), 0)}, nil
+}
+
+// GenerateJWT creates a signed JWT token containing the user ID and expiration
+func GenerateJWT(userID int) (string, error) {
+	issued, err := IssueJWT(userID)
+	if err != nil {
+		return "", err
+	}
+	return issued.Token, nil
+}
+
+// ParseClaims validates the JWT token string, rejects revoked tokens
+// and returns its claims.
+func ParseClaims(tokenStr string) (*Claims, error) {
+	token, err := Keys.Parse(tokenStr, jwt.MapClaims{})
+	if err != nil || !token.Valid {
+		return nil, errors.New(i18n.T("error.invalid_token"))
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New(i18n.T("error.invalid_token_claims"))
+	}
+
+	userIDFloat, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errors.New(i18n.T("error.invalid_token_claims"))
+	}
+
+	// Challenge tokens are not valid for API access
+	if _, ok := claims["purpose"]; ok {
+		return nil, errors.New(i18n.T("error.invalid_token"))
+	}
+
+	// Tokens without an ID could never be revoked
+	id, _ := claims["jti"].(string)
+	if id == "" {
+		return nil, errors.New(i18n.T("error.invalid_token_claims"))
+	}
+	if Revoked.IsRevoked(id) {
+		return nil, errors.New(i18n.T("error.token_revoked"))
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+
+	var expiresAt time.Time
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+
+	return &Claims{UserID: int(userIDFloat), ID: id, ExpiresAt: expiresAt}, nil
 }
 
 // ParseToken validates the JWT token string and extracts the user ID
 func ParseToken(tokenStr string) (int, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	claims, err := ParseClaims(tokenStr)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+// GeneratePurposeToken signs a short-lived token restricted to a single purpose
+// (MFA challenge, OIDC login flow...). ParseClaims rejects such tokens, so they
+// never grant API access.
+func GeneratePurposeToken(purpose string, values map[string]any, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{}
+	for k, v := range values {
+		claims[k] = v
+	}
+	claims["purpose"] = purpose
+	claims["exp"] = time.Now().Add(ttl).Unix()
+	return Keys.Sign(claims)
+}
+
+// ParsePurposeToken validates a token created by GeneratePurposeToken for the same purpose
+func ParsePurposeToken(purpose, tokenStr string) (map[string]any, error) {
+	token, err := Keys.Parse(tokenStr, jwt.MapClaims{})
 	if err != nil || !token.Valid {
-		return 0, errors.New(i18n.T("error.invalid_token"))
+		return nil, errors.New(i18n.T("error.invalid_token"))
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, errors.New(i18n.T("error.invalid_token_claims"))
+	if !ok || claims["purpose"] != purpose {
+		return nil, errors.New(i18n.T("error.invalid_token_claims"))
+	}
+	return claims, nil
+}
+
+// GenerateChallengeToken creates a short-lived token proving the password step
+// of a two-factor login succeeded. It cannot be used to access the API. The
+// jti makes every challenge distinct, so each can be spent on its own.
+func GenerateChallengeToken(userID int) (string, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	return GeneratePurposeToken(purposeMFA, map[string]any{"user_id": userID, "jti": id}, ChallengeTTL)
+}
+
+// ParseChallengeToken validates an MFA challenge token and extracts the user ID
+func ParseChallengeToken(tokenStr string) (int, error) {
+	claims, err := ParsePurposeToken(purposeMFA, tokenStr)
+	if err != nil {
+		return 0, err
 	}
 
 	userIDFloat, ok := claims["user_id"].(float64)
@@ -2,10 +2,12 @@ package auth_test
 
 import (
 	"testing"
+	"time"
 
 	"task-manager/backend-go/internal/auth"
 	"task-manager/backend-go/internal/i18n"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,3 +30,36 @@ func TestParseToken(t *testing.T) {
 	assert.NoError(t, err, i18n.T("test.jwt.parse.parse_no_error"))
 	assert.Equal(t, userID, parsedUserID, i18n.T("test.jwt.parse.match_id"))
 }
+
+// TestParseToken_Revoked verifies that a token on the denylist is rejected
+func TestParseToken_Revoked(t *testing.T) {
+	issued, err := auth.IssueJWT(123)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, issued.ID)
+
+	auth.Revoked.Revoke(issued.ID, issued.ExpiresAt)
+
+	_, err = auth.ParseToken(issued.Token)
+	assert.Error(t, err)
+}
+
+// TestParseToken_WithoutID verifies that tokens that cannot be revoked are rejected
+func TestParseToken_WithoutID(t *testing.T) {
+	token, err := auth.Keys.Sign(jwt.MapClaims{"user_id": 123, "exp": time.Now().Add(time.Hour).Unix()})
+	assert.NoError(t, err)
+
+	_, err = auth.ParseToken(token)
+	assert.Error(t, err)
+}
+
+// TestMemoryDenylist_Expiry verifies that denylist entries expire after their TTL
+func TestMemoryDenylist_Expiry(t *testing.T) {
+	denylist := auth.NewMemoryDenylist()
+
+	denylist.Revoke("active", time.Now().Add(time.Hour))
+	denylist.Revoke("expired", time.Now().Add(-time.Second))
+
+	assert.True(t, denylist.IsRevoked("active"))
+	assert.False(t, denylist.IsRevoked("expired"))
+	assert.False(t, denylist.IsRevoked("unknown"))
+}
@@ -0,0 +1,431 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"task-manager/backend-go/internal/i18n"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported JWT signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// legacyKeyID identifies the JWT_SECRET key. Tokens without a kid header
+// (issued before key rotation existed) are verified with it.
+const legacyKeyID = "default"
+
+// pemTypeHMAC is the PEM block type used to persist HMAC secrets.
+const pemTypeHMAC = "HMAC KEY"
+
+// keyReloadInterval limits how often a token with an unknown kid reloads the
+// keyring directory, so that forged kids cannot make every request read it.
+const keyReloadInterval = time.Minute
+
+// SigningKey is a key of the keyring, identified in tokens by the kid header.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewSigningKey generates a random key for the algorithm.
+func NewSigningKey(algorithm string, createdAt time.Time) (*SigningKey, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	key := &SigningKey{
+		ID:        fmt.Sprintf("%d-%s", createdAt.Unix(), hex.EncodeToString(suffix)),
+		Algorithm: algorithm,
+		CreatedAt: createdAt,
+	}
+
+	switch algorithm {
+	case AlgHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = secret, secret
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = private, &private.PublicKey
+	case AlgEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = private, public
+	default:
+		return nil, fmt.Errorf("%s: %s", i18n.T("error.jwt.unsupported_algorithm"), algorithm)
+	}
+
+	return key, nil
+}
+
+// HMACKey wraps an existing shared secret as an HS256 signing key.
+func HMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{ID: id, Algorithm: AlgHS256, signKey: secret, verifyKey: secret}
+}
+
+// method returns the jwt signing method matching the key's algorithm.
+func (k *SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// Keyring holds the key used to sign new tokens plus every key still accepted
+// for verification. Retired keys are kept for the retention period so that
+// tokens they signed stay valid until they expire.
+type Keyring struct {
+	mu        sync.RWMutex
+	algorithm string
+	dir       string
+	retention time.Duration
+	active    *SigningKey
+	keys      map[string]*SigningKey
+	reloaded  time.Time
+	now       func() time.Time
+}
+
+// NewKeyring creates an empty keyring that generates keys for the algorithm.
+func NewKeyring(algorithm string, retention time.Duration) *Keyring {
+	return &Keyring{
+		algorithm: algorithm,
+		retention: retention,
+		keys:      make(map[string]*SigningKey),
+		now:       time.Now,
+	}
+}
+
+// LoadKeyring builds the keyring from configuration. When dir is set, keys are
+// read from and persisted to that directory (shared between replicas);
+// otherwise the legacy secret is used for HS256 and an ephemeral key is
+// generated for asymmetric algorithms.
+func LoadKeyring(algorithm, dir string, legacySecret []byte) (*Keyring, error) {
+	if algorithm == "" {
+		algorithm = AlgHS256
+	}
+	if algorithm != AlgHS256 && algorithm != AlgRS256 && algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("%s: %s", i18n.T("error.jwt.unsupported_algorithm"), algorithm)
+	}
+
+	ring := NewKeyring(algorithm, tokenTTL)
+	if len(legacySecret) > 0 {
+		ring.Add(HMACKey(legacyKeyID, legacySecret))
+	}
+
+	if dir != "" {
+		ring.dir = dir
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+		if err := ring.Reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ring.active == nil || ring.active.Algorithm != algorithm {
+		if ring.dir == "" && algorithm != AlgHS256 {
+			log.Printf("%s", i18n.T("warning.jwt.ephemeral_key"))
+		}
+		if _, err := ring.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return ring, nil
+}
+
+// Add puts a key on the keyring. The most recently created key signs new tokens.
+func (k *Keyring) Add(key *SigningKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.add(key)
+}
+
+func (k *Keyring) add(key *SigningKey) {
+	k.keys[key.ID] = key
+	if k.active == nil || !key.CreatedAt.Before(k.active.CreatedAt) {
+		k.active = key
+	}
+}
+
+// Active returns the key currently used for signing.
+func (k *Keyring) Active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Rotate generates a new signing key, persists it when a directory is
+// configured and makes it active. Keys retired longer than the retention
+// period are dropped.
+func (k *Keyring) Rotate() (*SigningKey, error) {
+	key, err := NewSigningKey(k.algorithm, k.now())
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.dir != "" {
+		if err := writeKeyFile(k.dir, key); err != nil {
+			return nil, err
+		}
+	}
+	k.add(key)
+	k.prune()
+
+	return key, nil
+}
+
+// RotateEvery rotates the signing key whenever the active key is older than
+// interval, until stop is closed. Keys written by other replicas are picked
+// up on every check.
+func (k *Keyring) RotateEvery(interval time.Duration, stop <-chan struct{}) {
+	check := interval / 10
+	if check > time.Hour {
+		check = time.Hour
+	}
+	if check < time.Second {
+		check = time.Second
+	}
+
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if k.dir != "" {
+				if err := k.Reload(); err != nil {
+					log.Printf("%s: %v", i18n.T("error.jwt.key_reload"), err)
+				}
+			}
+			if active := k.Active(); active == nil || k.now().Sub(active.CreatedAt) >= interval {
+				if _, err := k.Rotate(); err != nil {
+					log.Printf("%s: %v", i18n.T("error.jwt.key_rotation"), err)
+				}
+			}
+		}
+	}
+}
+
+// Reload reads every key stored in the keyring directory.
+func (k *Keyring) Reload() error {
+	files, err := filepath.Glob(filepath.Join(k.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	loaded := make([]*SigningKey, 0, len(files))
+	for _, file := range files {
+		key, err := readKeyFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		loaded = append(loaded, key)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, key := range loaded {
+		if _, ok := k.keys[key.ID]; !ok {
+			k.add(key)
+		}
+	}
+	k.prune()
+	return nil
+}
+
+// prune drops keys that were superseded longer than the retention period ago.
+// The caller must hold the write lock.
+func (k *Keyring) prune() {
+	ordered := make([]*SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		ordered = append(ordered, key)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].CreatedAt.Before(ordered[j].CreatedAt) })
+
+	now := k.now()
+	for i := 0; i < len(ordered)-1; i++ {
+		retiredAt := ordered[i+1].CreatedAt
+		if ordered[i] == k.active || now.Sub(retiredAt) <= k.retention {
+			continue
+		}
+		delete(k.keys, ordered[i].ID)
+		if k.dir != "" && ordered[i].ID != legacyKeyID {
+			os.Remove(filepath.Join(k.dir, ordered[i].ID+".pem"))
+		}
+	}
+}
+
+// Sign signs the claims with the active key and sets the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key := k.Active()
+	if key == nil {
+		return "", errors.New(i18n.T("error.jwt.no_signing_key"))
+	}
+
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Parse verifies the token with the key named by its kid header. The signing
+// algorithm is pinned to the one of that key, so a token cannot pick a
+// different algorithm (e.g. "none" or HS256 with a public key as secret).
+func (k *Keyring) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	k.mu.RLock()
+	algorithms := make([]string, 0, 3)
+	seen := map[string]bool{}
+	for _, key := range k.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	k.mu.RUnlock()
+
+	parser := jwt.NewParser(jwt.WithValidMethods(algorithms))
+	return parser.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = legacyKeyID
+		}
+
+		key, err := k.key(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, errors.New(i18n.T("error.jwt.unexpected_algorithm"))
+		}
+		return key.verifyKey, nil
+	})
+}
+
+// key returns the key with the kid. An unknown kid reloads the directory, at
+// most once per keyReloadInterval, since another replica may have just
+// rotated to a key this one has not read yet.
+func (k *Keyring) key(kid string) (*SigningKey, error) {
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	k.mu.Lock()
+	reload := k.dir != "" && k.now().Sub(k.reloaded) >= keyReloadInterval
+	if reload {
+		k.reloaded = k.now()
+	}
+	k.mu.Unlock()
+	if !reload {
+		return nil, errors.New(i18n.T("error.jwt.unknown_key"))
+	}
+
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	key, ok = k.keys[kid]
+	k.mu.RUnlock()
+	if !ok {
+		return nil, errors.New(i18n.T("error.jwt.unknown_key"))
+	}
+	return key, nil
+}
+
+// writeKeyFile stores the private key as PEM named after its kid.
+func writeKeyFile(dir string, key *SigningKey) error {
+	block := &pem.Block{Headers: map[string]string{
+		"Algorithm": key.Algorithm,
+		"Created":   key.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}}
+
+	switch signKey := key.signKey.(type) {
+	case []byte:
+		block.Type = pemTypeHMAC
+		block.Bytes = signKey
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(signKey)
+		if err != nil {
+			return err
+		}
+		block.Type = "PRIVATE KEY"
+		block.Bytes = der
+	}
+
+	return os.WriteFile(filepath.Join(dir, key.ID+".pem"), pem.EncodeToMemory(block), 0o600)
+}
+
+// readKeyFile loads a key written by writeKeyFile.
+func readKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New(i18n.T("error.jwt.invalid_key_file"))
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, block.Headers["Created"])
+	if err != nil {
+		return nil, err
+	}
+	key := &SigningKey{
+		ID:        strings.TrimSuffix(filepath.Base(path), ".pem"),
+		Algorithm: block.Headers["Algorithm"],
+		CreatedAt: createdAt,
+	}
+
+	if block.Type == pemTypeHMAC {
+		key.signKey, key.verifyKey = block.Bytes, block.Bytes
+		return key, nil
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key.signKey, key.verifyKey = private, &private.PublicKey
+	case ed25519.PrivateKey:
+		key.signKey, key.verifyKey = private, private.Public()
+	default:
+		return nil, errors.New(i18n.T("error.jwt.invalid_key_file"))
+	}
+	return key, nil
+}
@@ -0,0 +1,111 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKeyring_AsymmetricAlgorithms verifies signing and parsing with RS256 and EdDSA keys
+func TestKeyring_AsymmetricAlgorithms(t *testing.T) {
+	for _, alg := range []string{auth.AlgRS256, auth.AlgEdDSA} {
+		ring, err := auth.LoadKeyring(alg, "", nil)
+		assert.NoError(t, err, alg)
+
+		signed, err := ring.Sign(jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Hour).Unix()})
+		assert.NoError(t, err, alg)
+
+		token, err := ring.Parse(signed, jwt.MapClaims{})
+		assert.NoError(t, err, alg)
+		assert.Equal(t, ring.Active().ID, token.Header["kid"], alg)
+
+		jwks := ring.PublicKeys()
+		assert.Len(t, jwks.Keys, 1, alg)
+		assert.Equal(t, alg, jwks.Keys[0].Algorithm)
+	}
+}
+
+// TestKeyring_RotationKeepsOldTokensValid verifies that retired keys still verify their tokens
+func TestKeyring_RotationKeepsOldTokensValid(t *testing.T) {
+	dir := t.TempDir()
+	ring, err := auth.LoadKeyring(auth.AlgEdDSA, dir, nil)
+	assert.NoError(t, err)
+
+	claims := jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Hour).Unix()}
+	before, err := ring.Sign(claims)
+	assert.NoError(t, err)
+	oldKey := ring.Active()
+
+	newKey, err := ring.Rotate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldKey.ID, newKey.ID)
+
+	_, err = ring.Parse(before, jwt.MapClaims{})
+	assert.NoError(t, err)
+	assert.Len(t, ring.PublicKeys().Keys, 2)
+
+	// A second instance sharing the directory verifies tokens from both keys
+	replica, err := auth.LoadKeyring(auth.AlgEdDSA, dir, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, newKey.ID, replica.Active().ID)
+	_, err = replica.Parse(before, jwt.MapClaims{})
+	assert.NoError(t, err)
+}
+
+// TestKeyring_ReloadsUnknownKey verifies that a token signed by a key another
+// replica just rotated to is verified after reading the directory again
+func TestKeyring_ReloadsUnknownKey(t *testing.T) {
+	dir := t.TempDir()
+	ring, err := auth.LoadKeyring(auth.AlgEdDSA, dir, nil)
+	assert.NoError(t, err)
+	replica, err := auth.LoadKeyring(auth.AlgEdDSA, dir, nil)
+	assert.NoError(t, err)
+
+	claims := jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Hour).Unix()}
+	_, err = replica.Rotate()
+	assert.NoError(t, err)
+	signed, err := replica.Sign(claims)
+	assert.NoError(t, err)
+
+	_, err = ring.Parse(signed, jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	// The directory was just read, so a second new key waits for the next reload
+	_, err = replica.Rotate()
+	assert.NoError(t, err)
+	signed, err = replica.Sign(claims)
+	assert.NoError(t, err)
+	_, err = ring.Parse(signed, jwt.MapClaims{})
+	assert.Error(t, err)
+}
+
+// TestKeyring_PinsAlgorithm rejects tokens whose algorithm differs from their key's
+func TestKeyring_PinsAlgorithm(t *testing.T) {
+	ring, err := auth.LoadKeyring(auth.AlgRS256, "", []byte("legacy-secret"))
+	assert.NoError(t, err)
+	claims := jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Hour).Unix()}
+
+	// HS256 token claiming the RS256 key ID
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = ring.Active().ID
+	signed, err := forged.SignedString([]byte("legacy-secret"))
+	assert.NoError(t, err)
+	_, err = ring.Parse(signed, jwt.MapClaims{})
+	assert.Error(t, err)
+
+	// Unsigned token
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	_, err = ring.Parse(unsigned, jwt.MapClaims{})
+	assert.Error(t, err)
+
+	// Tokens issued before key IDs existed are verified with the legacy secret
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("legacy-secret"))
+	assert.NoError(t, err)
+	_, err = ring.Parse(legacy, jwt.MapClaims{})
+	assert.NoError(t, err)
+}
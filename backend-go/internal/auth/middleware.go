@@ -2,62 +2,202 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 
 	"task-manager/backend-go/internal/i18n"
-
-	"github.com/golang-jwt/jwt/v5"
+	"task-manager/backend-go/internal/problem"
 )
 
+// SessionTracker is notified about authenticated requests so the last-seen
+// time of a session can be kept up to date.
+type SessionTracker interface {
+	Touch(ctx context.Context, sessionID, ip string)
+}
+
+// Sessions is the optional tracker used by AuthMiddleware.
+var Sessions SessionTracker
+
+// LocalePreferences returns the language a user chose, "" when they have none.
+type LocalePreferences interface {
+	PreferredLocale(ctx context.Context, userID int) string
+}
+
+// Locales is the optional source of user languages used by AuthMiddleware.
+var Locales LocalePreferences
+
 // AuthMiddleware verifies JWT token and protects routes.
 // Extracts user ID from token and adds it to the request context.
+// Revoked tokens are rejected. Personal access tokens are only accepted on
+// routes wrapped with ScopedAuthMiddleware.
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return ScopedAuthMiddleware("", "", next)
+}
+
+// ScopedAuthMiddleware works like AuthMiddleware but also accepts personal
+// access tokens: GET and HEAD requests need readScope, any other method needs
+// writeScope. An empty scope means personal access tokens are not allowed.
+func ScopedAuthMiddleware(readScope, writeScope string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, i18n.T("error.token_not_provided"), http.StatusUnauthorized)
+			problem.Write(w, r, http.StatusUnauthorized, problem.TokenMissing)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, i18n.T("error.invalid_token_format"), http.StatusUnauthorized)
+			problem.Write(w, r, http.StatusUnauthorized, problem.TokenMalformed)
 			return
 		}
 
-		tokenString := parts[1]
-		jwtKey := []byte(os.Getenv("JWT_SECRET"))
+		if IsAccessToken(parts[1]) {
+			required := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				required = readScope
+			}
 
-		claims := &jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
+			if AccessTokens == nil {
+				problem.Write(w, r, http.StatusUnauthorized, problem.InvalidToken)
+				return
+			}
+			token, err := AccessTokens.VerifyAccessToken(r.Context(), parts[1])
+			if err != nil {
+				problem.Write(w, r, http.StatusUnauthorized, problem.InvalidToken)
+				return
+			}
+			if required == "" || !token.HasScope(required) {
+				problem.Write(w, r, http.StatusForbidden, problem.InsufficientScope)
+				return
+			}
 
-		if err != nil || !token.Valid {
-			http.Error(w, i18n.T("error.invalid_token"), http.StatusUnauthorized)
+			ctx := contextWithUserID(r.Context(), token.UserID)
+			ctx = withPreferredLocale(ctx, token.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
-		// Extract user_id from claims as float64, then convert to int
-		userIDFloat, ok := (*claims)["user_id"].(float64)
-		if !ok {
-			http.Error(w, i18n.T("error.invalid_token_claims"), http.StatusUnauthorized)
+		claims, err := ParseClaims(parts[1])
+		if err != nil {
+			problem.Write(w, r, http.StatusUnauthorized, problem.InvalidToken)
 			return
 		}
-		userID := int(userIDFloat)
 
-		// Add userID to context for downstream handlers
-		ctx := contextWithUserID(r.Context(), userID)
+		if Sessions != nil && claims.ID != "" {
+			Sessions.Touch(r.Context(), claims.ID, ClientIP(r))
+		}
+
+		// Add userID and session ID to context for downstream handlers
+		ctx := contextWithUserID(r.Context(), claims.UserID)
+		ctx = context.WithValue(ctx, sessionIDKey, claims.ID)
+		ctx = withPreferredLocale(ctx, claims.UserID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// withPreferredLocale translates the rest of the request into the language of
+// the user, unless the request chose one with ?lang=.
+func withPreferredLocale(ctx context.Context, userID int) context.Context {
+	if Locales == nil {
+		return ctx
+	}
+	return i18n.WithUserLocale(ctx, Locales.PreferredLocale(ctx, userID))
+}
+
+// TrustedProxies are the reverse proxies allowed to set X-Forwarded-For.
+// Requests from any other address are identified by their RemoteAddr, so
+// clients cannot pick the IP used for rate limits, lockouts and sessions.
+var TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses IP addresses and CIDR ranges such as "10.0.0.0/8".
+func ParseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", value)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			value = fmt.Sprintf("%s/%d", value, bits)
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", value)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trustedProxy reports whether ip belongs to one of the TrustedProxies.
+func trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, network := range TrustedProxies {
+		if parsed != nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client. X-Forwarded-For is only
+// honoured when the request comes from a trusted proxy: its entries are read
+// from the right, skipping the trusted proxies in the chain.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// ClientKey identifies the caller for rate limiting: "user:<id>" for a valid
+// JWT, "token:<sha256>" for a personal access token and "ip:<address>"
+// otherwise. Access tokens are keyed by their hash rather than looked up, so
+// that requests over the limit never reach the database.
+func ClientKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if IsAccessToken(token) {
+			sum := sha256.Sum256([]byte(token))
+			return "token:" + hex.EncodeToString(sum[:])
+		} else if claims, err := ParseClaims(token); err == nil {
+			return "user:" + strconv.Itoa(claims.UserID)
+		}
+	}
+	return "ip:" + ClientIP(r)
+}
+
 // contextKey type is used to define context keys for type safety
 type contextKey string
 
-const userIDKey = contextKey("user_id")
+const (
+	userIDKey    = contextKey("user_id")
+	sessionIDKey = contextKey("session_id")
+)
 
 // contextWithUserID adds the userID to the context
 func contextWithUserID(ctx context.Context, userID int) context.Context {
@@ -69,3 +209,9 @@ func UserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(userIDKey).(int)
 	return userID, ok
 }
+
+// SessionIDFromContext retrieves the session (token jti) from the context
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey).(string)
+	return sessionID, ok && sessionID != ""
+}
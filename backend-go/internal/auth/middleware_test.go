@@ -0,0 +1,142 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"task-manager/backend-go/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAccessTokens accepts a single personal access token with fixed scopes
+type stubAccessTokens struct {
+	token  string
+	scopes []string
+}
+
+func (s stubAccessTokens) VerifyAccessToken(ctx context.Context, token string) (*auth.AccessToken, error) {
+	if token != s.token {
+		return nil, errors.New("unknown token")
+	}
+	return &auth.AccessToken{ID: 1, UserID: 7, Scopes: s.scopes}, nil
+}
+
+// verifyNever fails the test when an access token is looked up
+type verifyNever struct {
+	t *testing.T
+}
+
+func (v verifyNever) VerifyAccessToken(ctx context.Context, token string) (*auth.AccessToken, error) {
+	v.t.Error("access token looked up")
+	return nil, errors.New("unexpected lookup")
+}
+
+// TestScopedAuthMiddleware_AccessTokens verifies scope checks for personal access tokens
+func TestScopedAuthMiddleware_AccessTokens(t *testing.T) {
+	token := auth.AccessTokenPrefix + "abc"
+	auth.AccessTokens = stubAccessTokens{token: token, scopes: []string{auth.ScopeTasksRead}}
+	defer func() { auth.AccessTokens = nil }()
+
+	var gotUserID int
+	next := func(w http.ResponseWriter, r *http.Request) {
+		gotUserID, _ = auth.UserIDFromContext(r.Context())
+	}
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		status  int
+	}{
+		{"read scope granted", auth.ScopedAuthMiddleware(auth.ScopeTasksRead, auth.ScopeTasksWrite, next), http.MethodGet, http.StatusOK},
+		{"write scope missing", auth.ScopedAuthMiddleware(auth.ScopeTasksRead, auth.ScopeTasksWrite, next), http.MethodPost, http.StatusForbidden},
+		{"route without scopes", auth.AuthMiddleware(next), http.MethodGet, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		gotUserID = 0
+		req := httptest.NewRequest(tc.method, "/api/tasks/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		tc.handler(rec, req)
+
+		assert.Equal(t, tc.status, rec.Code, tc.name)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, 7, gotUserID, tc.name)
+		}
+	}
+}
+
+// TestClientKey verifies rate limit keys for authenticated and anonymous requests
+func TestClientKey(t *testing.T) {
+	token := auth.AccessTokenPrefix + "abc"
+	auth.AccessTokens = verifyNever{t}
+	defer func() { auth.AccessTokens = nil }()
+	sum := sha256.Sum256([]byte(token))
+
+	jwtToken, err := auth.GenerateJWT(42)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"jwt", "Bearer " + jwtToken, "user:42"},
+		{"access token", "Bearer " + token, "token:" + hex.EncodeToString(sum[:])},
+		{"invalid jwt", "Bearer not-a-token", "ip:192.0.2.1"},
+		{"anonymous", "", "ip:192.0.2.1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/tasks/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			assert.Equal(t, tc.want, auth.ClientKey(req))
+		})
+	}
+}
+
+// TestClientIP verifies that X-Forwarded-For is only honoured behind a trusted proxy
+func TestClientIP(t *testing.T) {
+	proxies, err := auth.ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	assert.NoError(t, err)
+	auth.TrustedProxies = proxies
+	defer func() { auth.TrustedProxies = nil }()
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct", "203.0.113.9:1234", "", "203.0.113.9"},
+		{"spoofed header", "203.0.113.9:1234", "198.51.100.7", "203.0.113.9"},
+		{"trusted proxy", "192.0.2.1:1234", "198.51.100.7", "198.51.100.7"},
+		{"client entry prepended", "10.0.0.2:1234", "1.2.3.4, 198.51.100.7, 10.0.0.3", "198.51.100.7"},
+		{"malformed entry", "10.0.0.2:1234", "198.51.100.7, garbage", "10.0.0.2"},
+		{"proxy without header", "10.0.0.2:1234", "", "10.0.0.2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/login", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwarded)
+			}
+			assert.Equal(t, tc.want, auth.ClientIP(req))
+		})
+	}
+
+	_, err = auth.ParseTrustedProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,193 @@
+package i18n_test
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"task-manager/backend-go/assets"
+	"task-manager/backend-go/internal/problem"
+)
+
+// sourceRoot is the module root scanned for translation keys.
+const sourceRoot = "../.."
+
+// checkedLocales must each translate every key.
+var checkedLocales = []string{"en", "es", "ca", "ja"}
+
+// translateFuncs maps the functions taking a translation key to the index of that argument.
+var translateFuncs = map[string]int{
+	"i18n.T":             0,
+	"i18n.Translate":     1,
+	"i18n.TranslateWith": 1,
+	"i18n.Lookup":        1,
+	"i18n.Format":        1,
+}
+
+// templateKey matches {{t "key"}} in the email templates.
+var templateKey = regexp.MustCompile(`\bt "([^"]+)"`)
+
+// sourceKeys scans the Go files and email templates of the module. It returns
+// the keys passed as literals to the translation functions or used for the
+// detail of an error code, with their positions, and every string literal, which marks a key as used even when it
+// is translated indirectly.
+func sourceKeys(t *testing.T) (map[string][]string, map[string]bool) {
+	keys := map[string][]string{}
+	literals := map[string]bool{}
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir(sourceRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return nil
+		case strings.HasSuffix(path, ".tmpl"):
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, match := range templateKey.FindAllStringSubmatch(string(data), -1) {
+				keys[match[1]] = append(keys[match[1]], path)
+				literals[match[1]] = true
+			}
+			return nil
+		case !strings.HasSuffix(path, ".go"):
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.BasicLit:
+				if node.Kind == token.STRING {
+					if value, err := strconv.Unquote(node.Value); err == nil {
+						literals[value] = true
+					}
+				}
+			case *ast.CallExpr:
+				index, ok := translateFuncs[funcName(node.Fun)]
+				if !ok || index >= len(node.Args) {
+					return true
+				}
+				if lit, ok := node.Args[index].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					key, _ := strconv.Unquote(lit.Value)
+					keys[key] = append(keys[key], fset.Position(lit.Pos()).String())
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range problem.Codes() {
+		keys[code.MessageKey()] = append(keys[code.MessageKey()], "problem."+string(code))
+	}
+	return keys, literals
+}
+
+// funcName returns "pkg.Func" or "Func" for the called expression.
+func funcName(fun ast.Expr) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := f.X.(*ast.Ident); ok {
+			return pkg.Name + "." + f.Sel.Name
+		}
+	}
+	return ""
+}
+
+// catalogKeys returns the keys of an embedded locale file.
+func catalogKeys(t *testing.T, locale string) map[string]bool {
+	data, err := fs.ReadFile(assets.I18n, "i18n/"+locale+".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages map[string]json.RawMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatalf("%s.json: %v", locale, err)
+	}
+	keys := map[string]bool{}
+	for key := range messages {
+		keys[key] = true
+	}
+	return keys
+}
+
+// used reports whether key appears as a string literal, or starts with a
+// literal prefix such as "password.rule." completed at run time.
+func used(key string, literals map[string]bool) bool {
+	if literals[key] {
+		return true
+	}
+	for literal := range literals {
+		if (strings.HasSuffix(literal, ".") || strings.HasSuffix(literal, "_")) && strings.HasPrefix(key, literal) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCatalogConsistency reports keys used in the source but missing from a
+// locale, keys only some locales translate, and keys nothing uses.
+func TestCatalogConsistency(t *testing.T) {
+	keys, literals := sourceKeys(t)
+
+	catalogs := map[string]map[string]bool{}
+	all := map[string]bool{}
+	for _, locale := range checkedLocales {
+		catalogs[locale] = catalogKeys(t, locale)
+		for key := range catalogs[locale] {
+			all[key] = true
+		}
+	}
+
+	for _, locale := range checkedLocales {
+		var missing, untranslated, unused []string
+		for key, positions := range keys {
+			if !catalogs[locale][key] {
+				missing = append(missing, key+" ("+positions[0]+")")
+			}
+		}
+		for key := range all {
+			if !catalogs[locale][key] && keys[key] == nil {
+				untranslated = append(untranslated, key)
+			}
+		}
+		for key := range catalogs[locale] {
+			if !used(key, literals) {
+				unused = append(unused, key)
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(untranslated)
+		sort.Strings(unused)
+
+		if len(missing) > 0 {
+			t.Errorf("%s.json is missing %d keys used in the source:\n  %s", locale, len(missing), strings.Join(missing, "\n  "))
+		}
+		if len(untranslated) > 0 {
+			t.Errorf("%s.json is missing %d keys other locales translate:\n  %s", locale, len(untranslated), strings.Join(untranslated, "\n  "))
+		}
+		if len(unused) > 0 {
+			t.Errorf("%s.json has %d unused keys:\n  %s", locale, len(unused), strings.Join(unused, "\n  "))
+		}
+	}
+}
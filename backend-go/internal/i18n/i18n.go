@@ -3,33 +3,261 @@ package i18n
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"task-manager/backend-go/assets"
 )
 
-// Messages holds the loaded translation key-value pairs
+// Messages holds the translations of DefaultLocale, used by T. Plural
+// messages hold their "other" form.
 var Messages map[string]string
 
-// LoadMessages loads the translations from a JSON file for the given locale.
-// The JSON file should be located at "assets/i18n/{locale}.json"
-func LoadMessages(locale string) error {
-	path := fmt.Sprintf("assets/i18n/%s.json", locale)
+// DefaultLocale is the locale of Messages, set by LoadMessages.
+var DefaultLocale string
+
+// Default is the catalog loaded by LoadMessages.
+var Default *Catalog
+
+// Fallbacks lists, per language, the languages tried in order when a key is
+// missing, before the default locale of the catalog. It is read when a
+// catalog is loaded.
+var Fallbacks = map[string][]string{
+	"ca": {"es", "en"},
+	"es": {"en"},
+	"ja": {"en"},
+}
+
+// Params are the named values interpolated into a message, such as
+// {"count": 3} for "{count} tasks overdue". "count" also selects the plural form.
+type Params map[string]any
+
+// message is one translation: a plain string, or plural forms keyed by CLDR
+// category ("zero", "one", "two", "few", "many", "other") in the JSON files.
+type message struct {
+	other string
+	forms map[string]string
+}
+
+// UnmarshalJSON accepts a string or an object of plural forms with at least "other".
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.other); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.forms); err != nil {
+		return err
+	}
+	for category := range m.forms {
+		if !validCategory(category) {
+			return fmt.Errorf("unknown plural category %q", category)
+		}
+	}
+	other, ok := m.forms["other"]
+	if !ok {
+		return fmt.Errorf("plural message without an \"other\" form")
+	}
+	m.other = other
+	return nil
+}
+
+// Catalog holds the translations of every locale. It is not modified once
+// loaded, so it can be shared by concurrent requests without locking.
+type Catalog struct {
+	defaultLocale string
+	messages      map[string]map[string]message
+	fallbacks     map[string][]string
+}
 
-	file, err := os.Open(path)
+// LoadCatalog reads every "{locale}.json" file at the root of fsys.
+// defaultLocale must be one of them; it ends every fallback chain.
+func LoadCatalog(fsys fs.FS, defaultLocale string) (*Catalog, error) {
+	paths, err := fs.Glob(fsys, "*.json")
 	if err != nil {
-		// Return error with translated message key for file open failure
-		return fmt.Errorf("%s: %w", T("error.i18n.load"), err)
+		return nil, err
 	}
-	defer file.Close()
 
-	decoder := json.NewDecoder(file)
-	if err = decoder.Decode(&Messages); err != nil {
-		// Return error with translated message key for JSON decode failure
-		return fmt.Errorf("%s: %w", T("error.i18n_json_decode_failed"), err)
+	c := &Catalog{
+		defaultLocale: defaultLocale,
+		messages:      map[string]map[string]message{},
+		fallbacks:     map[string][]string{},
+	}
+	for _, file := range paths {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", T("error.i18n.load"), err)
+		}
+		var messages map[string]message
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", T("error.i18n_json_decode_failed"), file, err)
+		}
+		locale := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		c.messages[locale] = messages
+	}
+	if _, ok := c.messages[defaultLocale]; !ok {
+		return nil, fmt.Errorf("%s: %s.json", T("error.i18n.load"), defaultLocale)
+	}
+	for locale, chain := range Fallbacks {
+		c.fallbacks[locale] = append([]string(nil), chain...)
+	}
+	return c, nil
+}
+
+// Locales returns the locales of the catalog, sorted.
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// DefaultLocale returns the locale ending every fallback chain.
+func (c *Catalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+// Match returns the catalog locale for a language tag such as "ca" or
+// "es-MX", falling back to its base language, or "" when neither is available.
+func (c *Catalog) Match(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if _, ok := c.messages[tag]; ok {
+		return tag
+	}
+	if base, _, found := strings.Cut(tag, "-"); found {
+		if _, ok := c.messages[base]; ok {
+			return base
+		}
+	}
+	return ""
+}
+
+// Chain returns the locales searched for a key in locale: the locale itself,
+// its fallbacks and the default locale, such as [ca es en] for "ca-ES".
+func (c *Catalog) Chain(locale string) []string {
+	var chain []string
+	seen := map[string]bool{}
+	add := func(candidate string) {
+		if matched := c.Match(candidate); matched != "" && !seen[matched] {
+			seen[matched] = true
+			chain = append(chain, matched)
+		}
 	}
 
+	add(locale)
+	if len(chain) > 0 {
+		for _, fallback := range c.fallbacks[chain[0]] {
+			add(fallback)
+		}
+	}
+	add(c.defaultLocale)
+	return chain
+}
+
+// find returns the first translation of key along the chain of locale, with
+// the locale it was found in.
+func (c *Catalog) find(locale, key string) (message, string, bool) {
+	for _, candidate := range c.Chain(locale) {
+		if msg, ok := c.messages[candidate][key]; ok {
+			return msg, candidate, true
+		}
+	}
+	return message{}, "", false
+}
+
+// Lookup returns the translation of key in locale, following its fallback
+// chain, or the key itself when no locale has it.
+func (c *Catalog) Lookup(locale, key string) string {
+	return c.Format(locale, key, nil)
+}
+
+// Format returns the translation of key in locale with params interpolated.
+// A numeric "count" param selects the plural form by the CLDR rules of the
+// locale the message was found in.
+func (c *Catalog) Format(locale, key string, params Params) string {
+	msg, found, ok := c.find(locale, key)
+	if !ok {
+		return key
+	}
+
+	text := msg.other
+	if count, ok := params["count"]; ok && msg.forms != nil {
+		if form, ok := msg.forms[PluralCategory(found, count)]; ok {
+			text = form
+		}
+	}
+	return interpolate(text, params)
+}
+
+// interpolate replaces the {name} placeholders of text with params; unknown
+// placeholders are left as they are.
+func interpolate(text string, params Params) string {
+	if len(params) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(text[:start])
+		if value, ok := params[text[start+1:end]]; ok {
+			fmt.Fprint(&b, value)
+		} else {
+			b.WriteString(text[start : end+1])
+		}
+		text = text[end+1:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// LoadMessages loads every locale embedded from "assets/i18n/" into Default
+// and makes locale the default one, used by T and at the end of every
+// fallback chain.
+func LoadMessages(locale string) error {
+	files, err := fs.Sub(assets.I18n, "i18n")
+	if err != nil {
+		return err
+	}
+	catalog, err := LoadCatalog(files, locale)
+	if err != nil {
+		return err
+	}
+
+	messages := make(map[string]string, len(catalog.messages[locale]))
+	for key, msg := range catalog.messages[locale] {
+		messages[key] = msg.other
+	}
+	Default = catalog
+	Messages = messages
+	DefaultLocale = locale
 	return nil
 }
 
+// Lookup returns the translation of key in locale, such as "ca" or "es-MX",
+// from the Default catalog, following the fallback chain of the locale.
+func Lookup(locale, key string) string {
+	return Format(locale, key, nil)
+}
+
+// Format returns the translation of key in locale from the Default catalog,
+// interpolating params and choosing the plural form from params["count"].
+func Format(locale, key string, params Params) string {
+	if Default == nil {
+		return interpolate(T(key), params)
+	}
+	return Default.Format(locale, key, params)
+}
+
 // T returns the translation for the given key or the key itself if missing.
 // Used throughout the backend to retrieve localized strings.
 func T(key string) string {
@@ -0,0 +1,155 @@
+package i18n_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"task-manager/backend-go/internal/i18n"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loadCatalog makes the shipped translations the Default catalog, Spanish being the default locale.
+func loadCatalog(t *testing.T) *i18n.Catalog {
+	catalog, err := i18n.LoadCatalog(os.DirFS("../../assets/i18n"), "es")
+	assert.NoError(t, err)
+
+	previous, previousLocale := i18n.Default, i18n.DefaultLocale
+	i18n.Default, i18n.DefaultLocale = catalog, "es"
+	t.Cleanup(func() { i18n.Default, i18n.DefaultLocale = previous, previousLocale })
+	return catalog
+}
+
+// TestCatalog verifies that every locale is loaded and lookups fall back to the default locale
+func TestCatalog(t *testing.T) {
+	catalog := loadCatalog(t)
+
+	assert.Equal(t, []string{"ca", "en", "es", "ja"}, catalog.Locales())
+	assert.Equal(t, "en", catalog.Match("en-GB"))
+	assert.Equal(t, "ca", catalog.Match("CA_es"))
+	assert.Equal(t, "", catalog.Match("fr"))
+
+	assert.Equal(t, "Invalid data", catalog.Lookup("en-US", "http.error.invalid_data"))
+	assert.Equal(t, catalog.Lookup("es", "http.error.invalid_data"), catalog.Lookup("fr", "http.error.invalid_data"))
+	assert.Equal(t, "missing.key", catalog.Lookup("en", "missing.key"))
+
+	_, err := i18n.LoadCatalog(os.DirFS("../../assets/i18n"), "fr")
+	assert.Error(t, err)
+}
+
+// TestParseAcceptLanguage verifies ordering by quality and skipped entries
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"ca-ES", "ca", "en"}, i18n.ParseAcceptLanguage("en;q=0.5, ca-ES, *;q=0.1, ca;q=0.9, ja;q=0"))
+	assert.Empty(t, i18n.ParseAcceptLanguage(""))
+}
+
+// TestMiddleware verifies the precedence of ?lang=, the user preference and Accept-Language
+func TestMiddleware(t *testing.T) {
+	catalog := loadCatalog(t)
+
+	var locale string
+	preferred := ""
+	handler := i18n.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// What AuthMiddleware does once the user is known
+		ctx := i18n.WithUserLocale(r.Context(), preferred)
+		locale = i18n.LocaleFromContext(ctx)
+	}))
+	serve := func(target, acceptLanguage string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return locale
+	}
+
+	assert.Equal(t, "es", serve("/", ""))
+	assert.Equal(t, "es", serve("/", "fr-FR, de;q=0.8"))
+	assert.Equal(t, "ca", serve("/", "fr-FR, ca;q=0.8, en;q=0.5"))
+	assert.Equal(t, "ja", serve("/?lang=ja", "ca"))
+	assert.Equal(t, "ca", serve("/?lang=xx", "ca"))
+
+	preferred = "en"
+	assert.Equal(t, "en", serve("/", "ca"))
+	assert.Equal(t, "ja", serve("/?lang=ja", "ca"))
+	preferred = "xx"
+	assert.Equal(t, "ca", serve("/", "ca"))
+
+	assert.Equal(t, catalog.Lookup("ja", "http.error.invalid_data"),
+		i18n.Translate(i18n.WithLocale(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "ja"), "http.error.invalid_data"))
+}
+
+// writeLocales writes translation files to a temporary directory.
+func writeLocales(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for locale, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, locale+".json"), []byte(content), 0o644))
+	}
+	return dir
+}
+
+// TestFormat verifies interpolation, plural forms and fallback chains
+func TestFormat(t *testing.T) {
+	dir := writeLocales(t, map[string]string{
+		"en": `{"overdue": {"one": "{count} task overdue", "other": "{count} tasks overdue"}, "hello": "Hello {name}", "only_en": "English"}`,
+		"es": `{"overdue": {"one": "{count} tarea vencida", "other": "{count} tareas vencidas"}, "only_es": "Español"}`,
+		"ca": `{"overdue": {"one": "{count} tasca vençuda", "other": "{count} tasques vençudes"}}`,
+		"ja": `{"overdue": "期限切れのタスク {count} 件"}`,
+	})
+	catalog, err := i18n.LoadCatalog(os.DirFS(dir), "en")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "1 task overdue", catalog.Format("en", "overdue", i18n.Params{"count": 1}))
+	assert.Equal(t, "3 tasks overdue", catalog.Format("en", "overdue", i18n.Params{"count": 3}))
+	assert.Equal(t, "1.0 tasks overdue", catalog.Format("en", "overdue", i18n.Params{"count": "1.0"}))
+	assert.Equal(t, "1 tasca vençuda", catalog.Format("ca-ES", "overdue", i18n.Params{"count": 1}))
+	assert.Equal(t, "0 tasques vençudes", catalog.Format("ca", "overdue", i18n.Params{"count": 0}))
+	assert.Equal(t, "期限切れのタスク 1 件", catalog.Format("ja", "overdue", i18n.Params{"count": 1}))
+
+	// Plural messages without a count use their "other" form, unknown placeholders are kept
+	assert.Equal(t, "{count} tasks overdue", catalog.Lookup("en", "overdue"))
+	assert.Equal(t, "Hello Thor", catalog.Format("en", "hello", i18n.Params{"name": "Thor"}))
+	assert.Equal(t, "Hello {name}", catalog.Format("en", "hello", i18n.Params{"other": 1}))
+
+	// ca → es → en, then the key
+	assert.Equal(t, []string{"ca", "es", "en"}, catalog.Chain("ca"))
+	assert.Equal(t, []string{"ja", "en"}, catalog.Chain("ja-JP"))
+	assert.Equal(t, []string{"en"}, catalog.Chain("fr"))
+	assert.Equal(t, "Español", catalog.Lookup("ca", "only_es"))
+	assert.Equal(t, "English", catalog.Lookup("ca", "only_en"))
+	assert.Equal(t, "missing", catalog.Lookup("ca", "missing"))
+
+	// Plural objects need an "other" form and known categories
+	_, err = i18n.LoadCatalog(os.DirFS(writeLocales(t, map[string]string{"en": `{"k": {"one": "x"}}`})), "en")
+	assert.Error(t, err)
+	_, err = i18n.LoadCatalog(os.DirFS(writeLocales(t, map[string]string{"en": `{"k": {"several": "x", "other": "y"}}`})), "en")
+	assert.Error(t, err)
+}
+
+// TestPluralCategory verifies the CLDR rules of a few languages
+func TestPluralCategory(t *testing.T) {
+	cases := []struct {
+		locale string
+		count  any
+		want   string
+	}{
+		{"en", 1, i18n.PluralOne},
+		{"en", 0, i18n.PluralOther},
+		{"en", 1.5, i18n.PluralOther},
+		{"es", 1, i18n.PluralOne},
+		{"es", "1.0", i18n.PluralOne},
+		{"ca", "1.0", i18n.PluralOther},
+		{"fr", 0, i18n.PluralOne},
+		{"ja", 1, i18n.PluralOther},
+		{"ru", 21, i18n.PluralOne},
+		{"ru", 22, i18n.PluralFew},
+		{"ru", 11, i18n.PluralMany},
+		{"pl", 12, i18n.PluralMany},
+		{"pl", 23, i18n.PluralFew},
+		{"en", "abc", i18n.PluralOther},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, i18n.PluralCategory(c.locale, c.count), "%s %v", c.locale, c.count)
+	}
+}
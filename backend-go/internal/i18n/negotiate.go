@@ -0,0 +1,128 @@
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// requestLocale is the locale of a request; explicit is set when it was
+// chosen with ?lang=, which overrides the stored preference of the user.
+type requestLocale struct {
+	locale   string
+	explicit bool
+}
+
+// localeKey is the context key of the request locale.
+type localeKey struct{}
+
+// WithLocale returns a context translating into locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, requestLocale{locale: locale})
+}
+
+// WithUserLocale applies the stored locale of the authenticated user, unless
+// it is empty, unsupported or the request chose one with ?lang=.
+func WithUserLocale(ctx context.Context, locale string) context.Context {
+	if current, ok := ctx.Value(localeKey{}).(requestLocale); ok && current.explicit {
+		return ctx
+	}
+	if Default == nil || locale == "" {
+		return ctx
+	}
+	if matched := Default.Match(locale); matched != "" {
+		return WithLocale(ctx, matched)
+	}
+	return ctx
+}
+
+// LocaleFromContext returns the locale of the request, DefaultLocale when none was set.
+func LocaleFromContext(ctx context.Context) string {
+	if current, ok := ctx.Value(localeKey{}).(requestLocale); ok {
+		return current.locale
+	}
+	return DefaultLocale
+}
+
+// Translate returns the translation of key in the locale of the request.
+func Translate(ctx context.Context, key string) string {
+	return Lookup(LocaleFromContext(ctx), key)
+}
+
+// TranslateWith returns the translation of key in the locale of the request
+// with params interpolated, choosing the plural form from params["count"].
+func TranslateWith(ctx context.Context, key string, params Params) string {
+	return Format(LocaleFromContext(ctx), key, params)
+}
+
+// Middleware resolves the locale of each request from a ?lang= override, then
+// the Accept-Language header, then DefaultLocale. AuthMiddleware later applies
+// the stored preference of the user, which only ?lang= overrides.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Language")
+		if Default == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		current := requestLocale{locale: DefaultLocale}
+		if matched := Default.Match(r.URL.Query().Get("lang")); matched != "" {
+			current = requestLocale{locale: matched, explicit: true}
+		} else if matched := Negotiate(Default, r.Header.Get("Accept-Language")); matched != "" {
+			current.locale = matched
+		}
+		ctx := context.WithValue(r.Context(), localeKey{}, current)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Negotiate returns the catalog locale best matching an Accept-Language
+// header such as "ca-ES,ca;q=0.9,en;q=0.5", or "" when none matches.
+func Negotiate(c *Catalog, acceptLanguage string) string {
+	for _, tag := range ParseAcceptLanguage(acceptLanguage) {
+		if matched := c.Match(tag); matched != "" {
+			return matched
+		}
+	}
+	return ""
+}
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language header
+// by decreasing quality, leaving out "*" and tags with q=0.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			value, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = value
+		}
+		if quality <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag, quality})
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
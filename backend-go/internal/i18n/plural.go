@@ -0,0 +1,154 @@
+package i18n
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CLDR plural categories.
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// validCategory reports whether category is a CLDR plural category.
+func validCategory(category string) bool {
+	switch category {
+	case PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther:
+		return true
+	}
+	return false
+}
+
+// operands are the CLDR plural operands of a number: n is its absolute value,
+// i its integer digits and v the number of visible fraction digits.
+type operands struct {
+	n float64
+	i int64
+	v int
+}
+
+// pluralRules maps a base language to its cardinal rule. Languages without a
+// rule use the English one.
+var pluralRules = map[string]func(operands) string{
+	"en": oneIfIntegerOne,
+	"ca": oneIfIntegerOne,
+	"de": oneIfIntegerOne,
+	"it": oneIfIntegerOne,
+	"nl": oneIfIntegerOne,
+	"es": func(o operands) string {
+		if o.n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	},
+	"fr": oneIfZeroOrOne,
+	"pt": oneIfZeroOrOne,
+	"ja": onlyOther,
+	"zh": onlyOther,
+	"ko": onlyOther,
+	"ru": eastSlavic,
+	"uk": eastSlavic,
+	"pl": func(o operands) string {
+		if o.v != 0 {
+			return PluralOther
+		}
+		mod10, mod100 := o.i%10, o.i%100
+		switch {
+		case o.i == 1:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	},
+}
+
+// oneIfIntegerOne is the rule of English and Catalan: "1" is one, "1.0" is other.
+func oneIfIntegerOne(o operands) string {
+	if o.i == 1 && o.v == 0 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+// oneIfZeroOrOne is the rule of French and Portuguese: 0 and 1.5 are one.
+func oneIfZeroOrOne(o operands) string {
+	if o.i == 0 || o.i == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+// onlyOther is the rule of languages without plural forms.
+func onlyOther(operands) string {
+	return PluralOther
+}
+
+// eastSlavic is the rule of Russian and Ukrainian.
+func eastSlavic(o operands) string {
+	if o.v != 0 {
+		return PluralOther
+	}
+	mod10, mod100 := o.i%10, o.i%100
+	switch {
+	case mod10 == 1 && mod100 != 11:
+		return PluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return PluralFew
+	default:
+		return PluralMany
+	}
+}
+
+// PluralCategory returns the CLDR cardinal plural category of count, an
+// integer, float or numeric string, in locale. Non-numeric counts are "other".
+func PluralCategory(locale string, count any) string {
+	o, ok := toOperands(count)
+	if !ok {
+		return PluralOther
+	}
+	base, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	rule, ok := pluralRules[base]
+	if !ok {
+		rule = oneIfIntegerOne
+	}
+	return rule(o)
+}
+
+// toOperands converts count to its plural operands.
+func toOperands(count any) (operands, bool) {
+	var text string
+	switch c := count.(type) {
+	case int:
+		text = strconv.FormatInt(int64(c), 10)
+	case int32:
+		text = strconv.FormatInt(int64(c), 10)
+	case int64:
+		text = strconv.FormatInt(c, 10)
+	case uint:
+		text = strconv.FormatUint(uint64(c), 10)
+	case float64:
+		text = strconv.FormatFloat(c, 'f', -1, 64)
+	case float32:
+		text = strconv.FormatFloat(float64(c), 'f', -1, 32)
+	case string:
+		text = c
+	default:
+		return operands{}, false
+	}
+
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return operands{}, false
+	}
+	text = strings.TrimPrefix(text, "-")
+	_, fraction, _ := strings.Cut(text, ".")
+	return operands{n: math.Abs(n), i: int64(math.Abs(n)), v: len(fraction)}, true
+}
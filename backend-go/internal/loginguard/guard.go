@@ -0,0 +1,269 @@
+// Package loginguard limits password guessing: it tracks failed login attempts
+// per account and per client IP, slows retries down with exponential backoff
+// and locks the key temporarily after too many failures.
+package loginguard
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of keys tracked by the guard.
+const (
+	KindAccount = "account"
+	KindIP      = "ip"
+	// KindMFA tracks two-factor codes entered after the password, by user ID.
+	KindMFA = "mfa"
+)
+
+// Policy configures backoff and lockout for one kind of key.
+type Policy struct {
+	// MaxFailures is the number of consecutive failures that triggers a lockout (0 disables it).
+	MaxFailures int
+	// BaseDelay is the wait imposed after the first failure; it doubles with every failure (0 disables backoff).
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay.
+	MaxDelay time.Duration
+	// LockDuration is how long a key stays locked.
+	LockDuration time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+// DefaultAccountPolicy locks an account for 15 minutes after 5 failures.
+var DefaultAccountPolicy = Policy{
+	MaxFailures:  5,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	LockDuration: 15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// DefaultIPPolicy locks a client IP for 15 minutes after 50 failures, without backoff.
+var DefaultIPPolicy = Policy{
+	MaxFailures:  50,
+	LockDuration: 15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// DefaultMFAPolicy locks two-factor login of a user for 15 minutes after 5 wrong codes.
+var DefaultMFAPolicy = Policy{
+	MaxFailures:  5,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	LockDuration: 15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// Lockout describes a key that has just been locked.
+type Lockout struct {
+	Kind       string
+	Identifier string
+	IP         string
+	Failures   int
+	Until      time.Time
+}
+
+// LockedError is returned by Check while an account or IP must wait before retrying.
+type LockedError struct {
+	Kind       string
+	RetryAfter time.Duration
+	// Locked is true for a lockout, false for a backoff delay.
+	Locked bool
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("login_locked: %s retry after %s", e.Kind, e.RetryAfter)
+}
+
+// Guard applies the account and IP policies on top of a Store.
+type Guard struct {
+	Store   Store
+	Account Policy
+	IP      Policy
+	MFA     Policy
+	// OnLockout is called when a key becomes locked (optional).
+	OnLockout func(ctx context.Context, lockout Lockout)
+	Now       func() time.Time
+}
+
+// New creates a guard with the default policies.
+func New(store Store) *Guard {
+	return &Guard{Store: store, Account: DefaultAccountPolicy, IP: DefaultIPPolicy, MFA: DefaultMFAPolicy, Now: time.Now}
+}
+
+// target is one tracked key and the policy that applies to it.
+type target struct {
+	kind       string
+	identifier string
+	policy     Policy
+}
+
+func (g *Guard) targets(account, ip string) []target {
+	targets := make([]target, 0, 2)
+	if account != "" {
+		targets = append(targets, target{KindAccount, strings.ToLower(account), g.Account})
+	}
+	if ip != "" {
+		targets = append(targets, target{KindIP, ip, g.IP})
+	}
+	return targets
+}
+
+func (g *Guard) mfaTarget(userID int) target {
+	return target{KindMFA, strconv.Itoa(userID), g.MFA}
+}
+
+func (t target) key() string {
+	return t.kind + ":" + t.identifier
+}
+
+// Check returns a *LockedError when the account or the IP is locked or still in backoff.
+func (g *Guard) Check(ctx context.Context, account, ip string) error {
+	return g.check(ctx, g.targets(account, ip))
+}
+
+// CheckMFA returns a *LockedError while the user must wait before entering
+// another two-factor code.
+func (g *Guard) CheckMFA(ctx context.Context, userID int) error {
+	return g.check(ctx, []target{g.mfaTarget(userID)})
+}
+
+func (g *Guard) check(ctx context.Context, targets []target) error {
+	now := g.Now()
+	var result *LockedError
+
+	for _, t := range targets {
+		state, err := g.Store.Get(ctx, t.key())
+		if err != nil {
+			return err
+		}
+		if state.Failures == 0 || now.Sub(state.LastFailure) > t.policy.Window {
+			continue
+		}
+
+		var wait time.Duration
+		locked := false
+		if state.LockedUntil.After(now) {
+			wait, locked = state.LockedUntil.Sub(now), true
+		} else if next := state.LastFailure.Add(t.policy.delay(state.Failures)); next.After(now) {
+			wait = next.Sub(now)
+		}
+
+		if wait > 0 && (result == nil || wait > result.RetryAfter) {
+			result = &LockedError{Kind: t.kind, RetryAfter: wait, Locked: locked}
+		}
+	}
+
+	if result != nil {
+		return result
+	}
+	return nil
+}
+
+// Failure records a failed attempt for the account and the IP, locking any key
+// that reached its policy's MaxFailures.
+func (g *Guard) Failure(ctx context.Context, account, ip string) error {
+	return g.failure(ctx, g.targets(account, ip), ip)
+}
+
+// FailureMFA records a wrong two-factor code entered for the user from ip.
+func (g *Guard) FailureMFA(ctx context.Context, userID int, ip string) error {
+	return g.failure(ctx, []target{g.mfaTarget(userID)}, ip)
+}
+
+func (g *Guard) failure(ctx context.Context, targets []target, ip string) error {
+	now := g.Now()
+
+	for _, t := range targets {
+		previous, err := g.Store.Get(ctx, t.key())
+		if err != nil {
+			return err
+		}
+		if previous.Failures > 0 && now.Sub(previous.LastFailure) > t.policy.Window {
+			if err := g.Store.Reset(ctx, t.key()); err != nil {
+				return err
+			}
+		}
+
+		state, err := g.Store.RecordFailure(ctx, t.key(), now)
+		if err != nil {
+			return err
+		}
+
+		if t.policy.MaxFailures > 0 && state.Failures >= t.policy.MaxFailures && !state.LockedUntil.After(now) {
+			until := now.Add(t.policy.LockDuration)
+			if err := g.Store.Lock(ctx, t.key(), until); err != nil {
+				return err
+			}
+			if g.OnLockout != nil {
+				g.OnLockout(ctx, Lockout{Kind: t.kind, Identifier: t.identifier, IP: ip, Failures: state.Failures, Until: until})
+			}
+		}
+	}
+	return nil
+}
+
+// Success clears the failures of the account. IP counters only expire with
+// their window, so one valid account cannot reset an attacker's IP.
+func (g *Guard) Success(ctx context.Context, account string) error {
+	return g.Unlock(ctx, account)
+}
+
+// SuccessMFA clears the two-factor failures of the user.
+func (g *Guard) SuccessMFA(ctx context.Context, userID int) error {
+	return g.Store.Reset(ctx, g.mfaTarget(userID).key())
+}
+
+// UseChallenge counts an attempt on a one-time challenge, such as the token of
+// a two-factor login, and reports whether it may still be used: it accepts up
+// to attempts tries and none once SpendChallenge was called.
+func (g *Guard) UseChallenge(ctx context.Context, id string, attempts int) (bool, error) {
+	state, err := g.Store.RecordFailure(ctx, "challenge:"+id, g.Now())
+	if err != nil {
+		return false, err
+	}
+	return state.Failures <= attempts && state.LockedUntil.IsZero(), nil
+}
+
+// SpendChallenge stops a challenge from being used again. until is when the
+// challenge expires anyway.
+func (g *Guard) SpendChallenge(ctx context.Context, id string, until time.Time) error {
+	return g.Store.Lock(ctx, "challenge:"+id, until)
+}
+
+// Unlock clears failures and any lockout of the account.
+func (g *Guard) Unlock(ctx context.Context, account string) error {
+	return g.Store.Reset(ctx, target{kind: KindAccount, identifier: strings.ToLower(account)}.key())
+}
+
+// Prune forgets the counters that no policy remembers anymore: those whose
+// last failure is older than the longest Window and that are not locked.
+func (g *Guard) Prune(ctx context.Context) error {
+	window := g.Account.Window
+	for _, policy := range []Policy{g.IP, g.MFA} {
+		if policy.Window > window {
+			window = policy.Window
+		}
+	}
+	now := g.Now()
+	return g.Store.Prune(ctx, now.Add(-window), now)
+}
+
+// delay returns the backoff after the given number of consecutive failures.
+func (p Policy) delay(failures int) time.Duration {
+	if p.BaseDelay <= 0 || failures <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
@@ -0,0 +1,227 @@
+package loginguard_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/db/dbtest"
+	"task-manager/backend-go/internal/loginguard"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testClock is a controllable time source.
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+// setupSQLStore creates a SQL store on a database with the login_attempts table.
+func setupSQLStore(t *testing.T) *loginguard.SQLStore {
+	return loginguard.NewSQLStore(dbtest.Open(t))
+}
+
+// newGuard creates a guard with a small account policy and a fixed clock.
+func newGuard(store loginguard.Store, clock *testClock) *loginguard.Guard {
+	guard := loginguard.New(store)
+	guard.Now = clock.Now
+	guard.Account = loginguard.Policy{
+		MaxFailures:  3,
+		BaseDelay:    time.Second,
+		MaxDelay:     4 * time.Second,
+		LockDuration: 10 * time.Minute,
+		Window:       time.Hour,
+	}
+	guard.IP = loginguard.Policy{MaxFailures: 5, LockDuration: 10 * time.Minute, Window: time.Hour}
+	return guard
+}
+
+// stores runs a test against every Store implementation.
+func stores(t *testing.T, test func(t *testing.T, store loginguard.Store)) {
+	t.Run("memory", func(t *testing.T) { test(t, loginguard.NewMemoryStore()) })
+	t.Run("sql", func(t *testing.T) { test(t, setupSQLStore(t)) })
+}
+
+// TestGuard_BackoffAndLockout verifies exponential backoff followed by a lockout event.
+func TestGuard_BackoffAndLockout(t *testing.T) {
+	stores(t, func(t *testing.T, store loginguard.Store) {
+		ctx := context.Background()
+		clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+		guard := newGuard(store, clock)
+
+		var lockouts []loginguard.Lockout
+		guard.OnLockout = func(ctx context.Context, lockout loginguard.Lockout) { lockouts = append(lockouts, lockout) }
+
+		assert.NoError(t, guard.Check(ctx, "Thor", "10.0.0.1"))
+
+		// First failure: 1s backoff
+		assert.NoError(t, guard.Failure(ctx, "Thor", "10.0.0.1"))
+		err := guard.Check(ctx, "thor", "10.0.0.2")
+		locked, ok := err.(*loginguard.LockedError)
+		assert.True(t, ok)
+		assert.False(t, locked.Locked)
+		assert.Equal(t, time.Second, locked.RetryAfter)
+
+		// Second failure doubles the backoff
+		clock.now = clock.now.Add(time.Second)
+		assert.NoError(t, guard.Check(ctx, "thor", "10.0.0.1"))
+		assert.NoError(t, guard.Failure(ctx, "thor", "10.0.0.1"))
+		locked = guard.Check(ctx, "thor", "").(*loginguard.LockedError)
+		assert.Equal(t, 2*time.Second, locked.RetryAfter)
+
+		// Third failure locks the account
+		clock.now = clock.now.Add(2 * time.Second)
+		assert.NoError(t, guard.Failure(ctx, "thor", "10.0.0.1"))
+		locked = guard.Check(ctx, "thor", "").(*loginguard.LockedError)
+		assert.True(t, locked.Locked)
+		assert.Equal(t, loginguard.KindAccount, locked.Kind)
+		assert.Equal(t, 10*time.Minute, locked.RetryAfter)
+
+		assert.Len(t, lockouts, 1)
+		assert.Equal(t, "thor", lockouts[0].Identifier)
+		assert.Equal(t, "10.0.0.1", lockouts[0].IP)
+		assert.Equal(t, 3, lockouts[0].Failures)
+
+		// The lock expires on its own
+		clock.now = clock.now.Add(11 * time.Minute)
+		assert.NoError(t, guard.Check(ctx, "thor", ""))
+	})
+}
+
+// TestGuard_SuccessAndUnlock verifies that a successful login or an unlock clears the account.
+func TestGuard_SuccessAndUnlock(t *testing.T) {
+	stores(t, func(t *testing.T, store loginguard.Store) {
+		ctx := context.Background()
+		clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+		guard := newGuard(store, clock)
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, guard.Failure(ctx, "thor", ""))
+		}
+		assert.Error(t, guard.Check(ctx, "thor", ""))
+
+		assert.NoError(t, guard.Unlock(ctx, "Thor"))
+		assert.NoError(t, guard.Check(ctx, "thor", ""))
+
+		assert.NoError(t, guard.Failure(ctx, "thor", ""))
+		assert.NoError(t, guard.Success(ctx, "thor"))
+		assert.NoError(t, guard.Check(ctx, "thor", ""))
+	})
+}
+
+// TestGuard_IPLockout verifies that failures across accounts lock the client IP.
+func TestGuard_IPLockout(t *testing.T) {
+	stores(t, func(t *testing.T, store loginguard.Store) {
+		ctx := context.Background()
+		clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+		guard := newGuard(store, clock)
+
+		for _, account := range []string{"a", "b", "c", "d", "e"} {
+			assert.NoError(t, guard.Failure(ctx, account, "10.0.0.9"))
+		}
+
+		locked, ok := guard.Check(ctx, "fresh", "10.0.0.9").(*loginguard.LockedError)
+		assert.True(t, ok)
+		assert.Equal(t, loginguard.KindIP, locked.Kind)
+		assert.True(t, locked.Locked)
+
+		// A successful login does not reset the IP counter
+		assert.NoError(t, guard.Success(ctx, "fresh"))
+		assert.Error(t, guard.Check(ctx, "fresh", "10.0.0.9"))
+		assert.NoError(t, guard.Check(ctx, "fresh", "10.0.0.10"))
+	})
+}
+
+// TestSQLStore_SharedBetweenGuards verifies that replicas sharing a database see the same counters.
+func TestSQLStore_SharedBetweenGuards(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := setupSQLStore(t)
+	first, second := newGuard(store, clock), newGuard(loginguard.NewSQLStore(store.DB), clock)
+
+	assert.NoError(t, first.Failure(ctx, "thor", ""))
+	assert.NoError(t, second.Failure(ctx, "thor", ""))
+	assert.NoError(t, first.Failure(ctx, "thor", ""))
+
+	locked, ok := second.Check(ctx, "thor", "").(*loginguard.LockedError)
+	assert.True(t, ok)
+	assert.True(t, locked.Locked)
+}
+
+// TestGuard_MFA verifies that wrong two-factor codes lock the user and that challenges are spent.
+func TestGuard_MFA(t *testing.T) {
+	stores(t, func(t *testing.T, store loginguard.Store) {
+		ctx := context.Background()
+		clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+		guard := newGuard(store, clock)
+		guard.MFA = loginguard.Policy{MaxFailures: 2, LockDuration: 10 * time.Minute, Window: time.Hour}
+
+		assert.NoError(t, guard.FailureMFA(ctx, 7, "10.0.0.1"))
+		assert.NoError(t, guard.CheckMFA(ctx, 7))
+		assert.NoError(t, guard.FailureMFA(ctx, 7, "10.0.0.2"))
+		locked, ok := guard.CheckMFA(ctx, 7).(*loginguard.LockedError)
+		assert.True(t, ok)
+		assert.Equal(t, loginguard.KindMFA, locked.Kind)
+
+		// Other users and the password step are not affected
+		assert.NoError(t, guard.CheckMFA(ctx, 8))
+		assert.NoError(t, guard.Check(ctx, "7", "10.0.0.1"))
+		assert.NoError(t, guard.SuccessMFA(ctx, 7))
+		assert.NoError(t, guard.CheckMFA(ctx, 7))
+
+		for i := 0; i < 2; i++ {
+			ok, err := guard.UseChallenge(ctx, "a", 2)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		ok, err := guard.UseChallenge(ctx, "a", 2)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		ok, _ = guard.UseChallenge(ctx, "b", 2)
+		assert.True(t, ok)
+		assert.NoError(t, guard.SpendChallenge(ctx, "b", clock.now.Add(time.Minute)))
+		ok, _ = guard.UseChallenge(ctx, "b", 2)
+		assert.False(t, ok)
+	})
+}
+
+// TestGuard_Prune verifies that only forgotten, unlocked counters are deleted.
+func TestGuard_Prune(t *testing.T) {
+	stores(t, func(t *testing.T, store loginguard.Store) {
+		ctx := context.Background()
+		clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+		guard := newGuard(store, clock)
+		guard.Account.LockDuration = 2 * time.Hour
+
+		// thor gets locked, loki only fails once
+		for i := 0; i < guard.Account.MaxFailures; i++ {
+			assert.NoError(t, guard.Failure(ctx, "thor", ""))
+		}
+		assert.NoError(t, guard.Failure(ctx, "loki", ""))
+
+		// Within the window nothing is pruned
+		clock.now = clock.now.Add(30 * time.Minute)
+		assert.NoError(t, guard.Prune(ctx))
+		state, err := store.Get(ctx, "account:loki")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, state.Failures)
+
+		// Past the window the unlocked counter goes, the lockout stays
+		clock.now = clock.now.Add(time.Hour)
+		assert.NoError(t, guard.Prune(ctx))
+		state, err = store.Get(ctx, "account:loki")
+		assert.NoError(t, err)
+		assert.Zero(t, state.Failures)
+		state, err = store.Get(ctx, "account:thor")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, state.Failures)
+
+		// Once the lockout is over it goes too
+		clock.now = clock.now.Add(time.Hour)
+		assert.NoError(t, guard.Prune(ctx))
+		state, err = store.Get(ctx, "account:thor")
+		assert.NoError(t, err)
+		assert.Zero(t, state.Failures)
+	})
+}
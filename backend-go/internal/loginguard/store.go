@@ -0,0 +1,156 @@
+package loginguard
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// State holds the failure counter of one key.
+type State struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store persists failure counters. The SQL implementation lets several
+// replicas share state; the in-memory one is for single instances and tests.
+type Store interface {
+	Get(ctx context.Context, key string) (State, error)
+	// RecordFailure atomically increments the failure count and returns the new state.
+	RecordFailure(ctx context.Context, key string, at time.Time) (State, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	// Prune deletes the counters whose last failure is before olderThan and
+	// that are not locked at now.
+	Prune(ctx context.Context, olderThan, now time.Time) error
+}
+
+// MemoryStore keeps counters in process memory.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[key], nil
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, at time.Time) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.states[key]
+	state.Failures++
+	state.LastFailure = at
+	s.states[key] = state
+	return state, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.states[key]
+	state.LockedUntil = until
+	s.states[key] = state
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, olderThan, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, state := range s.states {
+		if state.LastFailure.Before(olderThan) && !state.LockedUntil.After(now) {
+			delete(s.states, key)
+		}
+	}
+	return nil
+}
+
+// SQLStore keeps counters in the login_attempts table.
+type SQLStore struct {
+	DB *sql.DB
+}
+
+// NewSQLStore creates a store backed by the database.
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{DB: db}
+}
+
+func (s *SQLStore) Get(ctx context.Context, key string) (State, error) {
+	var state State
+	var lastFailure, lockedUntil sql.NullTime
+
+	err := s.DB.QueryRowContext(ctx,
+		"SELECT failures, last_failure, locked_until FROM login_attempts WHERE attempt_key = ?", key,
+	).Scan(&state.Failures, &lastFailure, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return State{}, nil
+	} else if err != nil {
+		return State{}, err
+	}
+
+	state.LastFailure = lastFailure.Time
+	state.LockedUntil = lockedUntil.Time
+	return state, nil
+}
+
+// RecordFailure increments the counter in place, inserting the row on the first failure.
+func (s *SQLStore) RecordFailure(ctx context.Context, key string, at time.Time) (State, error) {
+	res, err := s.DB.ExecContext(ctx,
+		"UPDATE login_attempts SET failures = failures + 1, last_failure = ? WHERE attempt_key = ?", at.UTC(), key,
+	)
+	if err != nil {
+		return State{}, err
+	}
+
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		_, err := s.DB.ExecContext(ctx,
+			"INSERT INTO login_attempts (attempt_key, failures, last_failure) VALUES (?, 1, ?)", key, at.UTC(),
+		)
+		if err != nil {
+			// Another replica inserted the row first
+			if _, retryErr := s.DB.ExecContext(ctx,
+				"UPDATE login_attempts SET failures = failures + 1, last_failure = ? WHERE attempt_key = ?", at.UTC(), key,
+			); retryErr != nil {
+				return State{}, err
+			}
+		}
+	}
+
+	return s.Get(ctx, key)
+}
+
+func (s *SQLStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ?", until.UTC(), key)
+	return err
+}
+
+func (s *SQLStore) Reset(ctx context.Context, key string) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM login_attempts WHERE attempt_key = ?", key)
+	return err
+}
+
+func (s *SQLStore) Prune(ctx context.Context, olderThan, now time.Time) error {
+	_, err := s.DB.ExecContext(ctx,
+		"DELETE FROM login_attempts WHERE last_failure < ? AND (locked_until IS NULL OR locked_until <= ?)", olderThan.UTC(), now.UTC(),
+	)
+	return err
+}
@@ -0,0 +1,215 @@
+package mail_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/mail"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMessage returns a message with a non-ASCII subject and both body parts.
+func testMessage() *mail.Message {
+	return &mail.Message{
+		From:    "Task Manager <noreply@example.com>",
+		To:      []string{"thor@example.com"},
+		Subject: "パスワードの再設定",
+		Text:    "Hello\nhttps://example.com/reset?token=abc",
+		HTML:    `<p>Hello</p><a href="https://example.com/reset?token=abc">reset</a>`,
+		Date:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// TestMessageBytes verifies the MIME headers and both alternative parts
+func TestMessageBytes(t *testing.T) {
+	data, err := testMessage().Bytes()
+	assert.NoError(t, err)
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, "Task Manager <noreply@example.com>", parsed.Header.Get("From"))
+	assert.Equal(t, "thor@example.com", parsed.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "パスワードの再設定", subject)
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 +0000", parsed.Header.Get("Date"))
+	assert.True(t, strings.HasSuffix(parsed.Header.Get("Message-ID"), "@example.com>"))
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		body, err := io.ReadAll(part)
+		assert.NoError(t, err)
+		bodies = append(bodies, part.Header.Get("Content-Type")+"|"+string(body))
+	}
+	assert.Equal(t, []string{
+		"text/plain; charset=utf-8|Hello\r\nhttps://example.com/reset?token=abc",
+		`text/html; charset=utf-8|<p>Hello</p><a href="https://example.com/reset?token=abc">reset</a>`,
+	}, bodies)
+}
+
+// TestMessageBytes_TextOnly verifies that messages without HTML are single part
+func TestMessageBytes_TextOnly(t *testing.T) {
+	msg := testMessage()
+	msg.HTML = ""
+	data, err := msg.Bytes()
+	assert.NoError(t, err)
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", parsed.Header.Get("Content-Type"))
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello\r\nhttps://example.com/reset?token=abc", string(body))
+}
+
+// TestMessageBytes_Invalid verifies that header injection and missing addresses are rejected
+func TestMessageBytes_Invalid(t *testing.T) {
+	for _, mutate := range []func(*mail.Message){
+		func(m *mail.Message) { m.Subject = "Hi\r\nBcc: victim@example.com" },
+		func(m *mail.Message) { m.To = []string{"thor@example.com\r\nBcc: victim@example.com"} },
+		func(m *mail.Message) { m.To = nil },
+		func(m *mail.Message) { m.From = "" },
+	} {
+		msg := testMessage()
+		mutate(msg)
+		_, err := msg.Bytes()
+		assert.ErrorIs(t, err, mail.ErrInvalidMessage)
+	}
+}
+
+// TestFileMailer verifies that messages are delivered to the maildir new folder
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer, err := mail.NewFileMailer(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mailer.Send(context.Background(), testMessage()))
+	assert.NoError(t, mailer.Send(context.Background(), testMessage()))
+
+	delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+	assert.NoError(t, err)
+	assert.Len(t, delivered, 2)
+	pending, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+// TestMemoryMailer verifies that messages are captured and can be reset
+func TestMemoryMailer(t *testing.T) {
+	mailer := mail.NewMemoryMailer()
+	assert.NoError(t, mailer.Send(context.Background(), testMessage()))
+	assert.Error(t, mailer.Send(context.Background(), &mail.Message{From: "noreply@example.com"}))
+
+	sent := mailer.Messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, []string{"thor@example.com"}, sent[0].To)
+
+	mailer.Reset()
+	assert.Empty(t, mailer.Messages())
+}
+
+// fakeSMTPServer accepts one SMTP session and returns the commands and message data it received.
+func fakeSMTPServer(t *testing.T) (int, <-chan []string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	received := make(chan []string, 1)
+
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var commands []string
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			commands = append(commands, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(line, "AUTH"):
+				reply("235 Authenticated")
+			case line == "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					dataLine, err := r.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				commands = append(commands, data.String())
+				reply("250 Queued")
+			case line == "QUIT":
+				reply("221 Bye")
+				received <- commands
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+		received <- commands
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port, received
+}
+
+// TestSMTPMailer verifies the SMTP conversation with authentication
+func TestSMTPMailer(t *testing.T) {
+	port, received := fakeSMTPServer(t)
+	mailer, err := mail.NewSMTPMailer(mail.SMTPConfig{
+		Host: "localhost", Port: port, Username: "noreply@example.com", Password: "secret", TLS: mail.TLSNone,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, mailer.Send(ctx, testMessage()))
+
+	commands := <-received
+	assert.Contains(t, commands, "MAIL FROM:<noreply@example.com>")
+	assert.Contains(t, commands, "RCPT TO:<thor@example.com>")
+	assert.True(t, strings.HasPrefix(commands[1], "AUTH PLAIN"), commands[1])
+	data := commands[len(commands)-2]
+	assert.Contains(t, data, "Subject: =?utf-8?q?")
+	assert.Contains(t, data, "MIME-Version: 1.0\r\n")
+
+	_, err = mail.NewSMTPMailer(mail.SMTPConfig{Host: "localhost", Port: 25, TLS: "ssl3"})
+	assert.Error(t, err)
+	_, err = mail.NewSMTPMailer(mail.SMTPConfig{Host: "", Port: 25})
+	assert.Error(t, err)
+}
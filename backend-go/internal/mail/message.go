@@ -0,0 +1,155 @@
+// Package mail builds MIME email messages and delivers them through
+// interchangeable backends: SMTP, a maildir on disk, the console or memory.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrInvalidMessage is returned for messages without sender or recipients, or
+// with line breaks in a header.
+var ErrInvalidMessage = errors.New("mail: invalid message")
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Message is an email with a plain text body and an optional HTML alternative.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	// Date defaults to the time the message is encoded.
+	Date time.Time
+	// ID is the local part of the Message-ID, kept across retries so that
+	// receivers can drop duplicates; random when empty.
+	ID string
+}
+
+// validate checks the addresses and rejects header injection.
+func (m *Message) validate() error {
+	if len(m.To) == 0 || strings.ContainsAny(m.Subject, "\r\n") || strings.ContainsAny(m.ID, "\r\n<>@ ") {
+		return ErrInvalidMessage
+	}
+	for _, address := range append([]string{m.From}, m.To...) {
+		if strings.ContainsAny(address, "\r\n") {
+			return ErrInvalidMessage
+		}
+		if _, err := netmail.ParseAddress(address); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+		}
+	}
+	return nil
+}
+
+// envelope returns the bare sender and recipient addresses for the SMTP envelope.
+func (m *Message) envelope() (string, []string, error) {
+	if err := m.validate(); err != nil {
+		return "", nil, err
+	}
+	from, _ := netmail.ParseAddress(m.From)
+	to := make([]string, len(m.To))
+	for i, address := range m.To {
+		parsed, _ := netmail.ParseAddress(address)
+		to[i] = parsed.Address
+	}
+	return from.Address, to, nil
+}
+
+// Bytes encodes the message as MIME: quoted-printable UTF-8 text, in a
+// multipart/alternative body when there is an HTML part.
+func (m *Message) Bytes() ([]byte, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	from, _ := netmail.ParseAddress(m.From)
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID(m.ID, from.Address))
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", `multipart/alternative; boundary="`+parts.Boundary()+`"`)
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable writes body with CRLF line endings in quoted-printable encoding.
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns the Message-ID for id in the domain of the sender, with a
+// random id when empty.
+func messageID(id, from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	if id == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+	return "<" + id + "@" + domain + ">"
+}
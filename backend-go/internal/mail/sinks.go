@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes each message to a maildir, for development. Any mail
+// client able to open a maildir can read them.
+type FileMailer struct {
+	Dir string
+}
+
+// deliveries numbers the messages written by this process.
+var deliveries atomic.Int64
+
+// NewFileMailer creates the maildir layout (tmp, new, cur) under dir.
+func NewFileMailer(dir string) (*FileMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+// Send writes msg to tmp and moves it to new once complete, as maildir requires.
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.P%dQ%d.%s", time.Now().Unix(), os.Getpid(), deliveries.Add(1), host)
+	tmp := filepath.Join(m.Dir, "tmp", name)
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.Dir, "new", name))
+}
+
+// ConsoleMailer prints messages instead of sending them.
+type ConsoleMailer struct {
+	W io.Writer
+}
+
+// Send writes the encoded message to W.
+func (m *ConsoleMailer) Send(ctx context.Context, msg *Message) error {
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(m.W, "----- email -----\n%s\n-----------------\n", data)
+	return err
+}
+
+// MemoryMailer keeps the messages it is given, for tests.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryMailer returns an empty capture mailer.
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send records a copy of msg after checking that it can be encoded.
+func (m *MemoryMailer) Send(ctx context.Context, msg *Message) error {
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, *msg)
+	return nil
+}
+
+// Messages returns the messages sent so far.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Reset forgets the captured messages.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
@@ -0,0 +1,121 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// TLS modes of an SMTP connection.
+const (
+	// TLSStartTLS upgrades a plain connection with STARTTLS (usually port 587).
+	TLSStartTLS = "starttls"
+	// TLSImplicit connects over TLS from the start (usually port 465).
+	TLSImplicit = "tls"
+	// TLSNone never encrypts; only meant for local relays.
+	TLSNone = "none"
+)
+
+// dialTimeout bounds connecting to the SMTP server when the context has no deadline.
+const dialTimeout = 10 * time.Second
+
+// SMTPConfig describes how to reach the SMTP server.
+type SMTPConfig struct {
+	Host string
+	Port int
+	// Username and Password enable PLAIN authentication when Username is set.
+	Username string
+	Password string
+	// TLS is TLSStartTLS (default), TLSImplicit or TLSNone.
+	TLS string
+}
+
+// SMTPMailer sends messages through an SMTP server, one connection per message.
+type SMTPMailer struct {
+	Config SMTPConfig
+}
+
+// NewSMTPMailer returns a mailer for the given server.
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = TLSStartTLS
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("mail: unknown SMTP TLS mode %q", cfg.TLS)
+	}
+	if cfg.Host == "" || cfg.Port <= 0 {
+		return nil, fmt.Errorf("mail: SMTP host and port are required")
+	}
+	return &SMTPMailer{Config: cfg}, nil
+}
+
+// Send delivers msg to all its recipients.
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	from, to, err := msg.envelope()
+	if err != nil {
+		return err
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	cfg := m.Config
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	if cfg.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: cfg.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if cfg.TLS == TLSStartTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,168 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+// layoutFile is shared by every template; the other files are one email each.
+const layoutFile = "layout.tmpl"
+
+// Translator returns the text of a translation key in the recipient's locale.
+type Translator func(key string) string
+
+// Templates renders localized emails. Each email is a file defining the
+// "subject", "text_body" and "html_body" blocks, wrapped by the shared layout.
+// Inside templates, {{t "key"}} translates a key and {{args "K" v ...}} builds
+// a map to pass to nested templates.
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// placeholderFuncs lets the templates parse; Render binds the real translator.
+var placeholderFuncs = map[string]any{
+	"t":    func(key string) string { return key },
+	"args": args,
+}
+
+// LoadTemplates parses layout.tmpl and every other *.tmpl file of fsys.
+func LoadTemplates(fsys fs.FS) (*Templates, error) {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{text: map[string]*texttemplate.Template{}, html: map[string]*htmltemplate.Template{}}
+	for _, file := range files {
+		if file == layoutFile {
+			continue
+		}
+		name := strings.TrimSuffix(file, path.Ext(file))
+
+		text, err := texttemplate.New(name).Funcs(placeholderFuncs).ParseFS(fsys, layoutFile, file)
+		if err != nil {
+			return nil, err
+		}
+		html, err := htmltemplate.New(name).Funcs(placeholderFuncs).ParseFS(fsys, layoutFile, file)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range []string{"subject", "text_body", "html_body"} {
+			if text.Lookup(block) == nil {
+				return nil, fmt.Errorf("mail: template %s does not define %q", file, block)
+			}
+		}
+		t.text[name], t.html[name] = text, html
+	}
+	return t, nil
+}
+
+// EmbeddedTemplates returns the templates shipped with the binary.
+func EmbeddedTemplates() (*Templates, error) {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+	return LoadTemplates(sub)
+}
+
+// Names returns the available templates, sorted.
+func (t *Templates) Names() []string {
+	names := make([]string, 0, len(t.text))
+	for name := range t.text {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render builds the subject and both bodies of the named email with translate
+// and data. The returned message has no From or To yet.
+func (t *Templates) Render(name string, translate Translator, data any) (*Message, error) {
+	text, ok := t.text[name]
+	if !ok {
+		return nil, fmt.Errorf("mail: unknown template %q", name)
+	}
+	funcs := map[string]any{"t": func(key string) string { return translate(key) }}
+
+	// Clones keep the parsed templates free of any translator
+	textClone, err := text.Clone()
+	if err != nil {
+		return nil, err
+	}
+	textClone.Funcs(funcs)
+	htmlClone, err := t.html[name].Clone()
+	if err != nil {
+		return nil, err
+	}
+	htmlClone.Funcs(funcs)
+
+	var subject, body, html bytes.Buffer
+	if err := textClone.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := textClone.ExecuteTemplate(&body, "text", data); err != nil {
+		return nil, err
+	}
+	if err := htmlClone.ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// args builds a map from alternating keys and values.
+func args(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("mail: args needs key and value pairs")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("mail: args key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// SampleData returns example data for the named template, used by previews and tests.
+func SampleData(name string) map[string]any {
+	tasks := []map[string]string{
+		{"Title": "Prepare the sprint review", "Description": "Slides and demo"},
+		{"Title": "Renew the TLS certificate", "Description": ""},
+	}
+	switch name {
+	case "change_email_notice":
+		return map[string]any{"NewEmail": "new.address@example.com"}
+	case "task_reminder":
+		return map[string]any{"Tasks": tasks, "URL": "https://example.com/tasks"}
+	case "digest":
+		return map[string]any{
+			"DueToday":           tasks[:1],
+			"Overdue":            []map[string]string{{"Title": "Renew the TLS certificate", "DueDate": "2026-10-12"}},
+			"CompletedYesterday": []map[string]string{{"Title": "Write the release notes"}},
+			"URL":                "https://example.com/tasks",
+		}
+	case "invitation":
+		return map[string]any{"InviterName": "Thor Odinson", "URL": "https://example.com/invitation?token=sample"}
+	default:
+		return map[string]any{"URL": "https://example.com/" + name + "?token=sample"}
+	}
+}
@@ -0,0 +1,76 @@
+package mail_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"task-manager/backend-go/internal/mail"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bracket marks translated keys so tests can spot them in the output.
+func bracket(key string) string { return "[" + key + "]" }
+
+// TestEmbeddedTemplates verifies that every shipped template renders with its sample data
+func TestEmbeddedTemplates(t *testing.T) {
+	templates, err := mail.EmbeddedTemplates()
+	assert.NoError(t, err)
+	assert.Contains(t, templates.Names(), "password_reset")
+	assert.Contains(t, templates.Names(), "digest")
+
+	for _, name := range templates.Names() {
+		msg, err := templates.Render(name, bracket, mail.SampleData(name))
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.True(t, strings.HasPrefix(msg.Subject, "["), name)
+		assert.NotContains(t, msg.Subject, "\n", name)
+		// Both bodies carry the shared layout footer
+		assert.Contains(t, msg.Text, "[forgot_email_team]", name)
+		assert.Contains(t, msg.HTML, "<!DOCTYPE html>", name)
+		assert.Contains(t, msg.HTML, "[email.footer.automated]", name)
+	}
+}
+
+// TestRender_EscapesHTML verifies that data is escaped in the HTML part only
+func TestRender_EscapesHTML(t *testing.T) {
+	templates, err := mail.EmbeddedTemplates()
+	assert.NoError(t, err)
+
+	msg, err := templates.Render("invitation", bracket, map[string]any{
+		"InviterName": "<script>alert(1)</script>",
+		"URL":         "javascript:alert(1)",
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, msg.Text, "<script>alert(1)</script>")
+	assert.NotContains(t, msg.HTML, "<script>")
+	assert.NotContains(t, msg.HTML, `href="javascript:`)
+}
+
+// TestLoadTemplates verifies the layout wrapping and the required blocks
+func TestLoadTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.tmpl": {Data: []byte(`{{define "text"}}{{template "text_body" .}} -- {{t "sign"}}{{end}}` +
+			`{{define "html"}}<div>{{template "html_body" .}}</div>{{end}}`)},
+		"hello.tmpl": {Data: []byte(`{{define "subject"}}{{t "hi"}} {{.Name}}{{end}}` +
+			`{{define "text_body"}}{{t "hi"}} {{.Name}}{{end}}{{define "html_body"}}<b>{{.Name}}</b>{{end}}`)},
+	}
+	templates, err := mail.LoadTemplates(fsys)
+	assert.NoError(t, err)
+
+	msg, err := templates.Render("hello", func(key string) string { return map[string]string{"hi": "Hola", "sign": "Equipo"}[key] },
+		map[string]any{"Name": "Thor & Loki"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hola Thor & Loki", msg.Subject)
+	assert.Equal(t, "Hola Thor & Loki -- Equipo\n", msg.Text)
+	assert.Equal(t, "<div><b>Thor &amp; Loki</b></div>", msg.HTML)
+
+	_, err = templates.Render("missing", bracket, nil)
+	assert.Error(t, err)
+
+	fsys["broken.tmpl"] = &fstest.MapFile{Data: []byte(`{{define "subject"}}x{{end}}`)}
+	_, err = mail.LoadTemplates(fsys)
+	assert.Error(t, err)
+}
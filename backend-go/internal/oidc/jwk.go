@@ -0,0 +1,93 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+)
+
+// jsonWebKeySet is a provider JWKS document.
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jsonWebKey holds the public key members of RSA, EC and OKP keys.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// errUnsupportedKey is returned for key types that cannot verify ID tokens.
+var errUnsupportedKey = errors.New("unsupported key")
+
+// publicKey converts the JWK into a Go public key.
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, errUnsupportedKey
+	}
+
+	switch {
+	case k.KeyType == "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case k.KeyType == "EC" && k.Curve == "P-256":
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+
+	case k.KeyType == "OKP" && k.Curve == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, errUnsupportedKey
+}
+
+// algorithmMatchesKey pins the token algorithm to the type of the provider key.
+func algorithmMatchesKey(alg string, key interface{}) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256"
+	case *ecdsa.PublicKey:
+		return alg == "ES256"
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// decodeBigInt decodes a base64url big-endian integer.
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
@@ -0,0 +1,257 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE
+// against a standard provider: discovery, code exchange and ID token verification.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"task-manager/backend-go/internal/i18n"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyRefreshInterval limits how often the provider JWKS is fetched again for an unknown kid.
+const keyRefreshInterval = time.Minute
+
+// Config describes the OAuth client registered at the provider.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Claims holds the ID token claims used to identify and provision a user.
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// Provider is a discovered OpenID Connect provider.
+type Provider struct {
+	config                Config
+	client                *http.Client
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	JWKSURI               string
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// discoveryDocument is the subset of /.well-known/openid-configuration in use.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Discover reads the provider metadata from the issuer's discovery document.
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	var doc discoveryDocument
+	if err := getJSON(ctx, client, wellKnown, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", i18n.T("error.oidc.discovery"), err)
+	}
+	if doc.Issuer != config.Issuer {
+		return nil, fmt.Errorf("%s: %s", i18n.T("error.oidc.issuer_mismatch"), doc.Issuer)
+	}
+
+	return &Provider{
+		config:                config,
+		client:                client,
+		AuthorizationEndpoint: doc.AuthorizationEndpoint,
+		TokenEndpoint:         doc.TokenEndpoint,
+		JWKSURI:               doc.JWKSURI,
+		keys:                  map[string]interface{}{},
+	}, nil
+}
+
+// Issuer returns the issuer identifier the provider was discovered from.
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// AuthCodeURL builds the authorization request URL with a S256 PKCE challenge.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange redeems the authorization code and returns the verified ID token claims.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return nil, fmt.Errorf("%s: %s %s", i18n.T("error.oidc.token_exchange"), resp.Status, body.Error)
+	}
+
+	return p.VerifyIDToken(ctx, body.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiration and nonce of an ID token.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+
+	claims := &Claims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := p.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if !algorithmMatchesKey(token.Method.Alg(), key) {
+			return nil, errors.New(i18n.T("error.jwt.unexpected_algorithm"))
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", i18n.T("error.oidc.invalid_id_token"), err)
+	}
+
+	if claims.Subject == "" || claims.Nonce != nonce {
+		return nil, errors.New(i18n.T("error.oidc.invalid_id_token"))
+	}
+	return claims, nil
+}
+
+// key returns the provider key with the given kid, refreshing the JWKS when it is unknown.
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	fetched := p.keysFetched
+	p.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if time.Since(fetched) < keyRefreshInterval {
+		return nil, errors.New(i18n.T("error.jwt.unknown_key"))
+	}
+
+	var set jsonWebKeySet
+	if err := getJSON(ctx, p.client, p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if public, err := jwk.publicKey(); err == nil {
+			keys[jwk.KeyID] = public
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = time.Now()
+	p.mu.Unlock()
+
+	key, ok = keys[kid]
+	if !ok {
+		return nil, errors.New(i18n.T("error.jwt.unknown_key"))
+	}
+	return key, nil
+}
+
+// RandomString returns a URL-safe random string, used for state, nonce and PKCE verifiers.
+func RandomString() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge from a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// getJSON fetches url and decodes the JSON response into v.
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,84 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"task-manager/backend-go/internal/oidc"
+	"task-manager/backend-go/internal/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// noRedirectClient stops at the provider redirect so the test can read the code.
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// authorize performs the authorization request and returns the code and state from the redirect.
+func authorize(t *testing.T, authURL string) (string, string) {
+	resp, err := noRedirectClient.Get(authURL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+// TestAuthorizationCodeFlowWithPKCE runs the full flow against the stand-in provider
+func TestAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	server := oidctest.NewServer("task-manager", "s3cret")
+	defer server.Close()
+	server.SetUser(oidctest.User{Subject: "abc-123", Email: "thor@example.com", EmailVerified: true, GivenName: "Thor"})
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "task-manager",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/auth/oidc/callback",
+	}, nil)
+	assert.NoError(t, err)
+
+	verifier, _ := oidc.RandomString()
+	nonce, _ := oidc.RandomString()
+	code, state := authorize(t, provider.AuthCodeURL("state-1", nonce, verifier))
+	assert.Equal(t, "state-1", state)
+
+	claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc-123", claims.Subject)
+	assert.Equal(t, "thor@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	// Codes are single use
+	_, err = provider.Exchange(context.Background(), code, verifier, nonce)
+	assert.Error(t, err)
+}
+
+// TestExchange_RejectsWrongVerifierAndNonce ensures PKCE and nonce are enforced
+func TestExchange_RejectsWrongVerifierAndNonce(t *testing.T) {
+	server := oidctest.NewServer("task-manager", "s3cret")
+	defer server.Close()
+	server.SetUser(oidctest.User{Subject: "abc-123"})
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "task-manager",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/auth/oidc/callback",
+	}, nil)
+	assert.NoError(t, err)
+
+	verifier, _ := oidc.RandomString()
+	code, _ := authorize(t, provider.AuthCodeURL("state", "nonce-1", verifier))
+	_, err = provider.Exchange(context.Background(), code, "wrong-verifier", "nonce-1")
+	assert.Error(t, err)
+
+	code, _ = authorize(t, provider.AuthCodeURL("state", "nonce-1", verifier))
+	_, err = provider.Exchange(context.Background(), code, verifier, "other-nonce")
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+// Package outbox stores outgoing emails in the database, in the same
+// transaction as the change that triggers them, and delivers them from a
+// background worker with retries.
+package outbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"task-manager/backend-go/internal/mail"
+	"time"
+)
+
+// Delivery states of an outbox entry.
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	// StatusDead marks entries that failed MaxAttempts times; only a retry sends them again.
+	StatusDead = "dead"
+	// StatusFailed is not stored: List uses it for dead entries and pending ones that already failed.
+	StatusFailed = "failed"
+)
+
+var (
+	// ErrNotFound is returned when retrying an entry that does not exist or was already sent.
+	ErrNotFound = errors.New("outbox_entry_not_found")
+	// ErrInvalidStatus is returned by List for an unknown status filter.
+	ErrInvalidStatus = errors.New("invalid_outbox_status")
+)
+
+// Execer runs statements on a *sql.DB or inside a *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Entry is an outbox row without the message bodies.
+type Entry struct {
+	ID            int64      `json:"id"`
+	Key           string     `json:"idempotency_key"`
+	Recipients    []string   `json:"recipients"`
+	Subject       string     `json:"subject"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// Enqueue stores msg for delivery. key identifies the email: enqueueing the
+// same key again, for instance when a request is replayed, does nothing. Pass
+// a *sql.Tx to send the email only if the surrounding change commits.
+func Enqueue(ctx context.Context, db Execer, key string, msg *mail.Message) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM email_outbox WHERE idempotency_key = ?)", key).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	// Encoding now rejects invalid messages before they reach the worker
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO email_outbox (idempotency_key, sender, recipients, subject, text_body, html_body, status, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?)`,
+		key, msg.From, strings.Join(msg.To, "\n"), msg.Subject, msg.Text, msg.HTML, StatusPending, now, now,
+	)
+	return err
+}
+
+// messageID derives a stable Message-ID local part from the idempotency key.
+func messageID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "outbox." + hex.EncodeToString(sum[:16])
+}
+
+// List returns up to limit entries with the given status, newest first.
+// StatusFailed selects dead entries and pending ones waiting for a retry.
+func List(ctx context.Context, db *sql.DB, status string, limit int) ([]Entry, error) {
+	query := `SELECT id, idempotency_key, recipients, subject, status, attempts, next_attempt_at, last_error, created_at, sent_at
+		FROM email_outbox WHERE `
+	args := []any{}
+	switch status {
+	case StatusFailed:
+		query += "status = ? OR (status = ? AND attempts > 0)"
+		args = append(args, StatusDead, StatusPending)
+	case StatusPending, StatusSending, StatusSent, StatusDead:
+		query += "status = ?"
+		args = append(args, status)
+	default:
+		return nil, ErrInvalidStatus
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var recipients string
+		var lastError sql.NullString
+		var sentAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.Key, &recipients, &e.Subject, &e.Status, &e.Attempts,
+			&e.NextAttemptAt, &lastError, &e.CreatedAt, &sentAt); err != nil {
+			return nil, err
+		}
+		e.Recipients = strings.Split(recipients, "\n")
+		e.LastError = lastError.String
+		if sentAt.Valid {
+			e.SentAt = &sentAt.Time
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Retry schedules a dead or pending entry for immediate delivery with a fresh
+// attempt budget.
+func Retry(ctx context.Context, db *sql.DB, id int64) error {
+	res, err := db.ExecContext(ctx,
+		"UPDATE email_outbox SET status = ?, attempts = 0, next_attempt_at = ?, last_error = NULL WHERE id = ? AND status IN (?, ?)",
+		StatusPending, time.Now().UTC(), id, StatusDead, StatusPending,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
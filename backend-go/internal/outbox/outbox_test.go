@@ -0,0 +1,170 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/db/dbtest"
+	"task-manager/backend-go/internal/mail"
+	"task-manager/backend-go/internal/outbox"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupDB creates a database with the outbox table.
+func setupDB(t *testing.T) *sql.DB {
+	return dbtest.Open(t)
+}
+
+// flakyMailer fails while down is set and records the messages it accepts.
+type flakyMailer struct {
+	mu   sync.Mutex
+	down bool
+	sent []mail.Message
+}
+
+func (m *flakyMailer) Send(ctx context.Context, msg *mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return errors.New("connection refused")
+	}
+	m.sent = append(m.sent, *msg)
+	return nil
+}
+
+func testMessage() *mail.Message {
+	return &mail.Message{
+		From:    "noreply@example.com",
+		To:      []string{"thor@example.com"},
+		Subject: "Reset your password",
+		Text:    "https://example.com/reset?token=secret",
+		HTML:    `<a href="https://example.com/reset?token=secret">reset</a>`,
+	}
+}
+
+// TestEnqueue verifies idempotency keys and that enqueueing follows the transaction
+func TestEnqueue(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	assert.NoError(t, outbox.Enqueue(ctx, db, "reset:1", testMessage()))
+	assert.NoError(t, outbox.Enqueue(ctx, db, "reset:1", testMessage()))
+
+	tx, err := db.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Enqueue(ctx, tx, "reset:2", testMessage()))
+	assert.NoError(t, tx.Rollback())
+
+	invalid := testMessage()
+	invalid.To = nil
+	assert.ErrorIs(t, outbox.Enqueue(ctx, db, "reset:3", invalid), mail.ErrInvalidMessage)
+
+	var count int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM email_outbox").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+// TestWorker_RetriesAndDeadLetters verifies backoff, the dead state and a manual retry
+func TestWorker_RetriesAndDeadLetters(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+	mailer := &flakyMailer{down: true}
+	// Enqueue uses the real clock, so the test clock starts just after it
+	now := time.Now().Add(time.Second)
+
+	worker := outbox.NewWorker(db, mailer)
+	worker.MaxAttempts = 3
+	worker.BaseDelay = time.Minute
+	worker.Now = func() time.Time { return now }
+
+	assert.NoError(t, outbox.Enqueue(ctx, db, "reset:1", testMessage()))
+
+	// First failure: retried after BaseDelay, not before
+	sent, err := worker.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	now = now.Add(59 * time.Second)
+	sent, _ = worker.RunOnce(ctx)
+	assert.Equal(t, 0, sent)
+
+	failed, err := outbox.List(ctx, db, outbox.StatusFailed, 10)
+	assert.NoError(t, err)
+	assert.Len(t, failed, 1)
+	assert.Equal(t, 1, failed[0].Attempts)
+	assert.Equal(t, "connection refused", failed[0].LastError)
+
+	// Second failure doubles the delay, the third one is final
+	now = now.Add(time.Second)
+	worker.RunOnce(ctx)
+	now = now.Add(2 * time.Minute)
+	worker.RunOnce(ctx)
+
+	dead, err := outbox.List(ctx, db, outbox.StatusDead, 10)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, 3, dead[0].Attempts)
+	now = now.Add(24 * time.Hour)
+	sent, _ = worker.RunOnce(ctx)
+	assert.Equal(t, 0, sent)
+
+	// A retry sends it again once the server is back
+	mailer.down = false
+	assert.NoError(t, outbox.Retry(ctx, db, dead[0].ID))
+	sent, err = worker.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.ErrorIs(t, outbox.Retry(ctx, db, dead[0].ID), outbox.ErrNotFound)
+
+	delivered, err := outbox.List(ctx, db, outbox.StatusSent, 10)
+	assert.NoError(t, err)
+	assert.Len(t, delivered, 1)
+	assert.NotNil(t, delivered[0].SentAt)
+	assert.Equal(t, []string{"thor@example.com"}, delivered[0].Recipients)
+
+	// Delivered bodies are not kept
+	var text string
+	assert.NoError(t, db.QueryRow("SELECT text_body FROM email_outbox").Scan(&text))
+	assert.Empty(t, text)
+
+	_, err = outbox.List(ctx, db, "bogus", 10)
+	assert.ErrorIs(t, err, outbox.ErrInvalidStatus)
+}
+
+// TestWorker_Lease verifies that a claimed entry is not sent twice, and that
+// the entries of a crashed worker are picked up once the lease expires
+func TestWorker_Lease(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+	mailer := &flakyMailer{}
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	assert.NoError(t, outbox.Enqueue(ctx, db, "reset:1", testMessage()))
+
+	// Simulate a worker that claimed the entry and died
+	_, err := db.Exec("UPDATE email_outbox SET status = ?, attempts = 1, next_attempt_at = ?",
+		outbox.StatusSending, now.UTC().Add(5*time.Minute))
+	assert.NoError(t, err)
+
+	worker := outbox.NewWorker(db, mailer)
+	worker.Now = clock
+	sent, err := worker.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	now = now.Add(6 * time.Minute)
+	sent, err = worker.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	sent, _ = worker.RunOnce(ctx)
+	assert.Equal(t, 0, sent)
+
+	// The Message-ID comes from the idempotency key, so a resend can be deduplicated
+	assert.Len(t, mailer.sent, 1)
+	assert.NotEmpty(t, mailer.sent[0].ID)
+}
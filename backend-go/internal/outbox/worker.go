@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"task-manager/backend-go/internal/mail"
+	"time"
+)
+
+// Worker delivers due outbox entries. Several workers, even on different
+// replicas, can share the table: each entry is claimed before it is sent.
+type Worker struct {
+	DB     *sql.DB
+	Mailer mail.Mailer
+	// BatchSize is the number of entries claimed per run.
+	BatchSize int
+	// MaxAttempts is the number of failed deliveries before an entry is dead.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure, doubled after each
+	// following one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Lease is how long a claimed entry is reserved; entries of a worker that
+	// died while sending are picked up again once it expires.
+	Lease time.Duration
+	// Now returns the current time; tests replace it.
+	Now func() time.Time
+}
+
+// NewWorker returns a worker with 8 attempts backing off from 30 seconds to 6 hours.
+func NewWorker(db *sql.DB, mailer mail.Mailer) *Worker {
+	return &Worker{
+		DB:          db,
+		Mailer:      mailer,
+		BatchSize:   20,
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    6 * time.Hour,
+		Lease:       5 * time.Minute,
+		Now:         time.Now,
+	}
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.BaseDelay
+	for i := 1; i < attempts && delay < w.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > w.MaxDelay {
+		delay = w.MaxDelay
+	}
+	return delay
+}
+
+// RunOnce delivers the entries that are due and returns how many were sent.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	now := w.Now().UTC()
+	rows, err := w.DB.QueryContext(ctx,
+		"SELECT id FROM email_outbox WHERE status IN (?, ?) AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?",
+		StatusPending, StatusSending, now, w.BatchSize,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, id := range ids {
+		ok, err := w.deliver(ctx, id, now)
+		if err != nil {
+			return sent, err
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// deliver claims and sends one entry. It reports whether the email was sent;
+// delivery failures are recorded on the entry, not returned.
+func (w *Worker) deliver(ctx context.Context, id int64, now time.Time) (bool, error) {
+	// The claim only succeeds if no other worker holds a live lease
+	res, err := w.DB.ExecContext(ctx,
+		"UPDATE email_outbox SET status = ?, attempts = attempts + 1, next_attempt_at = ? WHERE id = ? AND status IN (?, ?) AND next_attempt_at <= ?",
+		StatusSending, now.Add(w.Lease), id, StatusPending, StatusSending, now,
+	)
+	if err != nil {
+		return false, err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return false, nil
+	}
+
+	var key, recipients string
+	var attempts int
+	msg := &mail.Message{}
+	err = w.DB.QueryRowContext(ctx,
+		"SELECT idempotency_key, sender, recipients, subject, text_body, html_body, attempts FROM email_outbox WHERE id = ?", id,
+	).Scan(&key, &msg.From, &recipients, &msg.Subject, &msg.Text, &msg.HTML, &attempts)
+	if err != nil {
+		return false, err
+	}
+	msg.To = strings.Split(recipients, "\n")
+	msg.ID = messageID(key)
+
+	if sendErr := w.Mailer.Send(ctx, msg); sendErr != nil {
+		status, next := StatusPending, now.Add(w.backoff(attempts))
+		if attempts >= w.MaxAttempts {
+			status = StatusDead
+		}
+		_, err = w.DB.ExecContext(ctx,
+			"UPDATE email_outbox SET status = ?, next_attempt_at = ?, last_error = ? WHERE id = ?",
+			status, next, sendErr.Error(), id,
+		)
+		return false, err
+	}
+
+	// Bodies may hold single-use links, so they are not kept once delivered
+	_, err = w.DB.ExecContext(ctx,
+		"UPDATE email_outbox SET status = ?, sent_at = ?, last_error = NULL, text_body = '', html_body = '' WHERE id = ?",
+		StatusSent, w.Now().UTC(), id,
+	)
+	return err == nil, err
+}
+
+// Run delivers due entries every interval until stop is closed.
+func (w *Worker) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := w.RunOnce(context.Background()); err != nil {
+				log.Printf("Error delivering outbox emails: %v", err)
+			}
+		}
+	}
+}
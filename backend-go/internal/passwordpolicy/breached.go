@@ -0,0 +1,109 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefixLength is the length of the SHA-1 prefix used by the k-anonymity range format.
+const prefixLength = 5
+
+// BreachedList reports whether a password appears in a list of breached passwords.
+type BreachedList interface {
+	Contains(password string) (bool, error)
+}
+
+// LoadBreachedList opens a list in the Pwned Passwords k-anonymity format.
+// A directory holds one file per 5-character SHA-1 prefix (e.g. "21BD1" or
+// "21BD1.txt") with "SUFFIX:COUNT" lines, as served by the range API; only the
+// file for the password's prefix is read. A single file holds full
+// "HASH:COUNT" lines and is loaded in memory indexed by prefix.
+func LoadBreachedList(path string) (BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return RangeDir(path), nil
+	}
+	return loadHashFile(path)
+}
+
+// hashPassword returns the uppercase SHA-1 of password split into prefix and suffix.
+func hashPassword(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:prefixLength], hash[prefixLength:]
+}
+
+// parseLine returns the hash part of a "HASH:COUNT" line, uppercased.
+func parseLine(line string) string {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	return strings.ToUpper(hash)
+}
+
+// RangeDir is a directory of range files, one per hash prefix.
+type RangeDir string
+
+func (d RangeDir) Contains(password string) (bool, error) {
+	prefix, suffix := hashPassword(password)
+
+	file, err := os.Open(filepath.Join(string(d), prefix))
+	if os.IsNotExist(err) {
+		file, err = os.Open(filepath.Join(string(d), prefix+".txt"))
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if parseLine(scanner.Text()) == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// hashFile is a full-hash list held in memory, indexed by prefix.
+type hashFile map[string]map[string]struct{}
+
+func loadHashFile(path string) (hashFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := hashFile{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		hash := parseLine(scanner.Text())
+		if hash == "" {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, line)
+		}
+		prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+		if list[prefix] == nil {
+			list[prefix] = map[string]struct{}{}
+		}
+		list[prefix][suffix] = struct{}{}
+	}
+	return list, scanner.Err()
+}
+
+func (l hashFile) Contains(password string) (bool, error) {
+	prefix, suffix := hashPassword(password)
+	_, ok := l[prefix][suffix]
+	return ok, nil
+}
@@ -0,0 +1,135 @@
+// Package passwordpolicy validates new passwords against configurable rules
+// and an optional local list of breached passwords.
+package passwordpolicy
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rule names reported when a password fails validation.
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUppercase    = "uppercase"
+	RuleLowercase    = "lowercase"
+	RuleDigit        = "digit"
+	RuleSpecial      = "special"
+	RulePersonalInfo = "personal_info"
+	RuleBreached     = "breached"
+)
+
+// Policy lists the requirements for new passwords.
+type Policy struct {
+	MinLength int
+	// MaxLength is measured in bytes: bcrypt ignores everything after 72 bytes.
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// ForbidPersonalInfo rejects passwords containing the username or the email's local part.
+	ForbidPersonalInfo bool
+	// Breached is checked last, only for passwords that pass every other rule (optional).
+	Breached BreachedList
+}
+
+// Default mirrors the historical rules: 8 characters with upper and lower
+// case letters, a digit and a special character.
+func Default() Policy {
+	return Policy{
+		MinLength:          8,
+		MaxLength:          72,
+		RequireUpper:       true,
+		RequireLower:       true,
+		RequireDigit:       true,
+		RequireSpecial:     true,
+		ForbidPersonalInfo: true,
+	}
+}
+
+// ValidationError lists the rules a password failed.
+type ValidationError struct {
+	Rules []string
+}
+
+func (e *ValidationError) Error() string {
+	return "password_not_secure: " + strings.Join(e.Rules, ", ")
+}
+
+// minPersonalInfoLength skips personal info too short to be meaningful, such as "jo".
+const minPersonalInfoLength = 3
+
+// Validate checks password against the policy and returns a *ValidationError
+// listing every failed rule, or nil. username and email feed the personal info rule.
+func (p Policy) Validate(password, username, email string) error {
+	var failed []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		failed = append(failed, RuleMinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		failed = append(failed, RuleMaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case unicode.IsPunct(char), unicode.IsSymbol(char):
+			hasSpecial = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		failed = append(failed, RuleUppercase)
+	}
+	if p.RequireLower && !hasLower {
+		failed = append(failed, RuleLowercase)
+	}
+	if p.RequireDigit && !hasDigit {
+		failed = append(failed, RuleDigit)
+	}
+	if p.RequireSpecial && !hasSpecial {
+		failed = append(failed, RuleSpecial)
+	}
+
+	if p.ForbidPersonalInfo && containsPersonalInfo(password, username, email) {
+		failed = append(failed, RulePersonalInfo)
+	}
+
+	if len(failed) == 0 && p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			failed = append(failed, RuleBreached)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &ValidationError{Rules: failed}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether the password contains the username or
+// the local part of the email, ignoring case.
+func containsPersonalInfo(password, username, email string) bool {
+	lowered := strings.ToLower(password)
+	localPart, _, _ := strings.Cut(email, "@")
+
+	for _, value := range []string{username, localPart} {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if utf8.RuneCountInString(value) >= minPersonalInfoLength && strings.Contains(lowered, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package passwordpolicy_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"task-manager/backend-go/internal/passwordpolicy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failedRules returns the rules reported by Validate, or nil when the password is accepted.
+func failedRules(t *testing.T, policy passwordpolicy.Policy, password, username, email string) []string {
+	err := policy.Validate(password, username, email)
+	if err == nil {
+		return nil
+	}
+	var invalid *passwordpolicy.ValidationError
+	assert.ErrorAs(t, err, &invalid)
+	return invalid.Rules
+}
+
+// TestValidate_DefaultPolicy verifies that every failed rule is listed
+func TestValidate_DefaultPolicy(t *testing.T) {
+	policy := passwordpolicy.Default()
+
+	assert.Nil(t, failedRules(t, policy, "Str0ng-Passw0rd", "thor", "thor@example.com"))
+	assert.Equal(t,
+		[]string{passwordpolicy.RuleMinLength, passwordpolicy.RuleUppercase, passwordpolicy.RuleDigit, passwordpolicy.RuleSpecial},
+		failedRules(t, policy, "short", "", ""))
+	assert.Equal(t, []string{passwordpolicy.RuleMaxLength}, failedRules(t, policy, "Aa1!"+strings.Repeat("x", 70), "", ""))
+
+	// The username and the local part of the email are forbidden, ignoring case
+	assert.Equal(t, []string{passwordpolicy.RulePersonalInfo}, failedRules(t, policy, "My-THORbar-1", "thorbar", "x@example.com"))
+	assert.Equal(t, []string{passwordpolicy.RulePersonalInfo}, failedRules(t, policy, "Odinson-2024!", "thor", "odinson@example.com"))
+}
+
+// TestValidate_CustomPolicy verifies that disabled rules are not enforced
+func TestValidate_CustomPolicy(t *testing.T) {
+	policy := passwordpolicy.Policy{MinLength: 12}
+
+	assert.Nil(t, failedRules(t, policy, "correct horse battery", "horse", ""))
+	assert.Equal(t, []string{passwordpolicy.RuleMinLength}, failedRules(t, policy, "tiny", "", ""))
+}
+
+// sha1Hex returns the uppercase SHA-1 of the password.
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// TestBreachedList verifies both on-disk formats of the k-anonymity list
+func TestBreachedList(t *testing.T) {
+	breachedPassword := "P@ssw0rd-2024"
+	hash := sha1Hex(breachedPassword)
+
+	// Directory of range files named by prefix
+	dir := t.TempDir()
+	rangeFile := "0018A45C4D1DEF81644B54AB7F969B88D65:1\n" + strings.ToLower(hash[5:]) + ":42\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, hash[:5]), []byte(rangeFile), 0o644))
+
+	// Single file of full hashes
+	file := filepath.Join(t.TempDir(), "pwned.txt")
+	assert.NoError(t, os.WriteFile(file, []byte(hash+":42\n\n"), 0o644))
+
+	for _, path := range []string{dir, file} {
+		list, err := passwordpolicy.LoadBreachedList(path)
+		assert.NoError(t, err)
+
+		found, err := list.Contains(breachedPassword)
+		assert.NoError(t, err)
+		assert.True(t, found, path)
+
+		found, err = list.Contains("Another-Passw0rd")
+		assert.NoError(t, err)
+		assert.False(t, found, path)
+
+		policy := passwordpolicy.Default()
+		policy.Breached = list
+		assert.Equal(t, []string{passwordpolicy.RuleBreached}, failedRules(t, policy, breachedPassword, "", ""))
+	}
+
+	_, err := passwordpolicy.LoadBreachedList(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
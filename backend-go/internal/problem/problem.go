@@ -0,0 +1,230 @@
+// Package problem writes API errors as RFC 7807 problem details
+// (application/problem+json) carrying a stable code clients can branch on,
+// a detail translated into the locale of the request and the request ID.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/requestid"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// Code identifies a kind of error. Codes are part of the API: unlike the
+// translated detail they never change once published.
+type Code string
+
+// Codes of the errors returned by the API.
+const (
+	InvalidRequest   Code = "invalid_request"
+	ValidationFailed Code = "validation_failed"
+	PayloadTooLarge  Code = "payload_too_large"
+	MethodNotAllowed Code = "method_not_allowed"
+	Internal         Code = "internal_error"
+	RateLimited      Code = "rate_limited"
+
+	TokenMissing          Code = "token_missing"
+	TokenMalformed        Code = "token_malformed"
+	InvalidToken          Code = "invalid_token"
+	InsufficientScope     Code = "insufficient_scope"
+	Forbidden             Code = "forbidden"
+	TokenGenerationFailed Code = "token_generation_failed"
+
+	LoginFailed              Code = "login_failed"
+	IncorrectPassword        Code = "incorrect_password"
+	TooManyAttempts          Code = "too_many_attempts"
+	AccountLocked            Code = "account_locked"
+	UnlockFailed             Code = "unlock_failed"
+	InvalidMagicLink         Code = "invalid_magic_link"
+	ReauthenticationRequired Code = "reauthentication_required"
+
+	UserExists           Code = "user_exists"
+	RegistrationFailed   Code = "registration_failed"
+	UserNotFound         Code = "user_not_found"
+	UpdateFailed         Code = "update_failed"
+	InvalidEmail         Code = "invalid_email"
+	EmailTaken           Code = "email_taken"
+	EmailNotVerified     Code = "email_not_verified"
+	EmailVerifyFailed    Code = "email_verification_failed"
+	PasswordNotSecure    Code = "password_not_secure"
+	PasswordUpdateFailed Code = "password_update_failed"
+
+	SessionNotFound     Code = "session_not_found"
+	SessionsQueryFailed Code = "sessions_query_failed"
+	SessionRevokeFailed Code = "session_revoke_failed"
+	LogoutFailed        Code = "logout_failed"
+
+	MFANotEnrolled        Code = "mfa_not_enrolled"
+	MFAAlreadyEnabled     Code = "mfa_already_enabled"
+	InvalidMFACode        Code = "invalid_mfa_code"
+	MFAEnrollFailed       Code = "mfa_enroll_failed"
+	MFAVerifyFailed       Code = "mfa_verify_failed"
+	OIDCDisabled          Code = "oidc_disabled"
+	OIDCInvalidState      Code = "oidc_invalid_state"
+	OIDCEmailUnverified   Code = "oidc_email_unverified"
+	OIDCAccountUnverified Code = "oidc_account_unverified"
+
+	InvalidAccessTokenRequest Code = "invalid_access_token_request"
+	AccessTokenNotFound       Code = "access_token_not_found"
+	AccessTokenQueryFailed    Code = "access_token_query_failed"
+	AccessTokenCreateFailed   Code = "access_token_create_failed"
+	AccessTokenRevokeFailed   Code = "access_token_revoke_failed"
+
+	InvalidPreferences    Code = "invalid_preferences"
+	InvalidDigestSettings Code = "invalid_digest_settings"
+	ExportNotFound        Code = "export_not_found"
+	ExportNotReady        Code = "export_not_ready"
+	ExportFailed          Code = "export_failed"
+	OutboxEmailNotFound   Code = "outbox_email_not_found"
+	InvalidOutboxStatus   Code = "invalid_outbox_status"
+
+	InvalidID        Code = "invalid_id"
+	InvalidSort      Code = "invalid_sort"
+	TaskNotFound     Code = "task_not_found"
+	TaskQueryFailed  Code = "task_query_failed"
+	TaskCreateFailed Code = "task_create_failed"
+	TaskUpdateFailed Code = "task_update_failed"
+	TaskDeleteFailed Code = "task_delete_failed"
+)
+
+// messages maps each code to the translation key of its detail.
+var messages = map[Code]string{
+	InvalidRequest:   "http.error.invalid_data",
+	ValidationFailed: "http.error.validation_failed",
+	PayloadTooLarge:  "http.error.payload_too_large",
+	MethodNotAllowed: "http.error.method_not_allowed",
+	Internal:         "http.error.internal",
+	RateLimited:      "error.rate_limited",
+
+	TokenMissing:          "auth.error.token_not_provided",
+	TokenMalformed:        "error.invalid_token_format",
+	InvalidToken:          "auth.error.invalid_token",
+	InsufficientScope:     "error.insufficient_scope",
+	Forbidden:             "auth.error.forbidden",
+	TokenGenerationFailed: "error_token_generation",
+
+	LoginFailed:              "login_failed",
+	IncorrectPassword:        "user.error.incorrect_password",
+	TooManyAttempts:          "login.error.too_many_attempts",
+	AccountLocked:            "login.error.account_locked",
+	UnlockFailed:             "login.error.unlock_failed",
+	InvalidMagicLink:         "magic_link.error.invalid",
+	ReauthenticationRequired: "user.error.reauthentication_required",
+
+	UserExists:           "user_already_exists",
+	RegistrationFailed:   "register_failed",
+	UserNotFound:         "user.error.not_found",
+	UpdateFailed:         "user.error.update_failed",
+	InvalidEmail:         "user.error.invalid_email",
+	EmailTaken:           "user.error.email_taken",
+	EmailNotVerified:     "auth.error.email_not_verified",
+	EmailVerifyFailed:    "email.error.verify_failed",
+	PasswordNotSecure:    "password_not_secure",
+	PasswordUpdateFailed: "password_update_error",
+
+	SessionNotFound:     "auth.error.session_not_found",
+	SessionsQueryFailed: "auth.error.sessions_query_failed",
+	SessionRevokeFailed: "auth.error.revoke_failed",
+	LogoutFailed:        "auth.error.logout_failed",
+
+	MFANotEnrolled:        "mfa.error.not_enrolled",
+	MFAAlreadyEnabled:     "mfa.error.already_enabled",
+	InvalidMFACode:        "mfa.error.invalid_code",
+	MFAEnrollFailed:       "mfa.error.enroll_failed",
+	MFAVerifyFailed:       "mfa.error.verify_failed",
+	OIDCDisabled:          "oidc.error.disabled",
+	OIDCInvalidState:      "oidc.error.invalid_state",
+	OIDCEmailUnverified:   "oidc.error.email_unverified",
+	OIDCAccountUnverified: "oidc.error.account_unverified",
+
+	InvalidAccessTokenRequest: "token.error.invalid_request",
+	AccessTokenNotFound:       "token.error.not_found",
+	AccessTokenQueryFailed:    "token.error.query_failed",
+	AccessTokenCreateFailed:   "token.error.create_failed",
+	AccessTokenRevokeFailed:   "token.error.revoke_failed",
+
+	InvalidPreferences:    "preferences.error.invalid",
+	InvalidDigestSettings: "digest.error.invalid_settings",
+	ExportNotFound:        "export.error.not_found",
+	ExportNotReady:        "export.error.not_ready",
+	ExportFailed:          "export.error.failed",
+	OutboxEmailNotFound:   "outbox.error.not_found",
+	InvalidOutboxStatus:   "outbox.error.invalid_status",
+
+	InvalidID:        "error.invalid_id",
+	InvalidSort:      "error.invalid_sort",
+	TaskNotFound:     "error.task_not_found_or_not_owned",
+	TaskQueryFailed:  "error.query_tasks_failed",
+	TaskCreateFailed: "error.create_task_failed",
+	TaskUpdateFailed: "error.update_task_failed",
+	TaskDeleteFailed: "error.delete_task_failed",
+}
+
+// MessageKey returns the translation key of the detail of code.
+func (c Code) MessageKey() string {
+	if key, ok := messages[c]; ok {
+		return key
+	}
+	return messages[Internal]
+}
+
+// Codes returns every code, sorted.
+func Codes() []Code {
+	codes := make([]Code, 0, len(messages))
+	for code := range messages {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// FieldError describes why one field of the request was rejected.
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// Problem is an RFC 7807 problem detail with the code, request ID and field
+// errors of the API as extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// New returns the problem of code for the request, its detail translated
+// into the locale of the request.
+func New(r *http.Request, status int, code Code) *Problem {
+	return &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    i18n.Translate(r.Context(), code.MessageKey()),
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: requestid.FromContext(r.Context()),
+	}
+}
+
+// Write writes the problem of code with the given HTTP status.
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code) {
+	New(r, status, code).Write(w)
+}
+
+// Write writes the problem as the response.
+func (p *Problem) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
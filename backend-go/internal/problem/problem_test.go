@@ -0,0 +1,79 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/requestid"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serve runs handler behind the request ID and locale middlewares.
+func serve(handler http.HandlerFunc, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/7/update", nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	requestid.Middleware(i18n.Middleware(handler)).ServeHTTP(rec, req)
+	return rec
+}
+
+// TestWrite verifies the problem+json document of an error code
+func TestWrite(t *testing.T) {
+	previous, previousMessages, previousLocale := i18n.Default, i18n.Messages, i18n.DefaultLocale
+	assert.NoError(t, i18n.LoadMessages("es"))
+	defer func() { i18n.Default, i18n.Messages, i18n.DefaultLocale = previous, previousMessages, previousLocale }()
+
+	rec := serve(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, http.StatusNotFound, problem.TaskNotFound)
+	}, http.Header{"Accept-Language": {"en"}, "X-Request-Id": {"req-42"}})
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "req-42", rec.Header().Get(requestid.Header))
+
+	var body problem.Problem
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, problem.Problem{
+		Type:      "about:blank",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    i18n.Lookup("en", "error.task_not_found_or_not_owned"),
+		Instance:  "/api/tasks/7/update",
+		Code:      problem.TaskNotFound,
+		RequestID: "req-42",
+	}, body)
+
+	// Field errors are listed, and the detail follows the locale of the request
+	rec = serve(func(w http.ResponseWriter, r *http.Request) {
+		p := problem.New(r, http.StatusBadRequest, problem.PasswordNotSecure)
+		p.Errors = append(p.Errors, problem.FieldError{Field: "password", Code: "min_length", Detail: "too short"})
+		p.Write(w)
+	}, http.Header{"Accept-Language": {"ca"}, "X-Request-Id": {"not a valid id"}})
+
+	var fields map[string]any
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&fields))
+	assert.Equal(t, "password_not_secure", fields["code"])
+	assert.Equal(t, i18n.Lookup("ca", "password_not_secure"), fields["detail"])
+	assert.Equal(t, []any{map[string]any{"field": "password", "code": "min_length", "detail": "too short"}}, fields["errors"])
+	// An invalid client ID is replaced
+	assert.Len(t, fields["request_id"], 32)
+	assert.Equal(t, fields["request_id"], rec.Header().Get(requestid.Header))
+}
+
+// TestCodes verifies that codes are lower snake case and unknown codes read as internal errors
+func TestCodes(t *testing.T) {
+	for _, code := range problem.Codes() {
+		assert.Equal(t, strings.ToLower(string(code)), string(code))
+		assert.NotContains(t, string(code), " ")
+		assert.NotEmpty(t, code.MessageKey(), code)
+	}
+	assert.Equal(t, problem.Internal.MessageKey(), problem.Code("unknown").MessageKey())
+}
@@ -0,0 +1,151 @@
+// Package ratelimit provides a token-bucket rate limiting middleware with
+// per-route-group limits and a pluggable bucket store.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager/backend-go/internal/problem"
+)
+
+// Limit allows Requests per Window, refilled continuously; Requests is also the burst size.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseLimit reads a limit written as "<requests>/<window>", e.g. "20/1m".
+// An empty value, "0" or "off" returns a zero Limit, which disables limiting.
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" || value == "off" {
+		return Limit{}, nil
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	window, err := time.ParseDuration(parts[1])
+	if err != nil || window <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", value)
+	}
+	return Limit{Requests: requests, Window: window}, nil
+}
+
+// Enabled reports whether the limit restricts anything.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+// interval is the time needed to refill one token.
+func (l Limit) interval() time.Duration {
+	return l.Window / time.Duration(l.Requests)
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token when the request was refused.
+	RetryAfter time.Duration
+}
+
+// Store keeps buckets. Take must be atomic per key so limits hold across
+// concurrent requests, and across replicas for shared implementations.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Group applies a limit to every path starting with one of its prefixes.
+type Group struct {
+	Name     string
+	Prefixes []string
+	Limit    Limit
+}
+
+// matches reports whether the path belongs to the group.
+func (g Group) matches(path string) bool {
+	for _, prefix := range g.Prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyFunc identifies the client a request is counted against.
+type KeyFunc func(r *http.Request) string
+
+// Limiter is the middleware configuration. Requests outside every group are not limited.
+type Limiter struct {
+	Store  Store
+	Groups []Group
+	Key    KeyFunc
+	Now    func() time.Time
+}
+
+// New creates a limiter using the in-memory store.
+func New(key KeyFunc, groups ...Group) *Limiter {
+	return &Limiter{Store: NewMemoryStore(), Groups: groups, Key: key, Now: time.Now}
+}
+
+// Middleware enforces the group limits, setting RateLimit-Limit,
+// RateLimit-Remaining and RateLimit-Reset on limited routes and answering
+// 429 with Retry-After once a bucket is empty.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group, ok := l.group(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := l.Store.Take(r.Context(), group.Name+":"+l.Key(r), group.Limit, l.Now())
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down
+			log.Printf("Rate limit store error: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(group.Limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(result.Reset))
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", seconds(result.RetryAfter))
+			problem.Write(w, r, http.StatusTooManyRequests, problem.RateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// group returns the first enabled group matching the path.
+func (l *Limiter) group(path string) (Group, bool) {
+	for _, group := range l.Groups {
+		if group.Limit.Enabled() && group.matches(path) {
+			return group, true
+		}
+	}
+	return Group{}, false
+}
+
+// seconds formats a duration as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
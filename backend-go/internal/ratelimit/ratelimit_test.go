@@ -0,0 +1,96 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseLimit verifies the "<requests>/<window>" format
+func TestParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("20/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 20, Window: time.Minute}, limit)
+
+	limit, err = ratelimit.ParseLimit("off")
+	assert.NoError(t, err)
+	assert.False(t, limit.Enabled())
+
+	for _, value := range []string{"20", "x/1m", "20/x", "20/0s"} {
+		_, err := ratelimit.ParseLimit(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// TestBucket_RefillsContinuously verifies burst, refusal and refill
+func TestBucket_RefillsContinuously(t *testing.T) {
+	limit := ratelimit.Limit{Requests: 2, Window: 2 * time.Second}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var bucket ratelimit.Bucket
+
+	assert.True(t, bucket.Take(limit, now).Allowed)
+	result := bucket.Take(limit, now)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 2*time.Second, result.Reset)
+
+	result = bucket.Take(limit, now)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+
+	result = bucket.Take(limit, now.Add(time.Second))
+	assert.True(t, result.Allowed)
+}
+
+// TestMiddleware_LimitsPerGroupAndKey verifies headers, 429 responses and isolation of groups and clients
+func TestMiddleware_LimitsPerGroupAndKey(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := ratelimit.New(
+		func(r *http.Request) string { return r.Header.Get("X-Client") },
+		ratelimit.Group{Name: "auth", Prefixes: []string{"/login"}, Limit: ratelimit.Limit{Requests: 2, Window: time.Minute}},
+		ratelimit.Group{Name: "tasks", Prefixes: []string{"/api/tasks/"}, Limit: ratelimit.Limit{Requests: 5, Window: time.Minute}},
+	)
+	limiter.Now = func() time.Time { return now }
+
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(path, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("X-Client", client)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/login", "a")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+
+	assert.Equal(t, http.StatusOK, do("/login", "a").Code)
+	rec = do("/login", "a")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+	// Other clients and other groups have their own buckets
+	assert.Equal(t, http.StatusOK, do("/login", "b").Code)
+	rec = do("/api/tasks/", "a")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "4", rec.Header().Get("RateLimit-Remaining"))
+
+	// Routes outside every group are not limited
+	rec = do("/health", "a")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+
+	// Tokens come back over time
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, http.StatusOK, do("/login", "a").Code)
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Bucket is the state of one token bucket. It is exported so other Store
+// implementations can share the refill arithmetic.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills the bucket up to now and removes one token if available.
+// A zero Bucket starts full.
+func (b *Bucket) Take(limit Limit, now time.Time) Result {
+	capacity := float64(limit.Requests)
+	interval := limit.interval()
+
+	if b.Updated.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = min(capacity, b.Tokens+float64(elapsed)/float64(interval))
+	}
+	b.Updated = now
+
+	result := Result{Allowed: b.Tokens >= 1}
+	if result.Allowed {
+		b.Tokens--
+	} else {
+		result.RetryAfter = time.Duration((1 - b.Tokens) * float64(interval))
+	}
+	result.Remaining = int(b.Tokens)
+	result.Reset = time.Duration((capacity - b.Tokens) * float64(interval))
+	return result
+}
+
+// pruneInterval is how often idle buckets are dropped from memory.
+const pruneInterval = time.Minute
+
+// MemoryStore keeps buckets in process memory.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastPrune time.Time
+}
+
+// memoryBucket remembers when the bucket will be full, after which it can be forgotten.
+type memoryBucket struct {
+	Bucket
+	fullAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastPrune) > pruneInterval {
+		s.prune(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{}
+		s.buckets[key] = bucket
+	}
+	result := bucket.Take(limit, now)
+	bucket.fullAt = now.Add(result.Reset)
+	return result, nil
+}
+
+// prune drops buckets that have refilled completely; they are equivalent to new ones.
+func (s *MemoryStore) prune(now time.Time) {
+	for key, bucket := range s.buckets {
+		if !bucket.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastPrune = now
+}
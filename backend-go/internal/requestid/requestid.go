@@ -0,0 +1,60 @@
+// Package requestid gives every request an ID, returned in the X-Request-ID
+// header and in error responses so a report can be matched with the logs.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// Header carries the request ID, accepted from a proxy in front of the API or generated.
+const Header = "X-Request-ID"
+
+// maxLength bounds the IDs accepted from clients.
+const maxLength = 64
+
+// key is the context key of the request ID.
+type key struct{}
+
+// Middleware reuses a well-formed X-Request-ID header of the request or
+// generates one, stores it in the context and echoes it in the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = generate()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), key{}, id)))
+	})
+}
+
+// FromContext returns the ID of the request, "" outside Middleware.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
+
+// valid reports whether id is short and only uses letters, digits, "-", "_" and ".".
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// generate returns a random 128-bit ID in hex.
+func generate() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
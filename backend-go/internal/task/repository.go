@@ -0,0 +1,273 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"task-manager/backend-go/db"
+	"task-manager/backend-go/internal/user"
+)
+
+var (
+	// ErrTaskNotFound is returned when the user has no task with the ID.
+	ErrTaskNotFound = errors.New("task_not_found")
+	// ErrInvalidOrder is returned for an order other than the user.TaskSort* values.
+	ErrInvalidOrder = errors.New("invalid_order")
+)
+
+// TaskRepository stores the tasks of users. The SQL implementation backs the
+// server; the in-memory one lets tests run the HTTP API without a database.
+type TaskRepository interface {
+	// List returns the tasks of the user in order, one of the user.TaskSort* values.
+	List(ctx context.Context, userID int, order string) ([]Task, error)
+	// Create stores a pending task for the user and returns it as stored.
+	Create(ctx context.Context, userID int, task Task) (Task, error)
+	// Update changes the title, description, status and due date of a task of
+	// the user, or returns ErrTaskNotFound. An empty status keeps the stored one.
+	Update(ctx context.Context, userID int, task Task) error
+	// Delete removes a task of the user, or returns ErrTaskNotFound.
+	Delete(ctx context.Context, userID, taskID int) error
+}
+
+// taskOrders maps the task orders users can pick, in their preferences or
+// with ?sort=, to ORDER BY clauses.
+var taskOrders = map[string]string{
+	user.TaskSortCreatedAsc:  "t.created_at, t.id",
+	user.TaskSortCreatedDesc: "t.created_at DESC, t.id DESC",
+	user.TaskSortDueDate:     "t.due_date IS NULL, t.due_date, t.id",
+	user.TaskSortTitle:       "t.title, t.id",
+	user.TaskSortStatus:      "t.status, t.id",
+}
+
+// SQLTaskRepository stores tasks in the tasks table.
+type SQLTaskRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLTaskRepository creates a repository over db.
+func NewSQLTaskRepository(db *sql.DB) *SQLTaskRepository {
+	return &SQLTaskRepository{DB: db}
+}
+
+// selectTasks reads tasks with the username of their owner.
+const selectTasks = `
+	SELECT t.id, t.title, t.description, t.status, t.created_at, t.due_date, t.user_id, u.username
+	FROM tasks t
+	JOIN users u ON t.user_id = u.id
+`
+
+// scanTask reads a row of selectTasks.
+func scanTask(row interface{ Scan(...any) error }) (Task, error) {
+	var task Task
+	var dueDate sql.NullTime
+	err := row.Scan(
+		&task.ID, &task.Title, &task.Description, &task.Status,
+		&task.CreatedAt, &dueDate, &task.UserID, &task.Username,
+	)
+	task.DueDate = formatDueDate(dueDate)
+	return task, err
+}
+
+func (r *SQLTaskRepository) List(ctx context.Context, userID int, order string) ([]Task, error) {
+	clause, ok := taskOrders[order]
+	if !ok {
+		return nil, ErrInvalidOrder
+	}
+	rows, err := r.DB.QueryContext(ctx, selectTasks+"WHERE u.id = ? ORDER BY "+clause, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *SQLTaskRepository) Create(ctx context.Context, userID int, task Task) (Task, error) {
+	taskID, err := db.DialectOf(r.DB).Insert(ctx, r.DB, `
+		INSERT INTO tasks (title, description, status, created_at, due_date, user_id)
+		VALUES (?, ?, 'pending', ?, ?, ?)`,
+		task.Title, task.Description, time.Now(), dueDateValue(task.DueDate), userID,
+	)
+	if err != nil {
+		return Task{}, err
+	}
+	return scanTask(r.DB.QueryRowContext(ctx, selectTasks+"WHERE t.id = ?", taskID))
+}
+
+func (r *SQLTaskRepository) Update(ctx context.Context, userID int, task Task) error {
+	// An empty status keeps the stored one; completed_at keeps the first
+	// completion time and is cleared when the task is reopened
+	res, err := r.DB.ExecContext(ctx, `
+		UPDATE tasks
+		SET title = ?, description = ?, status = COALESCE(NULLIF(?, ''), status), due_date = ?,
+			completed_at = CASE WHEN COALESCE(NULLIF(?, ''), status) = 'completed' THEN COALESCE(completed_at, ?) ELSE NULL END
+		WHERE id = ? AND user_id = ?
+	`, task.Title, task.Description, task.Status, dueDateValue(task.DueDate),
+		task.Status, time.Now().UTC(), task.ID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+func (r *SQLTaskRepository) Delete(ctx context.Context, userID, taskID int) error {
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM tasks WHERE id = ? AND user_id = ?", taskID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+// dueDateValue returns the "2006-01-02" due date to store, nil when unset.
+func dueDateValue(value *string) any {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return *value
+}
+
+// formatDueDate returns a due date read from the database as "2006-01-02".
+func formatDueDate(value sql.NullTime) *string {
+	if !value.Valid {
+		return nil
+	}
+	date := value.Time.Format(time.DateOnly)
+	return &date
+}
+
+// memoryOrders compares tasks like the ORDER BY clauses of taskOrders.
+var memoryOrders = map[string]func(a, b Task) bool{
+	user.TaskSortCreatedAsc: func(a, b Task) bool {
+		return a.CreatedAt < b.CreatedAt || a.CreatedAt == b.CreatedAt && a.ID < b.ID
+	},
+	user.TaskSortCreatedDesc: func(a, b Task) bool {
+		return a.CreatedAt > b.CreatedAt || a.CreatedAt == b.CreatedAt && a.ID > b.ID
+	},
+	user.TaskSortDueDate: func(a, b Task) bool {
+		if (a.DueDate == nil) != (b.DueDate == nil) {
+			return b.DueDate == nil
+		}
+		if a.DueDate != nil && *a.DueDate != *b.DueDate {
+			return *a.DueDate < *b.DueDate
+		}
+		return a.ID < b.ID
+	},
+	user.TaskSortTitle: func(a, b Task) bool {
+		return a.Title < b.Title || a.Title == b.Title && a.ID < b.ID
+	},
+	user.TaskSortStatus: func(a, b Task) bool {
+		return a.Status < b.Status || a.Status == b.Status && a.ID < b.ID
+	},
+}
+
+// MemoryTaskRepository keeps tasks in process memory, for tests. Usernames
+// are looked up in Users when it is set.
+type MemoryTaskRepository struct {
+	Users user.UserRepository
+
+	mu     sync.Mutex
+	tasks  map[int]Task
+	nextID int
+}
+
+// NewMemoryTaskRepository creates an empty in-memory repository.
+func NewMemoryTaskRepository(users user.UserRepository) *MemoryTaskRepository {
+	return &MemoryTaskRepository{Users: users, tasks: make(map[int]Task)}
+}
+
+func (r *MemoryTaskRepository) List(ctx context.Context, userID int, order string) ([]Task, error) {
+	less, ok := memoryOrders[order]
+	if !ok {
+		return nil, ErrInvalidOrder
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var tasks []Task
+	for _, task := range r.tasks {
+		if task.UserID == userID {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return less(tasks[i], tasks[j]) })
+	return tasks, nil
+}
+
+func (r *MemoryTaskRepository) Create(ctx context.Context, userID int, task Task) (Task, error) {
+	var username string
+	if r.Users != nil {
+		summary, err := r.Users.Summary(ctx, userID)
+		if err != nil {
+			return Task{}, err
+		}
+		username = summary.Username
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	task.ID = r.nextID
+	task.UserID = userID
+	task.Username = username
+	task.Status = "pending"
+	task.CreatedAt = time.Now().UTC().Format(time.DateTime)
+	if task.DueDate != nil && *task.DueDate == "" {
+		task.DueDate = nil
+	}
+	task.Overdue = false
+	r.tasks[task.ID] = task
+	return task, nil
+}
+
+func (r *MemoryTaskRepository) Update(ctx context.Context, userID int, task Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.tasks[task.ID]
+	if !ok || stored.UserID != userID {
+		return ErrTaskNotFound
+	}
+	stored.Title = task.Title
+	stored.Description = task.Description
+	if task.Status != "" {
+		stored.Status = task.Status
+	}
+	stored.DueDate = nil
+	if task.DueDate != nil && *task.DueDate != "" {
+		dueDate := *task.DueDate
+		stored.DueDate = &dueDate
+	}
+	r.tasks[task.ID] = stored
+	return nil
+}
+
+func (r *MemoryTaskRepository) Delete(ctx context.Context, userID, taskID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if task, ok := r.tasks[taskID]; !ok || task.UserID != userID {
+		return ErrTaskNotFound
+	}
+	delete(r.tasks, taskID)
+	return nil
+}
@@ -1,117 +1,108 @@
 package task
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
-	"task-manager/backend-go/db"
 	"task-manager/backend-go/internal/auth"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
 	"time"
 )
 
-// TasksRouter routes HTTP methods to appropriate handlers.
-func TasksRouter(w http.ResponseWriter, r *http.Request) {
+// PreferenceSource gives the preferences of a user: the default order of the
+// task list and the time zone deciding which tasks are overdue.
+type PreferenceSource interface {
+	Preferences(ctx context.Context, userID int) (models.UserPreferences, error)
+}
+
+// Handler serves the task API of the authenticated user over the
+// dependencies it is constructed with in main.
+type Handler struct {
+	Tasks       TaskRepository
+	Preferences PreferenceSource
+}
+
+// NewHandler creates the handlers of the task API.
+func NewHandler(tasks TaskRepository, preferences PreferenceSource) *Handler {
+	return &Handler{Tasks: tasks, Preferences: preferences}
+}
+
+// Router routes HTTP methods to appropriate handlers.
+func (h *Handler) Router(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		GetTasksHandler(w, r)
+		h.List(w, r)
 	case http.MethodPost:
-		CreateTaskHandler(w, r)
+		h.Create(w, r)
 	case http.MethodPut:
-		UpdateTaskHandler(w, r)
+		h.Update(w, r)
 	case http.MethodDelete:
-		DeleteTaskHandler(w, r)
+		h.Delete(w, r)
 	default:
-		http.Error(w, i18n.T("error.method_not_allowed"), http.StatusMethodNotAllowed)
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
 	}
 }
 
-// GetTasksHandler returns all tasks for the authenticated user.
-func GetTasksHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserIDFromAuthHeader(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+// List returns all tasks for the authenticated user, in the order of ?sort=
+// or else of their preferences. Tasks are overdue when their due date is
+// before today in the time zone of the user.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := getUserIDFromAuthHeader(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
 		return
 	}
 
-	rows, err := db.DB.Query(`
-		SELECT t.id, t.title, t.description, t.status, t.created_at, t.user_id, u.username
-		FROM tasks t
-		JOIN users u ON t.user_id = u.id
-		WHERE u.id = ?`, userID)
+	prefs, err := h.Preferences.Preferences(r.Context(), userID)
 	if err != nil {
-		http.Error(w, i18n.T("error.query_tasks_failed"), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.TaskQueryFailed)
+		return
+	}
+	order := prefs.TaskSort
+	if requested := r.URL.Query().Get("sort"); requested != "" {
+		order = requested
+	}
+
+	tasks, err := h.Tasks.List(r.Context(), userID, order)
+	if err == ErrInvalidOrder {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidSort)
+		return
+	} else if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.TaskQueryFailed)
 		return
 	}
-	defer rows.Close()
 
-	var tasks []Task
-	for rows.Next() {
-		var task Task
-		if err := rows.Scan(
-			&task.ID, &task.Title, &task.Description, &task.Status,
-			&task.CreatedAt, &task.UserID, &task.Username,
-		); err != nil {
-			http.Error(w, i18n.T("error.read_tasks_failed"), http.StatusInternalServerError)
-			return
-		}
-		tasks = append(tasks, task)
+	today := time.Now().In(user.Location(prefs)).Format(time.DateOnly)
+	for i := range tasks {
+		tasks[i].Overdue = tasks[i].DueDate != nil && *tasks[i].DueDate < today && tasks[i].Status != "completed"
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{"tasks": tasks})
 }
 
-// CreateTaskHandler creates a new task for the authenticated user.
-func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserIDFromAuthHeader(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+// Create creates a new task for the authenticated user.
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := getUserIDFromAuthHeader(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
 		return
 	}
 
 	var newTask Task
-	if err := json.NewDecoder(r.Body).Decode(&newTask); err != nil {
-		http.Error(w, i18n.T("error.invalid_data"), http.StatusBadRequest)
+	if !validate.Decode(w, r, &newTask) {
 		return
 	}
 
-	createdAt := time.Now()
-	query := `
-		INSERT INTO tasks (title, description, status, created_at, user_id)
-		VALUES (?, ?, 'pending', ?, ?)
-	`
-	res, err := db.DB.Exec(query, newTask.Title, newTask.Description, createdAt, userID)
+	created, err := h.Tasks.Create(r.Context(), userID, newTask)
 	if err != nil {
-		http.Error(w, i18n.T("error.create_task_failed"), http.StatusInternalServerError)
-		return
-	}
-
-	taskID, err := res.LastInsertId()
-	if err != nil {
-		http.Error(w, i18n.T("error.retrieve_task_id_failed"), http.StatusInternalServerError)
-		return
-	}
-
-	var created Task
-	query = `
-		SELECT t.id, t.title, t.description, t.status, t.created_at, t.user_id, u.username
-		FROM tasks t
-		JOIN users u ON t.user_id = u.id
-		WHERE t.id = ?
-	`
-	err = db.DB.QueryRow(query, taskID).Scan(
-		&created.ID,
-		&created.Title,
-		&created.Description,
-		&created.Status,
-		&created.CreatedAt,
-		&created.UserID,
-		&created.Username,
-	)
-	if err != nil {
-		http.Error(w, i18n.T("error.get_task_failed"), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.TaskCreateFailed)
 		return
 	}
 
@@ -119,11 +110,11 @@ func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(created)
 }
 
-// UpdateTaskHandler updates an existing task owned by the authenticated user.
-func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserIDFromAuthHeader(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+// Update updates an existing task owned by the authenticated user.
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := getUserIDFromAuthHeader(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
 		return
 	}
 
@@ -131,88 +122,79 @@ func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	idStr = strings.TrimSuffix(idStr, "/update")
 	taskID, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, i18n.T("error.invalid_id"), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
 		return
 	}
 
 	var updatedTask Task
-	if err := json.NewDecoder(r.Body).Decode(&updatedTask); err != nil {
-		http.Error(w, i18n.T("error.invalid_data"), http.StatusBadRequest)
+	if !validate.Decode(w, r, &updatedTask) {
 		return
 	}
+	updatedTask.ID = taskID
 
-	query := `
-		UPDATE tasks
-		SET title = ?, description = ?, status = ?
-		WHERE id = ? AND user_id = ?
-	`
-	_, err = db.DB.Exec(query, updatedTask.Title, updatedTask.Description, updatedTask.Status, taskID, userID)
-	if err != nil {
-		http.Error(w, i18n.T("error.update_task_failed"), http.StatusInternalServerError)
+	err = h.Tasks.Update(r.Context(), userID, updatedTask)
+	if err == ErrTaskNotFound {
+		problem.Write(w, r, http.StatusNotFound, problem.TaskNotFound)
+		return
+	} else if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.TaskUpdateFailed)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": i18n.T("message.task_updated")})
+	json.NewEncoder(w).Encode(map[string]string{"message": i18n.Translate(r.Context(), "message.task_updated")})
 }
 
-// DeleteTaskHandler deletes a task owned by the authenticated user.
-func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
-	userID, err := getUserIDFromAuthHeader(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+// Delete deletes a task owned by the authenticated user.
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := getUserIDFromAuthHeader(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
 		return
 	}
 
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) < 4 {
-		http.Error(w, i18n.T("error.invalid_id"), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
 		return
 	}
 	taskID, err := strconv.Atoi(parts[3])
 	if err != nil {
-		http.Error(w, i18n.T("error.invalid_id"), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
 		return
 	}
 
-	query := `DELETE FROM tasks WHERE id = ? AND user_id = ?`
-	res, err := db.DB.Exec(query, taskID, userID)
-	if err != nil {
-		http.Error(w, i18n.T("error.delete_task_failed"), http.StatusInternalServerError)
+	err = h.Tasks.Delete(r.Context(), userID, taskID)
+	if err == ErrTaskNotFound {
+		problem.Write(w, r, http.StatusNotFound, problem.TaskNotFound)
 		return
-	}
-
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, i18n.T("error.task_not_found_or_not_owned"), http.StatusNotFound)
+	} else if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.TaskDeleteFailed)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": i18n.T("message.task_deleted")})
+	json.NewEncoder(w).Encode(map[string]string{"message": i18n.Translate(r.Context(), "message.task_deleted")})
 }
 
 // getUserIDFromAuthHeader extracts and validates the user ID from the Authorization header.
-func getUserIDFromAuthHeader(r *http.Request) (int, error) {
+// Requests that already went through AuthMiddleware (JWT or personal access token)
+// carry the user ID in their context. On failure it returns the code of the error.
+func getUserIDFromAuthHeader(r *http.Request) (int, problem.Code) {
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		return userID, ""
+	}
+
 	authHeader := r.Header.Get("Authorization")
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return 0, &authError{i18n.T("error.token_not_provided")}
+		return 0, problem.TokenMissing
 	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 	userID, err := auth.ParseToken(token)
 	if err != nil {
-		return 0, &authError{i18n.T("error.invalid_token")}
+		return 0, problem.InvalidToken
 	}
 
-	return userID, nil
-}
-
-// authError implements error interface for authentication errors.
-type authError struct {
-	message string
-}
-
-func (e *authError) Error() string {
-	return e.message
+	return userID, ""
 }
@@ -0,0 +1,195 @@
+package task_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/db/dbtest"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/task"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// api serves the task API over one of the backends.
+type api struct {
+	t        *testing.T
+	handler  http.Handler
+	accounts *user.Service
+}
+
+// backends create the repositories the API is tested over: in memory, and in
+// the SQL database of dbtest, SQLite unless TEST_DB_DRIVER names another.
+var backends = map[string]func(t *testing.T) (user.UserRepository, task.TaskRepository){
+	"memory": func(t *testing.T) (user.UserRepository, task.TaskRepository) {
+		users := user.NewMemoryUserRepository()
+		return users, task.NewMemoryTaskRepository(users)
+	},
+	"sql": func(t *testing.T) (user.UserRepository, task.TaskRepository) {
+		conn := dbtest.Open(t)
+		return user.NewSQLUserRepository(conn), task.NewSQLTaskRepository(conn)
+	},
+}
+
+// runAPI runs test against the task API over each backend.
+func runAPI(t *testing.T, test func(t *testing.T, a *api)) {
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			users, tasks := backend(t)
+			accounts := &user.Service{Users: users}
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/tasks/", task.NewHandler(tasks, accounts).Router)
+			test(t, &api{t: t, handler: mux, accounts: accounts})
+		})
+	}
+}
+
+// newUser creates an account and returns its ID and a token.
+func (a *api) newUser(username string) (int, string) {
+	userID, err := a.accounts.Users.Create(context.Background(), &models.RegisterRequest{
+		Name: "Test", Surname: "User", Username: username, Email: username + "@example.com",
+	}, nil)
+	assert.NoError(a.t, err)
+	token, err := auth.GenerateJWT(userID)
+	assert.NoError(a.t, err)
+	return userID, token
+}
+
+// do sends a request as the owner of token and decodes the JSON response into out.
+func (a *api) do(method, path, token, body string, out any) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	if out != nil {
+		assert.NoError(a.t, json.NewDecoder(rec.Body).Decode(out), rec.Body.String())
+	}
+	return rec.Code
+}
+
+// list returns the titles of the tasks of the owner of token.
+func (a *api) list(path, token string) []string {
+	var body struct{ Tasks []task.Task }
+	assert.Equal(a.t, http.StatusOK, a.do(http.MethodGet, path, token, "", &body))
+	titles := []string{}
+	for _, t := range body.Tasks {
+		titles = append(titles, t.Title)
+	}
+	return titles
+}
+
+// TestTaskAPI verifies creating, listing, updating and deleting tasks
+func TestTaskAPI(t *testing.T) {
+	runAPI(t, func(t *testing.T, a *api) {
+		_, token := a.newUser("thor")
+		_, other := a.newUser("loki")
+
+		var created task.Task
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/tasks/create", token,
+			`{"title":"Write report","description":"Q3","due_date":"2020-01-31"}`, &created))
+		assert.Equal(t, "Write report", created.Title)
+		assert.Equal(t, "pending", created.Status)
+		assert.Equal(t, "thor", created.Username)
+		assert.Equal(t, "2020-01-31", *created.DueDate)
+
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/tasks/create", token, `{"title":"Buy milk"}`, nil))
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/tasks/create", other, `{"title":"Mischief"}`, nil))
+
+		var list struct{ Tasks []task.Task }
+		assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/tasks/", token, "", &list))
+		assert.Len(t, list.Tasks, 2)
+		assert.True(t, list.Tasks[0].Overdue)
+		assert.False(t, list.Tasks[1].Overdue)
+		assert.Equal(t, []string{"Buy milk", "Write report"}, a.list("/api/tasks/?sort=title", token))
+		assert.Equal(t, []string{"Mischief"}, a.list("/api/tasks/", other))
+
+		var failure problem.Problem
+		assert.Equal(t, http.StatusBadRequest, a.do(http.MethodGet, "/api/tasks/?sort=random", token, "", &failure))
+		assert.Equal(t, problem.InvalidSort, failure.Code)
+
+		// Completed tasks are no longer overdue
+		path := "/api/tasks/" + strconv.Itoa(created.ID) + "/update"
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPut, path, token,
+			`{"id":1,"title":"Write report","description":"Q3","status":"completed","due_date":"2020-01-31","overdue":true}`, nil))
+		assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/tasks/?sort=due_date", token, "", &list))
+		assert.Equal(t, "completed", list.Tasks[0].Status)
+		assert.False(t, list.Tasks[0].Overdue)
+
+		// Saving a task unchanged is not taken for a missing one
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPut, path, token,
+			`{"title":"Write report","description":"Q3","status":"completed","due_date":"2020-01-31"}`, nil))
+
+		// Omitting the status keeps the stored one
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPut, path, token,
+			`{"title":"Write report","description":"Q4","due_date":"2020-01-31"}`, nil))
+		assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/tasks/?sort=due_date", token, "", &list))
+		assert.Equal(t, "Q4", list.Tasks[0].Description)
+		assert.Equal(t, "completed", list.Tasks[0].Status)
+
+		// Tasks of other users cannot be changed or deleted
+		assert.Equal(t, http.StatusNotFound, a.do(http.MethodPut, path, other, `{"title":"Hijacked","status":"pending"}`, &failure))
+		assert.Equal(t, problem.TaskNotFound, failure.Code)
+		assert.Equal(t, []string{"Write report", "Buy milk"}, a.list("/api/tasks/?sort=due_date", token))
+		assert.Equal(t, http.StatusNotFound, a.do(http.MethodPut, "/api/tasks/999/update", token, `{"title":"Missing","status":"pending"}`, &failure))
+		assert.Equal(t, problem.TaskNotFound, failure.Code)
+		assert.Equal(t, http.StatusNotFound, a.do(http.MethodDelete, "/api/tasks/"+strconv.Itoa(created.ID), other, "", &failure))
+		assert.Equal(t, problem.TaskNotFound, failure.Code)
+
+		assert.Equal(t, http.StatusOK, a.do(http.MethodDelete, "/api/tasks/"+strconv.Itoa(created.ID), token, "", nil))
+		assert.Equal(t, []string{"Buy milk"}, a.list("/api/tasks/", token))
+	})
+}
+
+// TestTaskAPIValidation verifies that invalid tasks are rejected field by field
+func TestTaskAPIValidation(t *testing.T) {
+	runAPI(t, func(t *testing.T, a *api) {
+		_, token := a.newUser("thor")
+
+		var failure problem.Problem
+		assert.Equal(t, http.StatusBadRequest, a.do(http.MethodPost, "/api/tasks/create", token,
+			`{"title":" ","due_date":"tomorrow"}`, &failure))
+		assert.Equal(t, problem.ValidationFailed, failure.Code)
+		assert.Equal(t, []string{"title", "due_date"}, []string{failure.Errors[0].Field, failure.Errors[1].Field})
+
+		assert.Equal(t, http.StatusBadRequest, a.do(http.MethodPut, "/api/tasks/1/update", token,
+			`{"title":"Ok","status":"archived"}`, &failure))
+		assert.Equal(t, "status", failure.Errors[0].Field)
+
+		assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/tasks/", "not-a-token", "", &failure))
+		assert.Equal(t, problem.InvalidToken, failure.Code)
+		assert.Empty(t, a.list("/api/tasks/", token))
+	})
+}
+
+// TestTaskAPIFollowsPreferences verifies the default order and the time zone of the overdue flag
+func TestTaskAPIFollowsPreferences(t *testing.T) {
+	runAPI(t, func(t *testing.T, a *api) {
+		userID, token := a.newUser("thor")
+
+		// Today in the time zone furthest ahead is overdue for nobody yet
+		today := time.Now().In(time.FixedZone("", 14*3600)).Format(time.DateOnly)
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/tasks/create", token, `{"title":"b","due_date":"`+today+`"}`, nil))
+		assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/tasks/create", token, `{"title":"a"}`, nil))
+
+		prefs := user.DefaultPreferences()
+		prefs.TaskSort = user.TaskSortTitle
+		prefs.TimeZone = "Pacific/Kiritimati"
+		_, err := a.accounts.SetPreferences(context.Background(), userID, prefs)
+		assert.NoError(t, err)
+
+		var list struct{ Tasks []task.Task }
+		assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/tasks/", token, "", &list))
+		assert.Equal(t, "a", list.Tasks[0].Title)
+		assert.False(t, list.Tasks[1].Overdue)
+	})
+}
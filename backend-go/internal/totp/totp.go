@@ -0,0 +1,112 @@
+// Package totp implements RFC 6238 time-based one-time passwords (HMAC-SHA1).
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// secretEncoding is the base32 alphabet used by authenticator apps, without padding.
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Generator computes and validates TOTP codes.
+// Now can be replaced to control the clock in tests.
+type Generator struct {
+	Period time.Duration
+	Digits int
+	// Skew is the number of time steps accepted before and after the current one.
+	Skew int
+	Now  func() time.Time
+}
+
+// New returns a Generator with the defaults used by common authenticator apps:
+// 30 second period, 6 digits and one step of clock skew.
+func New() *Generator {
+	return &Generator{Period: 30 * time.Second, Digits: 6, Skew: 1, Now: time.Now}
+}
+
+// GenerateSecret creates a random 160-bit secret encoded as base32.
+func GenerateSecret() (string, error) {
+	bytes := make([]byte, 20)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return secretEncoding.EncodeToString(bytes), nil
+}
+
+// Counter returns the time step that contains t.
+func (g *Generator) Counter(t time.Time) int64 {
+	return t.Unix() / int64(g.Period/time.Second)
+}
+
+// Code returns the code for the time step that contains t.
+func (g *Generator) Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return g.codeAt(key, g.Counter(t)), nil
+}
+
+// Validate checks the code against the current time step and the allowed skew.
+// It returns the matching time step so callers can reject replayed codes.
+func (g *Generator) Validate(secret, code string) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != g.Digits {
+		return 0, false
+	}
+
+	current := g.Counter(g.Now())
+	for offset := -g.Skew; offset <= g.Skew; offset++ {
+		counter := current + int64(offset)
+		if subtle.ConstantTimeCompare([]byte(g.codeAt(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// URI builds the otpauth:// URI used to enroll the secret in an authenticator app.
+func (g *Generator) URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(g.Digits))
+	params.Set("period", fmt.Sprint(int(g.Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// codeAt computes the HOTP value (RFC 4226) for the given counter.
+func (g *Generator) codeAt(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < g.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", g.Digits, value%mod)
+}
+
+// decodeSecret accepts base32 secrets with or without padding, in any case.
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	return secretEncoding.DecodeString(secret)
+}
@@ -0,0 +1,75 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 key used by the RFC 6238 test vectors ("12345678901234567890").
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// TestCode_RFC6238Vectors checks the 8-digit SHA1 reference values from RFC 6238 Appendix B
+func TestCode_RFC6238Vectors(t *testing.T) {
+	generator := totp.New()
+	generator.Digits = 8
+
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, expected := range vectors {
+		code, err := generator.Code(rfcSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+// TestValidate_InjectedClock verifies skew handling using a fixed clock
+func TestValidate_InjectedClock(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	generator := totp.New()
+	generator.Now = func() time.Time { return now }
+
+	current, err := generator.Code(rfcSecret, now)
+	assert.NoError(t, err)
+	previous, err := generator.Code(rfcSecret, now.Add(-30*time.Second))
+	assert.NoError(t, err)
+	stale, err := generator.Code(rfcSecret, now.Add(-90*time.Second))
+	assert.NoError(t, err)
+
+	counter, ok := generator.Validate(rfcSecret, current)
+	assert.True(t, ok)
+	assert.Equal(t, generator.Counter(now), counter)
+
+	counter, ok = generator.Validate(rfcSecret, previous)
+	assert.True(t, ok)
+	assert.Equal(t, generator.Counter(now)-1, counter)
+
+	_, ok = generator.Validate(rfcSecret, stale)
+	assert.False(t, ok)
+
+	_, ok = generator.Validate(rfcSecret, "12345")
+	assert.False(t, ok)
+}
+
+// TestURI verifies the otpauth:// enrollment URI
+func TestURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+
+	uri := totp.New().URI("Task Manager", "thor@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Task%20Manager:thor@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "digits=6")
+	assert.Contains(t, uri, "period=30")
+}
@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"task-manager/backend-go/db"
+	"task-manager/backend-go/models"
+)
+
+// AccessTokenRepository stores personal access tokens by the hash of their secret.
+type AccessTokenRepository interface {
+	// Create stores a token of the user and returns its ID.
+	Create(ctx context.Context, userID int, token *models.AccessToken, tokenHash string) (int, error)
+	// List returns the tokens of the user that are not revoked, newest first.
+	List(ctx context.Context, userID int) ([]models.AccessToken, error)
+	// Revoke revokes a token of the user, ErrAccessTokenNotFound when it has
+	// no such token or it is already revoked.
+	Revoke(ctx context.Context, userID, tokenID int, at time.Time) error
+	// Find returns the owner of the token that is not revoked with the hash,
+	// and the token, sql.ErrNoRows when there is none.
+	Find(ctx context.Context, tokenHash string) (int, models.AccessToken, error)
+	// MarkUsed records when the token was last used.
+	MarkUsed(ctx context.Context, tokenID int, at time.Time) error
+}
+
+// SQLAccessTokenRepository stores tokens in the personal_access_tokens table.
+type SQLAccessTokenRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLAccessTokenRepository creates a repository over db.
+func NewSQLAccessTokenRepository(db *sql.DB) *SQLAccessTokenRepository {
+	return &SQLAccessTokenRepository{DB: db}
+}
+
+func (r *SQLAccessTokenRepository) Create(ctx context.Context, userID int, token *models.AccessToken, tokenHash string) (int, error) {
+	id, err := db.DialectOf(r.DB).Insert(ctx, r.DB,
+		"INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, token.Name, tokenHash, strings.Join(token.Scopes, " "), token.CreatedAt, token.ExpiresAt,
+	)
+	return int(id), err
+}
+
+func (r *SQLAccessTokenRepository) List(ctx context.Context, userID int) ([]models.AccessToken, error) {
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT id, name, scopes, created_at, expires_at, last_used_at
+		FROM personal_access_tokens
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.AccessToken{}
+	for rows.Next() {
+		var token models.AccessToken
+		var scopes string
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&token.ID, &token.Name, &scopes, &token.CreatedAt, &token.ExpiresAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		if lastUsed.Valid {
+			token.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *SQLAccessTokenRepository) Revoke(ctx context.Context, userID, tokenID int, at time.Time) error {
+	revoked, err := affected(r.DB.ExecContext(ctx,
+		"UPDATE personal_access_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		at, tokenID, userID,
+	))
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (r *SQLAccessTokenRepository) Find(ctx context.Context, tokenHash string) (int, models.AccessToken, error) {
+	var userID int
+	var token models.AccessToken
+	var scopes string
+	var lastUsed sql.NullTime
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT id, user_id, scopes, expires_at, last_used_at FROM personal_access_tokens WHERE token_hash = ? AND revoked_at IS NULL",
+		tokenHash,
+	).Scan(&token.ID, &userID, &scopes, &token.ExpiresAt, &lastUsed)
+	token.Scopes = strings.Fields(scopes)
+	if lastUsed.Valid {
+		token.LastUsedAt = &lastUsed.Time
+	}
+	return userID, token, err
+}
+
+func (r *SQLAccessTokenRepository) MarkUsed(ctx context.Context, tokenID int, at time.Time) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?", at, tokenID)
+	return err
+}
+
+// memoryAccessToken is a token kept by MemoryAccessTokenRepository.
+type memoryAccessToken struct {
+	models.AccessToken
+	userID    int
+	hash      string
+	revokedAt *time.Time
+}
+
+// MemoryAccessTokenRepository keeps tokens in a MemoryUserRepository, for tests.
+type MemoryAccessTokenRepository struct {
+	users  *MemoryUserRepository
+	nextID int
+}
+
+// NewMemoryAccessTokenRepository creates a repository over the accounts of users.
+func NewMemoryAccessTokenRepository(users *MemoryUserRepository) *MemoryAccessTokenRepository {
+	return &MemoryAccessTokenRepository{users: users}
+}
+
+func (r *MemoryAccessTokenRepository) Create(ctx context.Context, userID int, token *models.AccessToken, tokenHash string) (int, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	r.nextID++
+	stored := memoryAccessToken{AccessToken: *token, userID: userID, hash: tokenHash}
+	stored.ID, stored.Token = r.nextID, ""
+	r.users.accessTokens = append(r.users.accessTokens, &stored)
+	return r.nextID, nil
+}
+
+func (r *MemoryAccessTokenRepository) List(ctx context.Context, userID int) ([]models.AccessToken, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	tokens := []models.AccessToken{}
+	for _, token := range r.users.accessTokens {
+		if token.userID == userID && token.revokedAt == nil {
+			tokens = append(tokens, token.AccessToken)
+		}
+	}
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (r *MemoryAccessTokenRepository) Revoke(ctx context.Context, userID, tokenID int, at time.Time) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, token := range r.users.accessTokens {
+		if token.ID == tokenID && token.userID == userID && token.revokedAt == nil {
+			token.revokedAt = &at
+			return nil
+		}
+	}
+	return ErrAccessTokenNotFound
+}
+
+func (r *MemoryAccessTokenRepository) Find(ctx context.Context, tokenHash string) (int, models.AccessToken, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, token := range r.users.accessTokens {
+		if token.hash == tokenHash && token.revokedAt == nil {
+			return token.userID, token.AccessToken, nil
+		}
+	}
+	return 0, models.AccessToken{}, sql.ErrNoRows
+}
+
+func (r *MemoryAccessTokenRepository) MarkUsed(ctx context.Context, tokenID int, at time.Time) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, token := range r.users.accessTokens {
+		if token.ID == tokenID {
+			token.LastUsedAt = &at
+		}
+	}
+	return nil
+}
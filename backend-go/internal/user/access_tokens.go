@@ -0,0 +1,168 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+const (
+	// defaultAccessTokenDays is used when the request does not set an expiration.
+	defaultAccessTokenDays = 30
+	// maxAccessTokenDays is the longest lifetime a personal access token can have.
+	maxAccessTokenDays = 365
+	// lastUsedInterval limits how often the last-used time of a token is written.
+	lastUsedInterval = time.Minute
+)
+
+var (
+	// ErrAccessTokenNotFound is returned when a token does not exist or belongs to another user.
+	ErrAccessTokenNotFound = errors.New("access_token_not_found")
+	// ErrInvalidAccessToken is returned when a presented token is unknown, expired or revoked.
+	ErrInvalidAccessToken = errors.New("invalid_access_token")
+	// ErrInvalidAccessTokenRequest is returned when the name, scopes or expiration are not acceptable.
+	ErrInvalidAccessTokenRequest = errors.New("invalid_access_token_request")
+)
+
+// CreateAccessToken creates a named, scoped personal access token for the user.
+// The returned value includes the plaintext token, which is not stored and
+// cannot be retrieved again.
+func (s *Service) CreateAccessToken(ctx context.Context, userID int, req *models.CreateAccessTokenRequest) (*models.AccessToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 || len(req.Scopes) == 0 {
+		return nil, ErrInvalidAccessTokenRequest
+	}
+	for _, scope := range req.Scopes {
+		if !auth.IsValidScope(scope) {
+			return nil, ErrInvalidAccessTokenRequest
+		}
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAccessTokenDays
+	}
+	if days < 0 || days > maxAccessTokenDays {
+		return nil, ErrInvalidAccessTokenRequest
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, err
+	}
+	plaintext := auth.AccessTokenPrefix + hex.EncodeToString(bytes)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	token := &models.AccessToken{
+		Name:      name,
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+		ExpiresAt: now.AddDate(0, 0, days),
+		Token:     plaintext,
+	}
+
+	id, err := s.AccessTokens.Create(ctx, userID, token, hashToken(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	token.ID = id
+
+	return token, nil
+}
+
+// ListAccessTokens returns the user's personal access tokens that are not revoked.
+func (s *Service) ListAccessTokens(ctx context.Context, userID int) ([]models.AccessToken, error) {
+	return s.AccessTokens.List(ctx, userID)
+}
+
+// RevokeAccessToken revokes a personal access token owned by the user.
+func (s *Service) RevokeAccessToken(ctx context.Context, userID, tokenID int) error {
+	return s.AccessTokens.Revoke(ctx, userID, tokenID, time.Now().UTC())
+}
+
+// VerifyAccessToken looks up a presented personal access token by its hash and
+// records when it was last used. It implements auth.AccessTokenVerifier.
+func (s *Service) VerifyAccessToken(ctx context.Context, token string) (*auth.AccessToken, error) {
+	userID, stored, err := s.AccessTokens.Find(ctx, hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAccessToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !stored.ExpiresAt.After(now) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) > lastUsedInterval {
+		if err := s.AccessTokens.MarkUsed(ctx, stored.ID, now.UTC()); err != nil {
+			log.Printf("Error updating access token last used: %v", err)
+		}
+	}
+
+	return &auth.AccessToken{ID: stored.ID, UserID: userID, Scopes: stored.Scopes}, nil
+}
+
+// AccessTokens lists (GET) and creates (POST) personal access tokens on
+// /api/user/tokens, and revokes one with DELETE /api/user/tokens/{id}.
+func (h *Handler) AccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/user/tokens"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && idStr == "":
+		tokens, err := h.Service.ListAccessTokens(r.Context(), userID)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.AccessTokenQueryFailed)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]any{"tokens": tokens})
+
+	case r.Method == http.MethodPost && idStr == "":
+		var req models.CreateAccessTokenRequest
+		if !validate.Decode(w, r, &req) {
+			return
+		}
+		token, err := h.Service.CreateAccessToken(r.Context(), userID, &req)
+		if err == ErrInvalidAccessTokenRequest {
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidAccessTokenRequest)
+			return
+		} else if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.AccessTokenCreateFailed)
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, token)
+
+	case r.Method == http.MethodDelete && idStr != "":
+		tokenID, err := strconv.Atoi(idStr)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+			return
+		}
+		err = h.Service.RevokeAccessToken(r.Context(), userID, tokenID)
+		if err == ErrAccessTokenNotFound {
+			problem.Write(w, r, http.StatusNotFound, problem.AccessTokenNotFound)
+			return
+		} else if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.AccessTokenRevokeFailed)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "token.success.revoked")})
+
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+	}
+}
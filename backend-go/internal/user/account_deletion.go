@@ -0,0 +1,186 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+// DeletionGracePeriod is how long a deleted account can still be recovered by
+// logging in again; set from configuration in main.
+var DeletionGracePeriod = 14 * 24 * time.Hour
+
+// reauthenticationWindow is how recently an account without a password must
+// have signed in to confirm its deletion.
+const reauthenticationWindow = 5 * time.Minute
+
+// ErrReauthenticationRequired is returned when an account without a password
+// asks for its deletion from a session older than reauthenticationWindow.
+var ErrReauthenticationRequired = errors.New("reauthentication_required")
+
+// userTables lists the tables holding rows owned by a user, deleted with the account.
+var userTables = []string{
+	"tasks",
+	"sessions",
+	"mfa_recovery_codes",
+	"personal_access_tokens",
+	"user_identities",
+	"data_exports",
+	"magic_links",
+	"password_resets",
+	"digest_subscriptions",
+	"user_preferences",
+}
+
+// RequestAccountDeletion schedules the account for deletion after
+// DeletionGracePeriod, once the password is confirmed. Accounts without a
+// password, such as those provisioned through OIDC, confirm by having signed
+// in to sessionID within reauthenticationWindow. Every session and personal
+// access token is revoked; logging in again cancels the deletion.
+func (s *Service) RequestAccountDeletion(ctx context.Context, userID int, sessionID, password string) (time.Time, error) {
+	if err := s.confirmDeletion(ctx, userID, sessionID, password); err != nil {
+		return time.Time{}, err
+	}
+
+	scheduledAt := time.Now().UTC().Add(DeletionGracePeriod)
+	if err := s.Users.ScheduleDeletion(ctx, userID, scheduledAt); err != nil {
+		return time.Time{}, err
+	}
+
+	return scheduledAt, s.RevokeAllSessions(ctx, userID, "")
+}
+
+// confirmDeletion checks the password of the account, or the age of the
+// session when it has none.
+func (s *Service) confirmDeletion(ctx context.Context, userID int, sessionID, password string) error {
+	hashedPassword, err := s.Users.PasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if hashedPassword != nil {
+		return s.checkPassword(ctx, userID, password)
+	}
+
+	session, err := s.Sessions.Get(ctx, userID, sessionID)
+	if err == ErrSessionNotFound || (err == nil && time.Since(session.CreatedAt) > reauthenticationWindow) {
+		return ErrReauthenticationRequired
+	}
+	return err
+}
+
+// cancelAccountDeletion clears a pending deletion; called whenever the user signs in.
+func (s *Service) cancelAccountDeletion(ctx context.Context, userID int) error {
+	return s.Users.CancelDeletion(ctx, userID)
+}
+
+// PurgeDeletedAccounts permanently removes accounts whose grace period is over,
+// together with their data. It returns the number of deleted accounts.
+func (s *Service) PurgeDeletedAccounts(ctx context.Context, now time.Time) (int, error) {
+	userIDs, err := s.Users.DueDeletions(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, userID := range userIDs {
+		if err := s.deleteAccount(ctx, userID); err != nil {
+			return i, err
+		}
+	}
+	return len(userIDs), nil
+}
+
+// deleteAccount removes the user and everything it owns: its rows, the emails
+// queued for its address, its failed login counters and its export archives.
+func (s *Service) deleteAccount(ctx context.Context, userID int) error {
+	files, err := s.Exports.Files(ctx, userID)
+	if err != nil {
+		return err
+	}
+	user, err := s.Users.Summary(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.Users.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	// The counters live in the store of LoginGuard, which may not be this database
+	if err := LoginGuard.Success(ctx, user.Username); err != nil {
+		log.Printf("Error clearing login attempts of deleted account %d: %v", userID, err)
+	}
+	if err := LoginGuard.SuccessMFA(ctx, userID); err != nil {
+		log.Printf("Error clearing login attempts of deleted account %d: %v", userID, err)
+	}
+
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing export %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// PurgeEvery deletes expired accounts, data exports and login counters every
+// interval, until stop is closed.
+func (s *Service) PurgeEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if _, err := s.PurgeDeletedAccounts(context.Background(), now); err != nil {
+				log.Printf("%s: %v", i18n.T("error.account.purge"), err)
+			}
+			if err := s.PurgeExpiredExports(context.Background(), now); err != nil {
+				log.Printf("%s: %v", i18n.T("error.account.purge"), err)
+			}
+		}
+	}
+}
+
+// DeleteAccount schedules the authenticated user's account for deletion.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if !validate.Decode(w, r, &req) {
+		return
+	}
+
+	sessionID, _ := auth.SessionIDFromContext(r.Context())
+	scheduledAt, err := h.Service.RequestAccountDeletion(r.Context(), userID, sessionID, req.Password)
+	if err == ErrReauthenticationRequired {
+		problem.Write(w, r, http.StatusForbidden, problem.ReauthenticationRequired)
+		return
+	}
+	if err != nil {
+		respondWithCredentialsError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]any{
+		"message":               i18n.Translate(r.Context(), "user.success.deletion_scheduled"),
+		"deletion_scheduled_at": scheduledAt,
+	})
+}
@@ -0,0 +1,178 @@
+package user_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/oidc"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAccountDeletion verifies confirmation, the grace period and the final purge
+func TestAccountDeletion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	service := user.NewService(db)
+	userID, token := loginTestUser(t, service, db)
+	ctx := context.Background()
+
+	previous := user.LoginGuard
+	defer func() { user.LoginGuard = previous }()
+	user.LoginGuard = user.NewLoginGuard(loginguard.NewSQLStore(db), service)
+	// No backoff after the wrong password
+	user.LoginGuard.Account.BaseDelay = 0
+
+	_, err := db.Exec("INSERT INTO tasks (user_id, title) VALUES (?, ?)", userID, "Forge Mjolnir")
+	assert.NoError(t, err)
+
+	_, err = service.RequestAccountDeletion(ctx, userID, "", "wrong")
+	assert.Equal(t, user.ErrIncorrectPassword, err)
+
+	scheduledAt, err := service.RequestAccountDeletion(ctx, userID, "", testPassword)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(user.DeletionGracePeriod), scheduledAt, time.Minute)
+	_, err = auth.ParseClaims(token)
+	assert.Error(t, err, "sessions are revoked")
+
+	// Logging in during the grace period keeps the account
+	secondSession(t, service, testPassword)
+	deleted, err := service.PurgeDeletedAccounts(ctx, time.Now().Add(user.DeletionGracePeriod+time.Hour))
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	_, err = service.RequestAccountDeletion(ctx, userID, "", testPassword)
+	assert.NoError(t, err)
+	deleted, err = service.PurgeDeletedAccounts(ctx, time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, deleted, "nothing is deleted before the grace period ends")
+
+	// Failed logins and queued emails of the account go with it
+	assert.NoError(t, user.LoginGuard.Failure(ctx, testUsername, "192.0.2.1"))
+	assert.NoError(t, user.LoginGuard.FailureMFA(ctx, userID, "192.0.2.1"))
+	_, err = db.Exec(`INSERT INTO email_outbox (idempotency_key, sender, recipients, subject, text_body, html_body, status, next_attempt_at, created_at)
+		VALUES ('digest:1', 'tasks@example.com', ?, 'Digest', '', '', 'pending', ?, ?)`, testEmail, time.Now(), time.Now())
+	assert.NoError(t, err)
+	var emails int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM email_outbox WHERE recipients = ?", testEmail).Scan(&emails))
+	assert.NotZero(t, emails)
+
+	deleted, err = service.PurgeDeletedAccounts(ctx, time.Now().Add(user.DeletionGracePeriod+time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	var users, tasks, attempts int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM users").Scan(&users))
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM tasks").Scan(&tasks))
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM email_outbox WHERE recipients = ?", testEmail).Scan(&emails))
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM login_attempts WHERE attempt_key <> ?", "ip:192.0.2.1").Scan(&attempts))
+	assert.Zero(t, users)
+	assert.Zero(t, tasks)
+	assert.Zero(t, emails)
+	assert.Zero(t, attempts)
+}
+
+// TestAccountDeletion_Passwordless verifies that accounts without a password
+// confirm their deletion with a recent sign-in
+func TestAccountDeletion_Passwordless(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	service := user.NewService(db)
+	ctx := context.Background()
+
+	token, err := service.LoginWithOIDC(ctx, "https://idp.example.com", &oidc.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-loki"},
+		Email:            "loki@example.com",
+		EmailVerified:    true,
+	}, "test-agent", "192.0.2.1")
+	assert.NoError(t, err)
+	claims, err := auth.ParseClaims(token)
+	assert.NoError(t, err)
+
+	// A session from earlier on is not enough
+	_, err = db.Exec("UPDATE sessions SET created_at = ? WHERE id = ?", time.Now().Add(-time.Hour).UTC(), claims.ID)
+	assert.NoError(t, err)
+	_, err = service.RequestAccountDeletion(ctx, claims.UserID, claims.ID, "")
+	assert.Equal(t, user.ErrReauthenticationRequired, err)
+
+	_, err = db.Exec("UPDATE sessions SET created_at = ? WHERE id = ?", time.Now().UTC(), claims.ID)
+	assert.NoError(t, err)
+	_, err = service.RequestAccountDeletion(ctx, claims.UserID, claims.ID, "")
+	assert.NoError(t, err)
+}
+
+// TestDataExport verifies the archive content and the one-time download link
+func TestDataExport(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	service := user.NewService(db)
+	userID, _ := loginTestUser(t, service, db)
+	ctx := context.Background()
+
+	previous := user.ExportDir
+	defer func() { user.ExportDir = previous }()
+	user.ExportDir = t.TempDir()
+
+	_, err := db.Exec("INSERT INTO tasks (user_id, title, description) VALUES (?, ?, ?)", userID, "Forge Mjolnir", "At Nidavellir")
+	assert.NoError(t, err)
+
+	first, err := service.StartExport(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ExportReady, first.Status)
+
+	// A second request reuses the export and replaces its link
+	export, err := service.StartExport(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, export.ID)
+	_, err = service.OpenExport(ctx, strings.TrimPrefix(first.DownloadURL, "/export/download?token="))
+	assert.Equal(t, user.ErrExportNotFound, err)
+	token := strings.TrimPrefix(export.DownloadURL, "/export/download?token=")
+
+	status, err := service.GetExport(ctx, userID, export.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ExportReady, status.Status)
+	_, err = service.GetExport(ctx, userID+1, export.ID)
+	assert.Equal(t, user.ErrExportNotFound, err)
+
+	archive, err := service.OpenExport(ctx, token)
+	assert.NoError(t, err)
+	content, err := io.ReadAll(archive)
+	assert.NoError(t, err)
+	assert.NoError(t, archive.Close())
+
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	assert.NoError(t, err)
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	assert.Contains(t, files, "profile.json")
+	assert.Contains(t, files, "sessions.json")
+
+	tasksFile, err := files["tasks.json"].Open()
+	assert.NoError(t, err)
+	var tasks []models.Task
+	assert.NoError(t, json.NewDecoder(tasksFile).Decode(&tasks))
+	tasksFile.Close()
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, "Forge Mjolnir", tasks[0].Title)
+
+	// The link works once and the archive is removed afterwards
+	_, err = service.OpenExport(ctx, token)
+	assert.Equal(t, user.ErrExportNotFound, err)
+	entries, err := os.ReadDir(user.ExportDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
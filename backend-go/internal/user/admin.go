@@ -0,0 +1,102 @@
+package user
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/outbox"
+	"task-manager/backend-go/internal/problem"
+)
+
+// Admins lists the usernames allowed to use the admin endpoints; set from configuration in main.
+var Admins []string
+
+// IsAdmin reports whether the user is listed in Admins.
+func (s *Service) IsAdmin(ctx context.Context, userID int) (bool, error) {
+	user, err := s.Users.Summary(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, admin := range Admins {
+		if strings.EqualFold(admin, user.Username) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RequireAdmin only lets users listed in Admins through. It must be wrapped
+// by AuthMiddleware.
+func (h *Handler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, errCode := authenticatedUserID(r)
+		if errCode != "" {
+			problem.Write(w, r, http.StatusUnauthorized, errCode)
+			return
+		}
+		admin, err := h.Service.IsAdmin(r.Context(), userID)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		if !admin {
+			problem.Write(w, r, http.StatusForbidden, problem.Forbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Outbox lets admins inspect queued emails (GET /api/admin/outbox, with
+// an optional ?status= defaulting to failed) and send a failed one again
+// (POST /api/admin/outbox/{id}/retry).
+func (h *Handler) Outbox(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/outbox"), "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = outbox.StatusFailed
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 || limit > 500 {
+			limit = 100
+		}
+
+		entries, err := outbox.List(r.Context(), h.Service.DB, status, limit)
+		if err == outbox.ErrInvalidStatus {
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidOutboxStatus)
+			return
+		} else if err != nil {
+			log.Printf("Error listing outbox: %v", err)
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, entries)
+
+	case strings.HasSuffix(path, "/retry") && r.Method == http.MethodPost:
+		id, err := strconv.ParseInt(strings.TrimSuffix(path, "/retry"), 10, 64)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidRequest)
+			return
+		}
+
+		err = outbox.Retry(r.Context(), h.Service.DB, id)
+		if err == outbox.ErrNotFound {
+			problem.Write(w, r, http.StatusNotFound, problem.OutboxEmailNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Error retrying outbox entry %d: %v", id, err)
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		respondWithJSON(w, http.StatusAccepted, map[string]string{"message": i18n.Translate(r.Context(), "outbox.retry_scheduled")})
+
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+	}
+}
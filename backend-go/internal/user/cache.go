@@ -0,0 +1,57 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+// expiringCache remembers values until a deadline. It spares the database
+// the lookups made on every authenticated request. The zero value is ready
+// to use.
+type expiringCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	entries map[K]cacheEntry[V]
+	// pruneAt is the size from which expired entries are removed.
+	pruneAt int
+}
+
+type cacheEntry[V any] struct {
+	value V
+	until time.Time
+}
+
+// get returns the value of key unless it expired at now.
+func (c *expiringCache[K, V]) get(key K, now time.Time) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !entry.until.After(now) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// set remembers value for key until the given time.
+func (c *expiringCache[K, V]) set(key K, value V, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[K]cacheEntry[V])
+	}
+	c.entries[key] = cacheEntry[V]{value: value, until: until}
+
+	// Remove expired entries once the map has doubled, so it does not grow
+	// without bound and is not scanned on every call
+	if len(c.entries) >= c.pruneAt {
+		now := time.Now()
+		for key, entry := range c.entries {
+			if !entry.until.After(now) {
+				delete(c.entries, key)
+			}
+		}
+		c.pruneAt = max(2*len(c.entries), 1024)
+	}
+}
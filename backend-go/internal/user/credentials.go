@@ -0,0 +1,286 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/passwordpolicy"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// purposeChangeEmail marks the signed token in the email change confirmation link.
+	purposeChangeEmail = "change_email"
+	// changeEmailTTL bounds how long the confirmation link stays valid.
+	changeEmailTTL = 24 * time.Hour
+)
+
+var (
+	// ErrIncorrectPassword is returned when the current password does not match.
+	ErrIncorrectPassword = errors.New("incorrect_password")
+	// ErrInvalidEmail is returned for malformed email addresses.
+	ErrInvalidEmail = errors.New("invalid_email")
+	// ErrEmailTaken is returned when another account already uses the address.
+	ErrEmailTaken = errors.New("email_taken")
+)
+
+// checkPassword compares password with the stored hash of the user.
+// Accounts without a password (provisioned through OIDC) never match. Wrong
+// passwords count as failed logins of the account in LoginGuard, so a stolen
+// session cannot be used to guess the password; a *loginguard.LockedError is
+// returned while the account must wait.
+func (s *Service) checkPassword(ctx context.Context, userID int, password string) error {
+	user, err := s.Users.Summary(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := LoginGuard.Check(ctx, user.Username, ""); err != nil {
+		if _, ok := err.(*loginguard.LockedError); ok {
+			return err
+		}
+		log.Printf("Error checking login attempts: %v", err)
+	}
+
+	hashedPassword, err := s.Users.PasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if hashedPassword == nil || bcrypt.CompareHashAndPassword(hashedPassword, []byte(password)) != nil {
+		if err := LoginGuard.Failure(ctx, user.Username, ""); err != nil {
+			log.Printf("Error recording login attempt: %v", err)
+		}
+		return ErrIncorrectPassword
+	}
+
+	if err := LoginGuard.Success(ctx, user.Username); err != nil {
+		log.Printf("Error clearing login attempts: %v", err)
+	}
+	return nil
+}
+
+// ChangePassword replaces the password after checking the current one, spends
+// the outstanding reset links and revokes every session except currentSessionID. A new password rejected by
+// PasswordPolicy returns a *passwordpolicy.ValidationError.
+func (s *Service) ChangePassword(ctx context.Context, userID int, currentSessionID string, req *models.ChangePasswordRequest) error {
+	if err := s.checkPassword(ctx, userID, req.CurrentPassword); err != nil {
+		return err
+	}
+	user, err := s.Users.Summary(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := PasswordPolicy.Validate(req.NewPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.Users.SetPassword(ctx, userID, hashedPassword, time.Now().UTC()); err != nil {
+		return err
+	}
+
+	return s.RevokeAllSessions(ctx, userID, currentSessionID)
+}
+
+// RequestEmailChange sends a confirmation link to the new address and a notice
+// to the current one. The address only changes once the link is followed.
+func (s *Service) RequestEmailChange(ctx context.Context, userID int, currentSessionID string, req *models.ChangeEmailRequest) error {
+	if err := s.checkPassword(ctx, userID, req.CurrentPassword); err != nil {
+		return err
+	}
+
+	newEmail := strings.TrimSpace(req.Email)
+	if address, err := mail.ParseAddress(newEmail); err != nil || address.Address != newEmail {
+		return ErrInvalidEmail
+	}
+
+	user, err := s.Users.Summary(ctx, userID)
+	if err != nil {
+		return err
+	}
+	oldEmail := user.Email
+	// The current address counts as taken too: there is nothing to change
+	if err := s.emailAvailable(ctx, newEmail, 0); err != nil {
+		return err
+	}
+
+	token, err := auth.GeneratePurposeToken(purposeChangeEmail, map[string]any{
+		"user_id":    userID,
+		"old_email":  oldEmail,
+		"new_email":  newEmail,
+		"session_id": currentSessionID,
+	}, changeEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	locale := s.recipientLocale(ctx, userID)
+	return s.Users.QueueEmails(ctx,
+		Email{
+			Key:      "change_email:" + hashToken(token),
+			To:       newEmail,
+			Locale:   locale,
+			Template: "change_email",
+			Data:     map[string]any{"URL": publicURL("/confirm-email-change?token=" + token)},
+		},
+		Email{
+			Key:      "change_email_notice:" + hashToken(token),
+			To:       oldEmail,
+			Locale:   locale,
+			Template: "change_email_notice",
+			Data:     map[string]any{"NewEmail": newEmail},
+		},
+	)
+}
+
+// ConfirmEmailChange switches to the new address of a confirmation token, marks
+// it verified and revokes every session except the one that asked for the change.
+// The token stops working once the address has changed.
+func (s *Service) ConfirmEmailChange(ctx context.Context, token string) error {
+	claims, err := auth.ParsePurposeToken(purposeChangeEmail, token)
+	if err != nil {
+		return ErrInvalidChallenge
+	}
+	userIDClaim, _ := claims["user_id"].(float64)
+	userID := int(userIDClaim)
+	oldEmail, _ := claims["old_email"].(string)
+	newEmail, _ := claims["new_email"].(string)
+	sessionID, _ := claims["session_id"].(string)
+
+	if err := s.emailAvailable(ctx, newEmail, userID); err != nil {
+		return err
+	}
+
+	changed, err := s.Users.ChangeEmail(ctx, userID, oldEmail, newEmail, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return ErrInvalidChallenge
+	}
+
+	return s.RevokeAllSessions(ctx, userID, sessionID)
+}
+
+// emailAvailable returns ErrEmailTaken when an account other than userID already uses the address.
+func (s *Service) emailAvailable(ctx context.Context, email string, userID int) error {
+	exists, err := s.Users.EmailTaken(ctx, email, userID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// ChangePassword changes the password of the authenticated user.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if !validate.Decode(w, r, &req) {
+		return
+	}
+
+	sessionID, _ := auth.SessionIDFromContext(r.Context())
+	if err := h.Service.ChangePassword(r.Context(), userID, sessionID, &req); err != nil {
+		respondWithCredentialsError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "user.success.password_changed")})
+}
+
+// ChangeEmail starts an email change for the authenticated user.
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if !validate.Decode(w, r, &req) {
+		return
+	}
+
+	sessionID, _ := auth.SessionIDFromContext(r.Context())
+	if err := h.Service.RequestEmailChange(r.Context(), userID, sessionID, &req); err != nil {
+		respondWithCredentialsError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"message": i18n.Translate(r.Context(), "user.success.email_change_sent")})
+}
+
+// ConfirmEmailChange completes an email change from the link sent to the new address.
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	if err := h.Service.ConfirmEmailChange(r.Context(), r.URL.Query().Get("token")); err != nil {
+		respondWithCredentialsError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "user.success.email_changed")})
+}
+
+// respondWithCredentialsError maps password and email change errors to HTTP responses.
+func respondWithCredentialsError(w http.ResponseWriter, r *http.Request, err error) {
+	if locked, ok := err.(*loginguard.LockedError); ok {
+		respondWithLockout(w, r, locked)
+		return
+	}
+	if invalid, ok := err.(*passwordpolicy.ValidationError); ok {
+		respondWithPasswordError(w, r, "new_password", invalid)
+		return
+	}
+
+	switch err {
+	case ErrIncorrectPassword:
+		problem.Write(w, r, http.StatusUnauthorized, problem.IncorrectPassword)
+	case ErrInvalidEmail:
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidEmail)
+	case ErrEmailTaken:
+		problem.Write(w, r, http.StatusConflict, problem.EmailTaken)
+	case ErrInvalidChallenge:
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidToken)
+	case sql.ErrNoRows:
+		problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
+	default:
+		log.Printf("Error changing credentials: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.UpdateFailed)
+	}
+}
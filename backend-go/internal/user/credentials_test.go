@@ -0,0 +1,100 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/passwordpolicy"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// secondSession logs the test user in again and returns the new token.
+func secondSession(t *testing.T, service *user.Service, password string) string {
+	token, err := service.LoginUser(context.Background(), &models.LoginRequest{Username: testUsername, Password: password})
+	assert.NoError(t, err)
+	return token
+}
+
+// TestChangePassword verifies the current password check, the policy and session revocation
+func TestChangePassword(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	service := user.NewService(db)
+	userID, current := loginTestUser(t, service, db)
+	other := secondSession(t, service, testPassword)
+
+	claims, err := auth.ParseClaims(current)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	err = service.ChangePassword(ctx, userID, claims.ID, &models.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "N3w-Passw0rd!"})
+	assert.Equal(t, user.ErrIncorrectPassword, err)
+	err = service.ChangePassword(ctx, userID, claims.ID, &models.ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "weak"})
+	var invalid *passwordpolicy.ValidationError
+	assert.ErrorAs(t, err, &invalid)
+
+	err = service.ChangePassword(ctx, userID, claims.ID, &models.ChangePasswordRequest{CurrentPassword: testPassword, NewPassword: "N3w-Passw0rd!"})
+	assert.NoError(t, err)
+
+	_, err = auth.ParseClaims(current)
+	assert.NoError(t, err, "the session that changed the password stays valid")
+	_, err = auth.ParseClaims(other)
+	assert.Error(t, err, "other sessions are revoked")
+
+	_, err = service.LoginUser(ctx, &models.LoginRequest{Username: testUsername, Password: testPassword})
+	assert.Error(t, err)
+	secondSession(t, service, "N3w-Passw0rd!")
+}
+
+// TestChangeEmail verifies validation, confirmation through the signed link and session revocation
+func TestChangeEmail(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	service := user.NewService(db)
+	userID, current := loginTestUser(t, service, db)
+	other := secondSession(t, service, testPassword)
+
+	claims, err := auth.ParseClaims(current)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	err = service.RequestEmailChange(ctx, userID, claims.ID, &models.ChangeEmailRequest{Email: "new@example.com", CurrentPassword: "wrong"})
+	assert.Equal(t, user.ErrIncorrectPassword, err)
+	err = service.RequestEmailChange(ctx, userID, claims.ID, &models.ChangeEmailRequest{Email: "not an email", CurrentPassword: testPassword})
+	assert.Equal(t, user.ErrInvalidEmail, err)
+	err = service.RequestEmailChange(ctx, userID, claims.ID, &models.ChangeEmailRequest{Email: testEmail, CurrentPassword: testPassword})
+	assert.Equal(t, user.ErrEmailTaken, err)
+	err = service.RequestEmailChange(ctx, userID, claims.ID, &models.ChangeEmailRequest{Email: "new@example.com", CurrentPassword: testPassword})
+	assert.NoError(t, err)
+
+	// Nothing changes until the link sent to the new address is followed
+	var email string
+	assert.NoError(t, db.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email))
+	assert.Equal(t, testEmail, email)
+
+	token, err := auth.GeneratePurposeToken("change_email", map[string]any{
+		"user_id": userID, "old_email": testEmail, "new_email": "new@example.com", "session_id": claims.ID,
+	}, time.Hour)
+	assert.NoError(t, err)
+
+	assert.NoError(t, service.ConfirmEmailChange(ctx, token))
+	assert.NoError(t, db.QueryRow("SELECT email FROM users WHERE id = ?", userID).Scan(&email))
+	assert.Equal(t, "new@example.com", email)
+
+	verified, err := service.IsEmailVerified(ctx, userID)
+	assert.NoError(t, err)
+	assert.True(t, verified)
+
+	_, err = auth.ParseClaims(current)
+	assert.NoError(t, err)
+	_, err = auth.ParseClaims(other)
+	assert.Error(t, err)
+
+	// The link cannot be used twice
+	assert.Equal(t, user.ErrInvalidChallenge, service.ConfirmEmailChange(ctx, token))
+}
@@ -0,0 +1,232 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+// Digest frequencies; weekly digests are sent on the first day of the user's week.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// defaultDigestHour is the local hour digests are sent at unless the user picks another.
+const defaultDigestHour = 8
+
+// ErrInvalidDigestSettings is returned for an unknown frequency, hour or time zone.
+var ErrInvalidDigestSettings = errors.New("invalid_digest_settings")
+
+// AppURL is the base URL of the frontend linked from digest emails; set from configuration in main.
+var AppURL string
+
+// DigestSettings returns the digest settings of the user, off when never set.
+// An empty time zone follows the preferences of the user.
+func (s *Service) DigestSettings(ctx context.Context, userID int) (models.DigestSettings, error) {
+	settings, err := s.Digests.Settings(ctx, userID)
+	if err == sql.ErrNoRows {
+		return models.DigestSettings{Frequency: DigestOff, Hour: defaultDigestHour}, nil
+	}
+	return settings, err
+}
+
+// SetDigestSettings subscribes the user to a digest, or unsubscribes them with
+// DigestOff. The hour is interpreted in the IANA time zone of the settings, or
+// of the user's preferences when the settings have none.
+func (s *Service) SetDigestSettings(ctx context.Context, userID int, settings models.DigestSettings) error {
+	switch settings.Frequency {
+	case DigestOff, DigestDaily, DigestWeekly:
+	default:
+		return ErrInvalidDigestSettings
+	}
+	if settings.Hour < 0 || settings.Hour > 23 {
+		return ErrInvalidDigestSettings
+	}
+	if settings.TimeZone == "Local" {
+		return ErrInvalidDigestSettings
+	}
+	if _, err := time.LoadLocation(settings.TimeZone); settings.TimeZone != "" && err != nil {
+		return ErrInvalidDigestSettings
+	}
+
+	return s.Digests.SaveSettings(ctx, userID, settings)
+}
+
+// digestSubscription is a subscriber loaded by SendDigests, with its preferences.
+type digestSubscription struct {
+	DigestSubscription
+	prefs models.UserPreferences
+}
+
+// due reports whether the digest should go out at now, returning the local
+// date it is sent for and the location of the subscriber. A digest missed at
+// its hour, for instance during a restart, is sent later the same day.
+func (d digestSubscription) due(now time.Time) (string, *time.Location, bool) {
+	loc := Location(d.prefs)
+	if d.TimeZone != "" {
+		loc = Location(models.UserPreferences{TimeZone: d.TimeZone})
+	}
+	local := now.In(loc)
+	today := local.Format(time.DateOnly)
+	if local.Hour() < d.Hour || d.LastSentOn == today {
+		return today, loc, false
+	}
+	if d.Frequency == DigestWeekly && local.Weekday() != FirstWeekday(d.prefs) {
+		return today, loc, false
+	}
+	return today, loc, true
+}
+
+// SendDigests queues the digests due at now and returns how many were queued.
+// Each digest is recorded as sent for the local day, so running it again, or
+// from several replicas, does not send it twice.
+func (s *Service) SendDigests(ctx context.Context, now time.Time) (int, error) {
+	subscribers, err := s.Digests.Subscriptions(ctx, EmailVerificationPolicy != VerificationOff)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, subscriber := range subscribers {
+		d := digestSubscription{DigestSubscription: subscriber}
+		if d.prefs, err = s.Preferences(ctx, d.UserID); err != nil {
+			log.Printf("Error reading preferences of user %d: %v", d.UserID, err)
+			continue
+		}
+		today, loc, ok := d.due(now)
+		if !ok {
+			continue
+		}
+		// One failing subscriber does not hold back the others
+		sent, err := s.sendDigest(ctx, d, today, loc)
+		if err != nil {
+			log.Printf("Error sending digest to user %d: %v", d.UserID, err)
+			continue
+		}
+		if sent {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// sendDigest queues the digest of one subscriber for the local day today. It
+// reports whether an email was queued: there is none when nothing is due,
+// overdue or recently completed.
+func (s *Service) sendDigest(ctx context.Context, d digestSubscription, today string, loc *time.Location) (bool, error) {
+	// Tasks completed since the start of the previous day, or week, in the user's time zone
+	dayStart, _ := time.ParseInLocation(time.DateOnly, today, loc)
+	from := dayStart.AddDate(0, 0, -1)
+	if d.Frequency == DigestWeekly {
+		from = dayStart.AddDate(0, 0, -7)
+	}
+
+	tasks, err := s.Digests.Tasks(ctx, d.UserID, today, from, dayStart)
+	if err != nil {
+		return false, err
+	}
+	if len(tasks.DueToday) == 0 && len(tasks.Overdue) == 0 && len(tasks.Completed) == 0 {
+		// The day is claimed all the same, so it is not looked at again
+		_, err := s.Digests.Claim(ctx, d.UserID, today, nil)
+		return false, err
+	}
+
+	return s.Digests.Claim(ctx, d.UserID, today, &Email{
+		Key:      fmt.Sprintf("digest:%d:%s", d.UserID, today),
+		To:       d.Email,
+		Locale:   s.recipientLocale(ctx, d.UserID),
+		Template: "digest",
+		Data: map[string]any{
+			"DueToday":           digestTasks(d.prefs, tasks.DueToday),
+			"Overdue":            digestTasks(d.prefs, tasks.Overdue),
+			"CompletedYesterday": digestTasks(d.prefs, tasks.Completed),
+			"Weekly":             d.Frequency == DigestWeekly,
+			"URL":                AppURL + "/tasks",
+		},
+	})
+}
+
+// digestTask is a task listed in a digest email, with its due date in the
+// date format of the user or "" when it has none.
+type digestTask struct {
+	Title   string
+	DueDate string
+}
+
+// digestTasks converts tasks for a digest email in the preferences of the user.
+func digestTasks(prefs models.UserPreferences, tasks []models.Task) []digestTask {
+	var items []digestTask
+	for _, task := range tasks {
+		item := digestTask{Title: task.Title}
+		if task.DueDate != nil {
+			if dueDate, err := time.Parse(time.DateOnly, *task.DueDate); err == nil {
+				item.DueDate = FormatDate(prefs, dueDate)
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// SendDigestsEvery queues due digests every interval until stop is closed.
+func (s *Service) SendDigestsEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := s.SendDigests(context.Background(), time.Now()); err != nil {
+				log.Printf("Error sending digests: %v", err)
+			}
+		}
+	}
+}
+
+// DigestSettings reads (GET) or changes (PUT) the digest settings of the
+// authenticated user at /api/user/digest.
+func (h *Handler) DigestSettings(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
+		return
+	}
+
+	var settings models.DigestSettings
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		settings, err = h.Service.DigestSettings(r.Context(), userID)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+	case http.MethodPut, http.MethodPost:
+		if !validate.Decode(w, r, &settings) {
+			return
+		}
+		err = h.Service.SetDigestSettings(r.Context(), userID, settings)
+		if err == ErrInvalidDigestSettings {
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidDigestSettings)
+			return
+		} else if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.UpdateFailed)
+			return
+		}
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, settings)
+}
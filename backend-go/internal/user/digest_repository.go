@@ -0,0 +1,247 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"task-manager/backend-go/db"
+	"task-manager/backend-go/models"
+)
+
+// DigestSubscription is an account subscribed to a digest.
+type DigestSubscription struct {
+	UserID    int
+	Email     string
+	Frequency string
+	Hour      int
+	TimeZone  string
+	// LastSentOn is the local date of the last digest, "" before the first one.
+	LastSentOn string
+}
+
+// DigestTasks are the tasks listed in a digest.
+type DigestTasks struct {
+	// DueToday and Overdue are pending tasks, Completed were completed in the
+	// period the digest covers.
+	DueToday, Overdue, Completed []models.Task
+}
+
+// DigestRepository stores the digest subscriptions and reads the tasks they list.
+type DigestRepository interface {
+	// Settings returns the digest settings of the user, sql.ErrNoRows when never set.
+	Settings(ctx context.Context, userID int) (models.DigestSettings, error)
+	SaveSettings(ctx context.Context, userID int, settings models.DigestSettings) error
+	// Subscriptions returns the subscribers to a digest that are not scheduled
+	// for deletion, only those that verified their email when verifiedOnly.
+	Subscriptions(ctx context.Context, verifiedOnly bool) ([]DigestSubscription, error)
+	// Tasks returns the pending tasks of the user due on the date today or
+	// before it, and the tasks completed between from and to.
+	Tasks(ctx context.Context, userID int, today string, from, to time.Time) (DigestTasks, error)
+	// Claim records the digest of the user as sent on the date today and
+	// queues its email when there is one, in one transaction. It reports false
+	// when the digest of that day was already claimed.
+	Claim(ctx context.Context, userID int, today string, email *Email) (bool, error)
+}
+
+// SQLDigestRepository stores subscriptions in the digest_subscriptions table.
+type SQLDigestRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLDigestRepository creates a repository over db.
+func NewSQLDigestRepository(db *sql.DB) *SQLDigestRepository {
+	return &SQLDigestRepository{DB: db}
+}
+
+func (r *SQLDigestRepository) Settings(ctx context.Context, userID int) (models.DigestSettings, error) {
+	var settings models.DigestSettings
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT frequency, send_hour, time_zone FROM digest_subscriptions WHERE user_id = ?", userID,
+	).Scan(&settings.Frequency, &settings.Hour, &settings.TimeZone)
+	return settings, err
+}
+
+func (r *SQLDigestRepository) SaveSettings(ctx context.Context, userID int, settings models.DigestSettings) error {
+	columns := []string{"frequency", "send_hour", "time_zone"}
+	query := db.DialectOf(r.DB).Upsert("digest_subscriptions", []string{"user_id"}, append([]string{"user_id"}, columns...), columns)
+	_, err := r.DB.ExecContext(ctx, query, userID, settings.Frequency, settings.Hour, settings.TimeZone)
+	return err
+}
+
+func (r *SQLDigestRepository) Subscriptions(ctx context.Context, verifiedOnly bool) ([]DigestSubscription, error) {
+	query := `
+		SELECT d.user_id, u.email, d.frequency, d.send_hour, d.time_zone, d.last_sent_on
+		FROM digest_subscriptions d
+		JOIN users u ON u.id = d.user_id
+		WHERE d.frequency <> ? AND u.deletion_scheduled_at IS NULL`
+	if verifiedOnly {
+		query += " AND u.email_verified_at IS NOT NULL"
+	}
+	rows, err := r.DB.QueryContext(ctx, query, DigestOff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []DigestSubscription
+	for rows.Next() {
+		var d DigestSubscription
+		var lastSentOn sql.NullString
+		if err := rows.Scan(&d.UserID, &d.Email, &d.Frequency, &d.Hour, &d.TimeZone, &lastSentOn); err != nil {
+			return nil, err
+		}
+		d.LastSentOn = lastSentOn.String
+		subscriptions = append(subscriptions, d)
+	}
+	return subscriptions, rows.Err()
+}
+
+func (r *SQLDigestRepository) Tasks(ctx context.Context, userID int, today string, from, to time.Time) (DigestTasks, error) {
+	var tasks DigestTasks
+	var err error
+	tasks.DueToday, err = r.tasks(ctx,
+		"SELECT title, due_date FROM tasks WHERE user_id = ? AND status = 'pending' AND due_date = ? ORDER BY id", userID, today)
+	if err != nil {
+		return tasks, err
+	}
+	tasks.Overdue, err = r.tasks(ctx,
+		"SELECT title, due_date FROM tasks WHERE user_id = ? AND status = 'pending' AND due_date < ? ORDER BY due_date, id", userID, today)
+	if err != nil {
+		return tasks, err
+	}
+	tasks.Completed, err = r.tasks(ctx,
+		"SELECT title, due_date FROM tasks WHERE user_id = ? AND status = 'completed' AND completed_at >= ? AND completed_at < ? ORDER BY completed_at, id",
+		userID, from.UTC(), to.UTC())
+	return tasks, err
+}
+
+// tasks returns the tasks selected by query, which reads their title and due date.
+func (r *SQLDigestRepository) tasks(ctx context.Context, query string, args ...any) ([]models.Task, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		var task models.Task
+		var dueDate sql.NullTime
+		if err := rows.Scan(&task.Title, &dueDate); err != nil {
+			return nil, err
+		}
+		if dueDate.Valid {
+			date := dueDate.Time.Format(time.DateOnly)
+			task.DueDate = &date
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *SQLDigestRepository) Claim(ctx context.Context, userID int, today string, email *Email) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Claiming the day first keeps concurrent runs from sending the digest twice
+	claimed, err := affected(tx.ExecContext(ctx,
+		"UPDATE digest_subscriptions SET last_sent_on = ? WHERE user_id = ? AND (last_sent_on IS NULL OR last_sent_on <> ?)",
+		today, userID, today,
+	))
+	if err != nil {
+		return false, err
+	}
+	if claimed && email != nil {
+		if err := email.Queue(ctx, tx); err != nil {
+			return false, err
+		}
+	}
+	return claimed, tx.Commit()
+}
+
+// memoryDigest is a subscription kept by MemoryDigestRepository.
+type memoryDigest struct {
+	models.DigestSettings
+	lastSentOn string
+}
+
+// MemoryDigestRepository keeps subscriptions in a MemoryUserRepository, for
+// tests. Its accounts have no tasks, so the digests it claims are empty.
+type MemoryDigestRepository struct {
+	users *MemoryUserRepository
+}
+
+// NewMemoryDigestRepository creates a repository over the accounts of users.
+func NewMemoryDigestRepository(users *MemoryUserRepository) *MemoryDigestRepository {
+	return &MemoryDigestRepository{users: users}
+}
+
+func (r *MemoryDigestRepository) Settings(ctx context.Context, userID int) (models.DigestSettings, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	digest, ok := r.users.digests[userID]
+	if !ok {
+		return models.DigestSettings{}, sql.ErrNoRows
+	}
+	return digest.DigestSettings, nil
+}
+
+func (r *MemoryDigestRepository) SaveSettings(ctx context.Context, userID int, settings models.DigestSettings) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	if digest, ok := r.users.digests[userID]; ok {
+		digest.DigestSettings = settings
+	} else {
+		r.users.digests[userID] = &memoryDigest{DigestSettings: settings}
+	}
+	return nil
+}
+
+func (r *MemoryDigestRepository) Subscriptions(ctx context.Context, verifiedOnly bool) ([]DigestSubscription, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	var subscriptions []DigestSubscription
+	for userID, digest := range r.users.digests {
+		account, ok := r.users.accounts[userID]
+		if !ok || digest.Frequency == DigestOff || account.deletionAt != nil || (verifiedOnly && account.emailVerifiedAt == nil) {
+			continue
+		}
+		subscriptions = append(subscriptions, DigestSubscription{
+			UserID:     userID,
+			Email:      account.Email,
+			Frequency:  digest.Frequency,
+			Hour:       digest.Hour,
+			TimeZone:   digest.TimeZone,
+			LastSentOn: digest.lastSentOn,
+		})
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].UserID < subscriptions[j].UserID })
+	return subscriptions, nil
+}
+
+func (r *MemoryDigestRepository) Tasks(ctx context.Context, userID int, today string, from, to time.Time) (DigestTasks, error) {
+	return DigestTasks{}, nil
+}
+
+func (r *MemoryDigestRepository) Claim(ctx context.Context, userID int, today string, email *Email) (bool, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	digest, ok := r.users.digests[userID]
+	if !ok || digest.lastSentOn == today {
+		return false, nil
+	}
+	digest.lastSentOn = today
+	if email != nil {
+		r.users.emails = append(r.users.emails, *email)
+	}
+	return true, nil
+}
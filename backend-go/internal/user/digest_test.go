@@ -0,0 +1,99 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/mail"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSendDigests verifies that digests follow the user's time zone and hour,
+// list the right tasks and are sent once per day
+func TestSendDigests(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	userID, _ := loginTestUser(t, service, testDB)
+	ctx := context.Background()
+
+	previousMailer, previousFrom := user.Mailer, user.MailFrom
+	user.Mailer, user.MailFrom = mail.NewMemoryMailer(), "noreply@example.com"
+	defer func() { user.Mailer, user.MailFrom = previousMailer, previousFrom }()
+
+	_, err := testDB.Exec("UPDATE users SET email_verified_at = ? WHERE id = ?", time.Now().UTC(), userID)
+	assert.NoError(t, err)
+
+	// Monday 19 October 2026 in Madrid (UTC+2)
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	assert.NoError(t, err)
+	yesterday := time.Date(2026, 10, 18, 22, 0, 0, 0, madrid).UTC()
+	tasks := []struct {
+		title, status string
+		dueDate       any
+		completedAt   any
+	}{
+		{"Due today", "pending", "2026-10-19", nil},
+		{"Overdue", "pending", "2026-10-12", nil},
+		{"Due next week", "pending", "2026-10-26", nil},
+		{"Done yesterday", "completed", "2026-10-12", yesterday},
+		{"Done last week", "completed", nil, yesterday.AddDate(0, 0, -3)},
+	}
+	for _, task := range tasks {
+		_, err := testDB.Exec("INSERT INTO tasks (user_id, title, status, due_date, completed_at) VALUES (?, ?, ?, ?, ?)",
+			userID, task.title, task.status, task.dueDate, task.completedAt)
+		assert.NoError(t, err)
+	}
+
+	// Invalid settings are rejected, unsubscribed users get nothing
+	assert.Equal(t, user.ErrInvalidDigestSettings,
+		service.SetDigestSettings(ctx, userID, models.DigestSettings{Frequency: "hourly", Hour: 8, TimeZone: "UTC"}))
+	assert.Equal(t, user.ErrInvalidDigestSettings,
+		service.SetDigestSettings(ctx, userID, models.DigestSettings{Frequency: user.DigestDaily, Hour: 8, TimeZone: "Mars/Olympus"}))
+	queued, err := service.SendDigests(ctx, time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queued)
+
+	settings := models.DigestSettings{Frequency: user.DigestDaily, Hour: 8, TimeZone: "Europe/Madrid"}
+	assert.NoError(t, service.SetDigestSettings(ctx, userID, settings))
+	stored, err := service.DigestSettings(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, settings, stored)
+
+	// 07:30 in Madrid is too early, 08:30 is not
+	queued, err = service.SendDigests(ctx, time.Date(2026, 10, 19, 5, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queued)
+	queued, err = service.SendDigests(ctx, time.Date(2026, 10, 19, 6, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, queued)
+	queued, err = service.SendDigests(ctx, time.Date(2026, 10, 19, 7, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queued)
+
+	var text string
+	assert.NoError(t, testDB.QueryRow("SELECT text_body FROM email_outbox WHERE idempotency_key = ?", "digest:1:2026-10-19").Scan(&text))
+	assert.Contains(t, text, "Due today")
+	assert.Contains(t, text, "Overdue")
+	assert.Contains(t, text, "Done yesterday")
+	assert.NotContains(t, text, "Due next week")
+	assert.NotContains(t, text, "Done last week")
+
+	// Weekly digests go out on Mondays and cover the whole previous week
+	settings.Frequency = user.DigestWeekly
+	assert.NoError(t, service.SetDigestSettings(ctx, userID, settings))
+	queued, err = service.SendDigests(ctx, time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queued)
+	queued, err = service.SendDigests(ctx, time.Date(2026, 10, 26, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, queued)
+
+	var count int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM email_outbox").Scan(&count))
+	assert.Equal(t, 2, count)
+}
@@ -0,0 +1,436 @@
+package user
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+// Export states.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+const (
+	// exportTTL bounds how long an export can be downloaded.
+	exportTTL = 24 * time.Hour
+	// exportAsyncThreshold is the number of tasks above which exports are built in the background.
+	exportAsyncThreshold = 500
+)
+
+// ExportDir holds the generated archives; set from configuration in main.
+var ExportDir = filepath.Join(os.TempDir(), "task-manager-exports")
+
+var (
+	// ErrExportNotFound is returned for unknown, expired or already downloaded exports.
+	ErrExportNotFound = errors.New("export_not_found")
+	// ErrExportNotReady is returned when downloading an export that is still being built.
+	ErrExportNotReady = errors.New("export_not_ready")
+)
+
+// StartExport creates a data export with a one-time download token. Small
+// accounts are exported right away; large ones are built in the background
+// and the link works once the export is ready. While the user has a pending or
+// ready export, it is returned with a new link instead, which replaces the
+// previous one.
+func (s *Service) StartExport(ctx context.Context, userID int) (*models.DataExport, error) {
+	token, err := generateResetToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	existing, err := s.Exports.Reissue(ctx, userID, hashToken(token), now)
+	if err == nil {
+		existing.DownloadURL = "/export/download?token=" + token
+		return &existing, nil
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	export := &models.DataExport{Status: ExportPending, CreatedAt: now, ExpiresAt: now.Add(exportTTL)}
+	id, err := s.Exports.Create(ctx, userID, export, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	export.ID = id
+	export.DownloadURL = "/export/download?token=" + token
+
+	taskCount, err := s.Exports.CountTasks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if taskCount > exportAsyncThreshold {
+		go func() {
+			if err := s.buildExport(context.Background(), userID, export.ID); err != nil {
+				log.Printf("Error building export %d: %v", export.ID, err)
+			}
+		}()
+		return export, nil
+	}
+
+	if err := s.buildExport(ctx, userID, export.ID); err != nil {
+		return nil, err
+	}
+	export.Status = ExportReady
+	return export, nil
+}
+
+// GetExport returns the state of one of the user's exports.
+func (s *Service) GetExport(ctx context.Context, userID, exportID int) (*models.DataExport, error) {
+	export, err := s.Exports.Get(ctx, userID, exportID)
+	if err == sql.ErrNoRows || (err == nil && time.Now().After(export.ExpiresAt)) {
+		return nil, ErrExportNotFound
+	}
+	return &export, err
+}
+
+// buildExport writes the archive and marks the export ready, or failed.
+func (s *Service) buildExport(ctx context.Context, userID, exportID int) error {
+	path, err := s.writeExportArchive(ctx, userID, exportID)
+	if err != nil {
+		s.Exports.Finish(ctx, exportID, ExportFailed, "")
+		return err
+	}
+
+	return s.Exports.Finish(ctx, exportID, ExportReady, path)
+}
+
+// writeExportArchive builds the ZIP with one JSON document per kind of data.
+// Times are written in the time zone of the user's preferences.
+func (s *Service) writeExportArchive(ctx context.Context, userID, exportID int) (string, error) {
+	prefs, err := s.Preferences(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	loc := Location(prefs)
+
+	documents := []struct {
+		name  string
+		query func(context.Context, int, *time.Location) (any, error)
+	}{
+		{"profile.json", s.exportProfile},
+		{"preferences.json", s.exportPreferences},
+		{"tasks.json", s.exportTasks},
+		{"sessions.json", s.exportSessions},
+		{"security_events.json", s.exportSecurityEvents},
+		{"identities.json", s.exportIdentities},
+		{"access_tokens.json", s.exportAccessTokens},
+	}
+
+	if err := os.MkdirAll(ExportDir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(ExportDir, fmt.Sprintf("export-%d-%d.zip", userID, exportID))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	for _, document := range documents {
+		data, err := document.query(ctx, userID, loc)
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		entry, err := archive.Create(document.name)
+		if err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// exportProfile returns the account details.
+func (s *Service) exportProfile(ctx context.Context, userID int, loc *time.Location) (any, error) {
+	profile, err := s.Exports.Profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	profile.CreatedAt = profile.CreatedAt.In(loc)
+	profile.EmailVerifiedAt = inLocation(profile.EmailVerifiedAt, loc)
+	profile.MFAEnabledAt = inLocation(profile.MFAEnabledAt, loc)
+	return profile, nil
+}
+
+// exportPreferences returns the language, time zone and display preferences.
+func (s *Service) exportPreferences(ctx context.Context, userID int, loc *time.Location) (any, error) {
+	return s.Preferences(ctx, userID)
+}
+
+// exportTasks returns every task of the user.
+func (s *Service) exportTasks(ctx context.Context, userID int, loc *time.Location) (any, error) {
+	tasks, err := s.Exports.Tasks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		tasks[i].CreatedAt = tasks[i].CreatedAt.In(loc)
+	}
+	return tasks, nil
+}
+
+// exportSessions returns the login history, including revoked and expired sessions.
+func (s *Service) exportSessions(ctx context.Context, userID int, loc *time.Location) (any, error) {
+	history, err := s.Sessions.History(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	type session struct {
+		UserAgent  string     `json:"user_agent"`
+		IP         string     `json:"ip"`
+		CreatedAt  time.Time  `json:"created_at"`
+		LastSeenAt *time.Time `json:"last_seen_at"`
+		ExpiresAt  time.Time  `json:"expires_at"`
+		RevokedAt  *time.Time `json:"revoked_at"`
+	}
+	sessions := []session{}
+	for _, item := range history {
+		sessions = append(sessions, session{
+			UserAgent:  item.Device,
+			IP:         item.IP,
+			CreatedAt:  item.CreatedAt.In(loc),
+			LastSeenAt: inLocation(item.LastSeenAt, loc),
+			ExpiresAt:  item.ExpiresAt.In(loc),
+			RevokedAt:  inLocation(item.RevokedAt, loc),
+		})
+	}
+	return sessions, nil
+}
+
+// exportSecurityEvents returns the lockouts of the account.
+func (s *Service) exportSecurityEvents(ctx context.Context, userID int, loc *time.Location) (any, error) {
+	lockouts, err := s.Lockouts.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	type lockout struct {
+		Event       string    `json:"event"`
+		IP          string    `json:"ip"`
+		Failures    int       `json:"failures"`
+		LockedUntil time.Time `json:"locked_until"`
+		CreatedAt   time.Time `json:"created_at"`
+	}
+	events := []lockout{}
+	for _, item := range lockouts {
+		events = append(events, lockout{
+			Event:       "account_locked",
+			IP:          item.IP,
+			Failures:    item.Failures,
+			LockedUntil: item.Until.In(loc),
+			CreatedAt:   item.CreatedAt.In(loc),
+		})
+	}
+	return events, nil
+}
+
+// exportIdentities returns the linked external identities.
+func (s *Service) exportIdentities(ctx context.Context, userID int, loc *time.Location) (any, error) {
+	identities, err := s.Identities.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	type identity struct {
+		Provider  string    `json:"provider"`
+		Subject   string    `json:"subject"`
+		Email     string    `json:"email"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	items := []identity{}
+	for _, item := range identities {
+		items = append(items, identity{Provider: item.Provider, Subject: item.Subject, Email: item.Email, CreatedAt: item.CreatedAt.In(loc)})
+	}
+	return items, nil
+}
+
+// exportAccessTokens returns the personal access tokens, without their secrets.
+func (s *Service) exportAccessTokens(ctx context.Context, userID int, loc *time.Location) (any, error) {
+	tokens, err := s.ListAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tokens {
+		tokens[i].CreatedAt, tokens[i].ExpiresAt = tokens[i].CreatedAt.In(loc), tokens[i].ExpiresAt.In(loc)
+		tokens[i].LastUsedAt = inLocation(tokens[i].LastUsedAt, loc)
+	}
+	return tokens, nil
+}
+
+// inLocation returns an optional time in loc.
+func inLocation(value *time.Time, loc *time.Location) *time.Time {
+	if value == nil {
+		return nil
+	}
+	local := value.In(loc)
+	return &local
+}
+
+// OpenExport consumes a one-time download token and returns the archive. The
+// export is marked downloaded before it is returned, so the link works once;
+// the file is removed when the returned ReadCloser is closed.
+func (s *Service) OpenExport(ctx context.Context, token string) (io.ReadCloser, error) {
+	export, path, err := s.Exports.Find(ctx, hashToken(token))
+	if err == sql.ErrNoRows || (err == nil && (export.Status == ExportFailed || time.Now().After(export.ExpiresAt))) {
+		return nil, ErrExportNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if export.Status != ExportReady {
+		return nil, ErrExportNotReady
+	}
+
+	downloaded, err := s.Exports.MarkDownloaded(ctx, export.ID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !downloaded {
+		return nil, ErrExportNotFound
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &removeOnClose{File: file}, nil
+}
+
+// removeOnClose deletes the file once it has been read.
+type removeOnClose struct {
+	*os.File
+}
+
+func (f *removeOnClose) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// PurgeExpiredExports removes expired archives and their records.
+func (s *Service) PurgeExpiredExports(ctx context.Context, now time.Time) error {
+	expired, err := s.Exports.Expired(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for id, path := range expired {
+		if path != "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("Error removing export %s: %v", path, err)
+			}
+		}
+		if err := s.Exports.Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export starts a data export (POST /api/user/export) or reports the state
+// of one (GET /api/user/export/{id}).
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
+		return
+	}
+
+	idPart := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/user/export"), "/")
+
+	switch {
+	case idPart == "" && r.Method == http.MethodPost:
+		export, err := h.Service.StartExport(r.Context(), userID)
+		if err != nil {
+			log.Printf("Error starting export: %v", err)
+			problem.Write(w, r, http.StatusInternalServerError, problem.ExportFailed)
+			return
+		}
+		status := http.StatusOK
+		if export.Status == ExportPending {
+			status = http.StatusAccepted
+		}
+		respondWithJSON(w, status, export)
+
+	case idPart != "" && r.Method == http.MethodGet:
+		exportID, err := strconv.Atoi(idPart)
+		if err != nil {
+			problem.Write(w, r, http.StatusNotFound, problem.ExportNotFound)
+			return
+		}
+		export, err := h.Service.GetExport(r.Context(), userID, exportID)
+		if err == ErrExportNotFound {
+			problem.Write(w, r, http.StatusNotFound, problem.ExportNotFound)
+			return
+		} else if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.ExportFailed)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, export)
+
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+	}
+}
+
+// ExportDownload serves an export through its one-time link.
+func (h *Handler) ExportDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	archive, err := h.Service.OpenExport(r.Context(), r.URL.Query().Get("token"))
+	switch err {
+	case nil:
+	case ErrExportNotReady:
+		w.Header().Set("Retry-After", "30")
+		problem.Write(w, r, http.StatusConflict, problem.ExportNotReady)
+		return
+	case ErrExportNotFound:
+		problem.Write(w, r, http.StatusNotFound, problem.ExportNotFound)
+		return
+	default:
+		log.Printf("Error opening export: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.ExportFailed)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="task-manager-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := io.Copy(w, archive); err != nil {
+		log.Printf("Error sending export: %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"task-manager/backend-go/db"
+	"task-manager/backend-go/models"
+)
+
+// ExportProfile is the account details written to a data export.
+type ExportProfile struct {
+	ID              int        `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Name            string     `json:"name"`
+	Surname         string     `json:"surname"`
+	CreatedAt       time.Time  `json:"created_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	MFAEnabledAt    *time.Time `json:"mfa_enabled_at"`
+}
+
+// ExportRepository stores the data exports of the accounts, with their
+// one-time download tokens by hash, and reads the data that only exports use.
+type ExportRepository interface {
+	// Create stores a new export of the user and returns its ID.
+	Create(ctx context.Context, userID int, export *models.DataExport, tokenHash string) (int, error)
+	// Reissue gives the latest pending or ready export of the user that was
+	// not downloaded and has not expired at now a new token hash, and returns
+	// it; sql.ErrNoRows when there is none.
+	Reissue(ctx context.Context, userID int, tokenHash string, now time.Time) (models.DataExport, error)
+	// Get returns an export of the user that was not downloaded, sql.ErrNoRows
+	// when there is none.
+	Get(ctx context.Context, userID, exportID int) (models.DataExport, error)
+	// Finish records the final status of an export and the path of its
+	// archive, "" when it failed.
+	Finish(ctx context.Context, exportID int, status, path string) error
+	// Find returns the export that was not downloaded with the token hash and
+	// the path of its archive, sql.ErrNoRows when there is none.
+	Find(ctx context.Context, tokenHash string) (models.DataExport, string, error)
+	// MarkDownloaded spends the download of the export, reporting false when
+	// it was already spent.
+	MarkDownloaded(ctx context.Context, exportID int, at time.Time) (bool, error)
+	// Files returns the paths of the archives of the user.
+	Files(ctx context.Context, userID int) ([]string, error)
+	// Expired returns the archive path, "" when none, by ID of the exports
+	// that expired at now or were downloaded.
+	Expired(ctx context.Context, now time.Time) (map[int]string, error)
+	Delete(ctx context.Context, exportID int) error
+	// Profile returns the account details of the user, sql.ErrNoRows when
+	// there is no such user.
+	Profile(ctx context.Context, userID int) (ExportProfile, error)
+	// CountTasks returns the number of tasks of the user.
+	CountTasks(ctx context.Context, userID int) (int, error)
+	// Tasks returns every task of the user, oldest first.
+	Tasks(ctx context.Context, userID int) ([]models.Task, error)
+}
+
+// SQLExportRepository stores exports in the data_exports table.
+type SQLExportRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLExportRepository creates a repository over db.
+func NewSQLExportRepository(db *sql.DB) *SQLExportRepository {
+	return &SQLExportRepository{DB: db}
+}
+
+func (r *SQLExportRepository) Create(ctx context.Context, userID int, export *models.DataExport, tokenHash string) (int, error) {
+	id, err := db.DialectOf(r.DB).Insert(ctx, r.DB,
+		"INSERT INTO data_exports (user_id, status, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, export.Status, tokenHash, export.CreatedAt, export.ExpiresAt,
+	)
+	return int(id), err
+}
+
+func (r *SQLExportRepository) Reissue(ctx context.Context, userID int, tokenHash string, now time.Time) (models.DataExport, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	defer tx.Rollback()
+
+	var export models.DataExport
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, status, created_at, expires_at FROM data_exports
+		WHERE user_id = ? AND status IN (?, ?) AND downloaded_at IS NULL AND expires_at > ?
+		ORDER BY id DESC LIMIT 1`,
+		userID, ExportPending, ExportReady, now,
+	).Scan(&export.ID, &export.Status, &export.CreatedAt, &export.ExpiresAt)
+	if err != nil {
+		return export, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE data_exports SET token_hash = ? WHERE id = ?", tokenHash, export.ID); err != nil {
+		return export, err
+	}
+	return export, tx.Commit()
+}
+
+func (r *SQLExportRepository) Get(ctx context.Context, userID, exportID int) (models.DataExport, error) {
+	var export models.DataExport
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT id, status, created_at, expires_at FROM data_exports WHERE id = ? AND user_id = ? AND downloaded_at IS NULL",
+		exportID, userID,
+	).Scan(&export.ID, &export.Status, &export.CreatedAt, &export.ExpiresAt)
+	return export, err
+}
+
+func (r *SQLExportRepository) Finish(ctx context.Context, exportID int, status, path string) error {
+	filePath := sql.NullString{String: path, Valid: path != ""}
+	_, err := r.DB.ExecContext(ctx, "UPDATE data_exports SET status = ?, file_path = ? WHERE id = ?", status, filePath, exportID)
+	return err
+}
+
+func (r *SQLExportRepository) Find(ctx context.Context, tokenHash string) (models.DataExport, string, error) {
+	var export models.DataExport
+	var path sql.NullString
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT id, status, created_at, expires_at, file_path FROM data_exports WHERE token_hash = ? AND downloaded_at IS NULL",
+		tokenHash,
+	).Scan(&export.ID, &export.Status, &export.CreatedAt, &export.ExpiresAt, &path)
+	return export, path.String, err
+}
+
+func (r *SQLExportRepository) MarkDownloaded(ctx context.Context, exportID int, at time.Time) (bool, error) {
+	return affected(r.DB.ExecContext(ctx,
+		"UPDATE data_exports SET downloaded_at = ? WHERE id = ? AND downloaded_at IS NULL", at, exportID,
+	))
+}
+
+func (r *SQLExportRepository) Files(ctx context.Context, userID int) ([]string, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT file_path FROM data_exports WHERE user_id = ? AND file_path IS NOT NULL", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, rows.Err()
+}
+
+func (r *SQLExportRepository) Expired(ctx context.Context, now time.Time) (map[int]string, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT id, file_path FROM data_exports WHERE expires_at <= ? OR downloaded_at IS NOT NULL", now.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expired := map[int]string{}
+	for rows.Next() {
+		var id int
+		var path sql.NullString
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, err
+		}
+		expired[id] = path.String
+	}
+	return expired, rows.Err()
+}
+
+func (r *SQLExportRepository) Delete(ctx context.Context, exportID int) error {
+	_, err := r.DB.ExecContext(ctx, "DELETE FROM data_exports WHERE id = ?", exportID)
+	return err
+}
+
+func (r *SQLExportRepository) Profile(ctx context.Context, userID int) (ExportProfile, error) {
+	var profile ExportProfile
+	var verifiedAt, mfaEnabledAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT id, username, email, name, surname, created_at, email_verified_at, totp_enabled_at FROM users WHERE id = ?", userID,
+	).Scan(&profile.ID, &profile.Username, &profile.Email, &profile.Name, &profile.Surname, &profile.CreatedAt, &verifiedAt, &mfaEnabledAt)
+	if verifiedAt.Valid {
+		profile.EmailVerifiedAt = &verifiedAt.Time
+	}
+	if mfaEnabledAt.Valid {
+		profile.MFAEnabledAt = &mfaEnabledAt.Time
+	}
+	return profile, err
+}
+
+func (r *SQLExportRepository) CountTasks(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM tasks WHERE user_id = ?", userID).Scan(&count)
+	return count, err
+}
+
+func (r *SQLExportRepository) Tasks(ctx context.Context, userID int) ([]models.Task, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT id, title, description, status, created_at, due_date FROM tasks WHERE user_id = ? ORDER BY id", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		task := models.Task{UserID: userID}
+		var description sql.NullString
+		var dueDate sql.NullTime
+		if err := rows.Scan(&task.ID, &task.Title, &description, &task.Status, &task.CreatedAt, &dueDate); err != nil {
+			return nil, err
+		}
+		task.Description = description.String
+		if dueDate.Valid {
+			date := dueDate.Time.Format(time.DateOnly)
+			task.DueDate = &date
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// memoryExport is an export kept by MemoryExportRepository.
+type memoryExport struct {
+	models.DataExport
+	userID       int
+	hash         string
+	path         string
+	downloadedAt *time.Time
+}
+
+// MemoryExportRepository keeps exports in a MemoryUserRepository, for tests.
+// The accounts of a MemoryUserRepository have no tasks, as those are stored by
+// the task package.
+type MemoryExportRepository struct {
+	users  *MemoryUserRepository
+	nextID int
+}
+
+// NewMemoryExportRepository creates a repository over the accounts of users.
+func NewMemoryExportRepository(users *MemoryUserRepository) *MemoryExportRepository {
+	return &MemoryExportRepository{users: users}
+}
+
+func (r *MemoryExportRepository) Create(ctx context.Context, userID int, export *models.DataExport, tokenHash string) (int, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	r.nextID++
+	stored := &memoryExport{DataExport: *export, userID: userID, hash: tokenHash}
+	stored.ID, stored.DownloadURL = r.nextID, ""
+	r.users.exports = append(r.users.exports, stored)
+	return r.nextID, nil
+}
+
+func (r *MemoryExportRepository) Reissue(ctx context.Context, userID int, tokenHash string, now time.Time) (models.DataExport, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for i := len(r.users.exports) - 1; i >= 0; i-- {
+		export := r.users.exports[i]
+		if export.userID == userID && (export.Status == ExportPending || export.Status == ExportReady) &&
+			export.downloadedAt == nil && export.ExpiresAt.After(now) {
+			export.hash = tokenHash
+			return export.DataExport, nil
+		}
+	}
+	return models.DataExport{}, sql.ErrNoRows
+}
+
+func (r *MemoryExportRepository) Get(ctx context.Context, userID, exportID int) (models.DataExport, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, export := range r.users.exports {
+		if export.ID == exportID && export.userID == userID && export.downloadedAt == nil {
+			return export.DataExport, nil
+		}
+	}
+	return models.DataExport{}, sql.ErrNoRows
+}
+
+func (r *MemoryExportRepository) Finish(ctx context.Context, exportID int, status, path string) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, export := range r.users.exports {
+		if export.ID == exportID {
+			export.Status, export.path = status, path
+		}
+	}
+	return nil
+}
+
+func (r *MemoryExportRepository) Find(ctx context.Context, tokenHash string) (models.DataExport, string, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, export := range r.users.exports {
+		if export.hash == tokenHash && export.downloadedAt == nil {
+			return export.DataExport, export.path, nil
+		}
+	}
+	return models.DataExport{}, "", sql.ErrNoRows
+}
+
+func (r *MemoryExportRepository) MarkDownloaded(ctx context.Context, exportID int, at time.Time) (bool, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, export := range r.users.exports {
+		if export.ID == exportID && export.downloadedAt == nil {
+			export.downloadedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryExportRepository) Files(ctx context.Context, userID int) ([]string, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	var files []string
+	for _, export := range r.users.exports {
+		if export.userID == userID && export.path != "" {
+			files = append(files, export.path)
+		}
+	}
+	return files, nil
+}
+
+func (r *MemoryExportRepository) Expired(ctx context.Context, now time.Time) (map[int]string, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	expired := map[int]string{}
+	for _, export := range r.users.exports {
+		if !export.ExpiresAt.After(now) || export.downloadedAt != nil {
+			expired[export.ID] = export.path
+		}
+	}
+	return expired, nil
+}
+
+func (r *MemoryExportRepository) Delete(ctx context.Context, exportID int) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	r.users.exports = deleteRows(r.users.exports, func(e *memoryExport) bool { return e.ID == exportID })
+	return nil
+}
+
+func (r *MemoryExportRepository) Profile(ctx context.Context, userID int) (ExportProfile, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	account, ok := r.users.accounts[userID]
+	if !ok {
+		return ExportProfile{}, sql.ErrNoRows
+	}
+	return ExportProfile{
+		ID:              userID,
+		Username:        account.Username,
+		Email:           account.Email,
+		Name:            account.Name,
+		Surname:         account.Surname,
+		CreatedAt:       account.createdAt,
+		EmailVerifiedAt: account.emailVerifiedAt,
+		MFAEnabledAt:    account.mfaEnabledAt,
+	}, nil
+}
+
+func (r *MemoryExportRepository) CountTasks(ctx context.Context, userID int) (int, error) {
+	return 0, nil
+}
+
+func (r *MemoryExportRepository) Tasks(ctx context.Context, userID int) ([]models.Task, error) {
+	return []models.Task{}, nil
+}
@@ -0,0 +1,206 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"task-manager/backend-go/db"
+	"task-manager/backend-go/models"
+)
+
+// Identity is an account of an external identity provider linked to a local one.
+type Identity struct {
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// OIDCAccount is the local account provisioned for a new identity. It takes
+// the first of Usernames that is free.
+type OIDCAccount struct {
+	Name      string
+	Surname   string
+	Usernames []string
+}
+
+// IdentityRepository stores the external identities linked to the accounts.
+type IdentityRepository interface {
+	// User returns the account linked to the identity, sql.ErrNoRows when
+	// there is none.
+	User(ctx context.Context, provider, subject string) (int, error)
+	// Link links the identity to the account with its email, or to a new
+	// account without password made from account when there is none, and
+	// returns the account. It returns ErrOIDCAccountUnverified when the
+	// existing account has not verified the email, and ErrUserExists when none
+	// of the usernames is free.
+	Link(ctx context.Context, identity Identity, account OIDCAccount) (int, error)
+	// List returns the identities linked to the user, oldest first.
+	List(ctx context.Context, userID int) ([]Identity, error)
+}
+
+// SQLIdentityRepository stores identities in the user_identities table.
+type SQLIdentityRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLIdentityRepository creates a repository over db.
+func NewSQLIdentityRepository(db *sql.DB) *SQLIdentityRepository {
+	return &SQLIdentityRepository{DB: db}
+}
+
+func (r *SQLIdentityRepository) User(ctx context.Context, provider, subject string) (int, error) {
+	var userID int
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, subject,
+	).Scan(&userID)
+	return userID, err
+}
+
+func (r *SQLIdentityRepository) Link(ctx context.Context, identity Identity, account OIDCAccount) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var verifiedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT id, email_verified_at FROM users WHERE email = ?", identity.Email).Scan(&userID, &verifiedAt)
+	if err == sql.ErrNoRows {
+		username, err := freeUsername(ctx, tx, account.Usernames)
+		if err != nil {
+			return 0, err
+		}
+		id, err := db.DialectOf(r.DB).Insert(ctx, tx,
+			"INSERT INTO users (name, surname, username, email, password, email_verified_at) VALUES (?, ?, ?, ?, NULL, ?)",
+			account.Name, account.Surname, username, identity.Email, identity.CreatedAt,
+		)
+		if err != nil {
+			return 0, err
+		}
+		userID = int(id)
+	} else if err != nil {
+		return 0, err
+	} else if !verifiedAt.Valid {
+		return 0, ErrOIDCAccountUnverified
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO user_identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+// freeUsername returns the first of usernames that no account uses.
+func freeUsername(ctx context.Context, tx *sql.Tx, usernames []string) (string, error) {
+	for _, username := range usernames {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", username).Scan(&exists); err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+	}
+	return "", ErrUserExists
+}
+
+func (r *SQLIdentityRepository) List(ctx context.Context, userID int) ([]Identity, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT provider, subject, email, created_at FROM user_identities WHERE user_id = ? ORDER BY id", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var identity Identity
+		var email sql.NullString
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &email, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identity.Email = email.String
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// memoryIdentity is an identity kept by MemoryIdentityRepository.
+type memoryIdentity struct {
+	Identity
+	userID int
+}
+
+// MemoryIdentityRepository keeps identities in a MemoryUserRepository, for tests.
+type MemoryIdentityRepository struct {
+	users *MemoryUserRepository
+}
+
+// NewMemoryIdentityRepository creates a repository over the accounts of users.
+func NewMemoryIdentityRepository(users *MemoryUserRepository) *MemoryIdentityRepository {
+	return &MemoryIdentityRepository{users: users}
+}
+
+func (r *MemoryIdentityRepository) User(ctx context.Context, provider, subject string) (int, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, identity := range r.users.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity.userID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (r *MemoryIdentityRepository) Link(ctx context.Context, identity Identity, account OIDCAccount) (int, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	userID, existing := r.users.findByEmail(identity.Email)
+	if existing != nil && existing.emailVerifiedAt == nil {
+		return 0, ErrOIDCAccountUnverified
+	}
+	if existing == nil {
+		username := ""
+		for _, candidate := range account.Usernames {
+			if _, taken := r.users.findByUsername(candidate); taken == nil {
+				username = candidate
+				break
+			}
+		}
+		if username == "" {
+			return 0, ErrUserExists
+		}
+		verifiedAt := identity.CreatedAt
+		userID = r.users.insert(&memoryAccount{
+			UserSummary:     models.UserSummary{Username: username, Email: identity.Email, Name: account.Name, Surname: account.Surname},
+			emailVerifiedAt: &verifiedAt,
+		})
+	}
+
+	r.users.identities = append(r.users.identities, &memoryIdentity{Identity: identity, userID: userID})
+	return userID, nil
+}
+
+func (r *MemoryIdentityRepository) List(ctx context.Context, userID int) ([]Identity, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	identities := []Identity{}
+	for _, identity := range r.users.identities {
+		if identity.userID == userID {
+			identities = append(identities, identity.Identity)
+		}
+	}
+	return identities, nil
+}
@@ -0,0 +1,179 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/problem"
+	"time"
+)
+
+// unlockTTL bounds how long the unlock link stays valid.
+const unlockTTL = 24 * time.Hour
+
+// ErrInvalidUnlockToken is returned for unknown, used or expired unlock links.
+var ErrInvalidUnlockToken = errors.New("invalid_unlock_token")
+
+// LoginGuard throttles failed logins. main replaces it with a guard that
+// records lockouts, optionally SQL-backed so several replicas share the counters.
+var LoginGuard = loginguard.New(loginguard.NewMemoryStore())
+
+// NewLoginGuard creates a guard that records lockouts through service and
+// emails the account owner.
+func NewLoginGuard(store loginguard.Store, service *Service) *loginguard.Guard {
+	guard := loginguard.New(store)
+	guard.OnLockout = func(ctx context.Context, lockout loginguard.Lockout) {
+		if err := service.RecordLockout(ctx, lockout); err != nil {
+			log.Printf("Error recording lockout of %s %s: %v", lockout.Kind, lockout.Identifier, err)
+		}
+	}
+	return guard
+}
+
+// RecordLockout stores the lockout event and, for accounts, sends an unlock
+// link to the owner. Only the hash of the link's token is stored with the
+// lockout, so that UnlockAccount can spend it.
+func (s *Service) RecordLockout(ctx context.Context, lockout loginguard.Lockout) error {
+	event := LockoutEvent{Lockout: lockout, CreatedAt: time.Now().UTC()}
+	switch lockout.Kind {
+	case loginguard.KindAccount:
+		userID, err := s.Users.FindByUsername(ctx, lockout.Identifier)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		event.UserID = userID
+	case loginguard.KindMFA:
+		// Two-factor lockouts are keyed by user ID and need no unlock link
+		if id, err := strconv.Atoi(lockout.Identifier); err == nil {
+			event.UserID = id
+		}
+	}
+	if lockout.Kind != loginguard.KindAccount || event.UserID == 0 {
+		return s.Lockouts.Record(ctx, event, nil)
+	}
+
+	user, err := s.Users.Summary(ctx, event.UserID)
+	if err != nil {
+		return err
+	}
+	token, err := generateResetToken()
+	if err != nil {
+		return err
+	}
+	event.UnlockTokenHash = hashToken(token)
+	return s.Lockouts.Record(ctx, event, &Email{
+		Key:      "unlock_account:" + event.UnlockTokenHash,
+		To:       user.Email,
+		Locale:   s.recipientLocale(ctx, event.UserID),
+		Template: "unlock_account",
+		Data:     map[string]any{"URL": publicURL("/unlock-account?token=" + token)},
+	})
+}
+
+// UnlockAccount spends an unlock link and clears the lockout of its account.
+// The other outstanding links of the account are spent too.
+func (s *Service) UnlockAccount(ctx context.Context, token string) error {
+	now := time.Now().UTC()
+	identifier, err := s.Lockouts.Unlock(ctx, hashToken(token), now.Add(-unlockTTL), now)
+	if err != nil {
+		return err
+	}
+	return LoginGuard.Unlock(ctx, identifier)
+}
+
+// respondWithLockout answers a throttled login with 429 and a Retry-After header.
+func respondWithLockout(w http.ResponseWriter, r *http.Request, locked *loginguard.LockedError) {
+	seconds := int(math.Ceil(locked.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	code := problem.TooManyAttempts
+	if locked.Locked {
+		code = problem.AccountLocked
+	}
+	problem.Write(w, r, http.StatusTooManyRequests, code)
+}
+
+// unlockPage asks the owner to confirm the unlock. Mail scanners and link
+// previews follow GET links, so only the POST of its form spends the link.
+// It also shows the outcome when the form was posted.
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Text}}</p>
+{{if .Token}}<form method="post" action="/unlock-account?token={{.Token}}">
+<button type="submit">{{.Button}}</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// UnlockAccount clears the lockout of the account named in an unlock link:
+// GET shows a page confirming the unlock and POST unlocks. Its form gets a
+// page back, other clients JSON.
+func (h *Handler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	switch r.Method {
+	case http.MethodGet:
+		renderUnlockPage(w, r, http.StatusOK, "login.unlock_confirm", token)
+		return
+	case http.MethodPost:
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	fromPage := r.Header.Get("Content-Type") == "application/x-www-form-urlencoded"
+	err := h.Service.UnlockAccount(r.Context(), token)
+	if err == ErrInvalidUnlockToken {
+		if fromPage {
+			renderUnlockPage(w, r, http.StatusBadRequest, "auth.error.invalid_token", "")
+			return
+		}
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidToken)
+		return
+	}
+	if err != nil {
+		log.Printf("Error unlocking account: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.UnlockFailed)
+		return
+	}
+
+	if fromPage {
+		renderUnlockPage(w, r, http.StatusOK, "login.unlocked", "")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "login.unlocked")})
+}
+
+// renderUnlockPage writes unlockPage with the translated text, and the
+// confirmation form when token is set.
+func renderUnlockPage(w http.ResponseWriter, r *http.Request, status int, textKey, token string) {
+	ctx := r.Context()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	err := unlockPage.Execute(w, map[string]string{
+		"Locale": i18n.LocaleFromContext(ctx),
+		"Title":  i18n.Translate(ctx, "email.button.unlock_account"),
+		"Text":   i18n.Translate(ctx, textKey),
+		"Button": i18n.Translate(ctx, "email.button.unlock_account"),
+		"Token":  token,
+	})
+	if err != nil {
+		log.Printf("Error rendering unlock page: %v", err)
+	}
+}
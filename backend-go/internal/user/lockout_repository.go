@@ -0,0 +1,182 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"task-manager/backend-go/internal/loginguard"
+)
+
+// LockoutEvent is a lockout recorded by LoginGuard.
+type LockoutEvent struct {
+	loginguard.Lockout
+	// UserID is the locked account, 0 when the lockout is not tied to one.
+	UserID int
+	// UnlockTokenHash is the hash of the token of the unlock link, "" when none was sent.
+	UnlockTokenHash string
+	CreatedAt       time.Time
+}
+
+// LockoutRepository stores lockout events and spends their unlock links.
+type LockoutRepository interface {
+	// Record stores the event and queues the unlock email when there is one,
+	// in one transaction.
+	Record(ctx context.Context, event LockoutEvent, email *Email) error
+	// Unlock spends the unlock link with the hash if it was created after
+	// since, together with the other outstanding links of the same
+	// identifier, and returns the identifier. It returns
+	// ErrInvalidUnlockToken for unknown, used or expired links.
+	Unlock(ctx context.Context, tokenHash string, since, at time.Time) (string, error)
+	// List returns the lockouts of the user, oldest first.
+	List(ctx context.Context, userID int) ([]LockoutEvent, error)
+}
+
+// SQLLockoutRepository stores lockouts in the login_lockouts table.
+type SQLLockoutRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLLockoutRepository creates a repository over db.
+func NewSQLLockoutRepository(db *sql.DB) *SQLLockoutRepository {
+	return &SQLLockoutRepository{DB: db}
+}
+
+func (r *SQLLockoutRepository) Record(ctx context.Context, event LockoutEvent, email *Email) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userID := sql.NullInt64{Int64: int64(event.UserID), Valid: event.UserID != 0}
+	tokenHash := sql.NullString{String: event.UnlockTokenHash, Valid: event.UnlockTokenHash != ""}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO login_lockouts (kind, identifier, user_id, ip, failures, locked_until, created_at, unlock_token_hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		event.Kind, event.Identifier, userID, event.IP, event.Failures, event.Until.UTC(), event.CreatedAt, tokenHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	if email != nil {
+		if err := email.Queue(ctx, tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLLockoutRepository) Unlock(ctx context.Context, tokenHash string, since, at time.Time) (string, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	spent, err := affected(tx.ExecContext(ctx,
+		"UPDATE login_lockouts SET unlocked_at = ? WHERE unlock_token_hash = ? AND unlocked_at IS NULL AND created_at > ?",
+		at, tokenHash, since,
+	))
+	if err != nil {
+		return "", err
+	}
+	if !spent {
+		return "", ErrInvalidUnlockToken
+	}
+
+	var kind, identifier string
+	err = tx.QueryRowContext(ctx,
+		"SELECT kind, identifier FROM login_lockouts WHERE unlock_token_hash = ?", tokenHash,
+	).Scan(&kind, &identifier)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE login_lockouts SET unlocked_at = ? WHERE kind = ? AND identifier = ? AND unlocked_at IS NULL",
+		at, kind, identifier,
+	); err != nil {
+		return "", err
+	}
+	return identifier, tx.Commit()
+}
+
+func (r *SQLLockoutRepository) List(ctx context.Context, userID int) ([]LockoutEvent, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT kind, identifier, ip, failures, locked_until, created_at FROM login_lockouts WHERE user_id = ? ORDER BY created_at", userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []LockoutEvent{}
+	for rows.Next() {
+		event := LockoutEvent{UserID: userID}
+		var ip sql.NullString
+		if err := rows.Scan(&event.Kind, &event.Identifier, &ip, &event.Failures, &event.Until, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.IP = ip.String
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// memoryLockout is a lockout kept by MemoryLockoutRepository.
+type memoryLockout struct {
+	LockoutEvent
+	unlockedAt *time.Time
+}
+
+// MemoryLockoutRepository keeps lockouts in a MemoryUserRepository, for tests.
+type MemoryLockoutRepository struct {
+	users *MemoryUserRepository
+}
+
+// NewMemoryLockoutRepository creates a repository over the accounts of users.
+func NewMemoryLockoutRepository(users *MemoryUserRepository) *MemoryLockoutRepository {
+	return &MemoryLockoutRepository{users: users}
+}
+
+func (r *MemoryLockoutRepository) Record(ctx context.Context, event LockoutEvent, email *Email) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	r.users.lockouts = append(r.users.lockouts, &memoryLockout{LockoutEvent: event})
+	if email != nil {
+		r.users.emails = append(r.users.emails, *email)
+	}
+	return nil
+}
+
+func (r *MemoryLockoutRepository) Unlock(ctx context.Context, tokenHash string, since, at time.Time) (string, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, lockout := range r.users.lockouts {
+		if lockout.UnlockTokenHash != tokenHash || lockout.unlockedAt != nil || !lockout.CreatedAt.After(since) {
+			continue
+		}
+		for _, other := range r.users.lockouts {
+			if other.Kind == lockout.Kind && other.Identifier == lockout.Identifier && other.unlockedAt == nil {
+				other.unlockedAt = &at
+			}
+		}
+		return lockout.Identifier, nil
+	}
+	return "", ErrInvalidUnlockToken
+}
+
+func (r *MemoryLockoutRepository) List(ctx context.Context, userID int) ([]LockoutEvent, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	events := []LockoutEvent{}
+	for _, lockout := range r.users.lockouts {
+		if lockout.UserID == userID {
+			events = append(events, lockout.LockoutEvent)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,151 @@
+package user_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// postLogin sends credentials to the login handler.
+func postLogin(handler *user.Handler, username, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(models.LoginRequest{Username: username, Password: password})
+	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	req.RemoteAddr = "192.0.2.1:4321"
+	rec := httptest.NewRecorder()
+	handler.Login(rec, req)
+	return rec
+}
+
+// TestLoginHandler_LocksAccountAfterFailures verifies throttling, the lockout record and the unlock path.
+func TestLoginHandler_LocksAccountAfterFailures(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	handler := user.NewHandler(service)
+
+	previous := user.LoginGuard
+	defer func() { user.LoginGuard = previous }()
+	user.LoginGuard = user.NewLoginGuard(loginguard.NewSQLStore(testDB), service)
+	// No backoff so the test can hit the lockout immediately
+	user.LoginGuard.Account.BaseDelay = 0
+	user.LoginGuard.Account.MaxFailures = 3
+
+	_, err := service.RegisterUser(context.Background(), &models.RegisterRequest{
+		Name: testName, Surname: testSurname, Username: testUsername, Email: testEmail, Password: testPassword,
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, postLogin(handler, testUsername, "wrong-password").Code)
+	}
+
+	// Even the right password is refused while locked
+	rec := postLogin(handler, testUsername, testPassword)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	var kind, ip, stored string
+	var userID int
+	err = testDB.QueryRow("SELECT kind, ip, user_id, unlock_token_hash FROM login_lockouts").Scan(&kind, &ip, &userID, &stored)
+	assert.NoError(t, err)
+	assert.Equal(t, loginguard.KindAccount, kind)
+	assert.Equal(t, "192.0.2.1", ip)
+	assert.NotZero(t, userID)
+	assert.Len(t, stored, 64)
+
+	// The unlock link works once
+	sum := sha256.Sum256([]byte("known-token"))
+	_, err = testDB.Exec("UPDATE login_lockouts SET unlock_token_hash = ?", hex.EncodeToString(sum[:]))
+	assert.NoError(t, err)
+	for _, status := range []int{http.StatusOK, http.StatusBadRequest} {
+		rec := httptest.NewRecorder()
+		handler.UnlockAccount(rec, httptest.NewRequest(http.MethodPost, "/unlock-account?token=known-token", nil))
+		assert.Equal(t, status, rec.Code)
+	}
+	assert.Equal(t, http.StatusOK, postLogin(handler, testUsername, testPassword).Code)
+}
+
+// TestLoginHandler_ServerErrorIsNotAFailedAttempt verifies that errors other
+// than wrong credentials answer 500 and do not count towards a lockout
+func TestLoginHandler_ServerErrorIsNotAFailedAttempt(t *testing.T) {
+	testDB := setupTestDB(t)
+	service := user.NewService(testDB)
+	handler := user.NewHandler(service)
+
+	previous := user.LoginGuard
+	defer func() { user.LoginGuard = previous }()
+	user.LoginGuard = user.NewLoginGuard(loginguard.NewMemoryStore(), service)
+	user.LoginGuard.Account.BaseDelay = 0
+	user.LoginGuard.Account.MaxFailures = 1
+
+	testDB.Close()
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusInternalServerError, postLogin(handler, testUsername, testPassword).Code)
+	}
+}
+
+// TestMFA_BruteForce verifies that wrong codes use up the challenge and lock two-factor login of the user.
+func TestMFA_BruteForce(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+
+	previous := user.LoginGuard
+	user.LoginGuard = user.NewLoginGuard(loginguard.NewMemoryStore(), service)
+	user.LoginGuard.MFA.BaseDelay = 0
+	defer func() { user.LoginGuard = previous }()
+
+	now := time.Unix(1700000000, 0)
+	service.TOTP.Now = func() time.Time { return now }
+	userID, _ := loginTestUser(t, service, testDB)
+	secret, _, err := service.EnrollMFA(context.Background(), userID)
+	assert.NoError(t, err)
+	code, _ := service.TOTP.Code(secret, now)
+	_, err = service.ConfirmMFA(context.Background(), userID, code)
+	assert.NoError(t, err)
+
+	login := func() string {
+		challenge, err := service.LoginUser(context.Background(), &models.LoginRequest{Username: testUsername, Password: testPassword})
+		assert.ErrorIs(t, err, user.ErrMFARequired)
+		return challenge
+	}
+
+	// A challenge accepts three codes
+	challenge := login()
+	for i := 0; i < 3; i++ {
+		_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: "000000"})
+		assert.ErrorIs(t, err, user.ErrInvalidMFACode)
+	}
+	_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: "000000"})
+	assert.ErrorIs(t, err, user.ErrInvalidChallenge)
+
+	// Five wrong codes lock the user, even with the right code and a new challenge
+	challenge = login()
+	_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: "000000"})
+	assert.ErrorIs(t, err, user.ErrInvalidMFACode)
+	_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: "000000"})
+	assert.ErrorIs(t, err, user.ErrInvalidMFACode)
+
+	now = now.Add(30 * time.Second)
+	code, _ = service.TOTP.Code(secret, now)
+	_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: login(), Code: code})
+	var locked *loginguard.LockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.Equal(t, loginguard.KindMFA, locked.Kind)
+
+	var lockouts int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM login_lockouts WHERE kind = 'mfa' AND user_id = ?", userID).Scan(&lockouts))
+	assert.Equal(t, 1, lockouts)
+}
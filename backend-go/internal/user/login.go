@@ -3,49 +3,85 @@ package user
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"task-manager/backend-go/db"
+	"task-manager/backend-go/internal/auth"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
 	"task-manager/backend-go/models"
 )
 
-/* LoginHandler handles HTTP login requests.
+/* Login handles HTTP login requests.
 It validates the method, decodes the request body,
 calls the login service, and returns a JWT token on success.
+Failed attempts are throttled per account and per IP by LoginGuard.
 */
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("method_not_allowed"),
-		})
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
 		return
 	}
 
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("invalid_request_data"),
-		})
+	if !validate.Decode(w, r, &req) {
 		return
 	}
 
-	service := NewService(db.DB)
+	req.UserAgent = r.UserAgent()
+	req.IP = auth.ClientIP(r)
 
-	token, err := service.LoginUser(context.Background(), &req)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("login_failed"),
+	ctx := context.Background()
+	if err := LoginGuard.Check(ctx, req.Username, req.IP); err != nil {
+		if locked, ok := err.(*loginguard.LockedError); ok {
+			respondWithLockout(w, r, locked)
+			return
+		}
+		log.Printf("Error checking login attempts: %v", err)
+	}
+
+	token, err := h.Service.LoginUser(ctx, &req)
+	if err == nil || err == ErrMFARequired || err == ErrEmailNotVerified {
+		if err := LoginGuard.Success(ctx, req.Username); err != nil {
+			log.Printf("Error clearing login attempts: %v", err)
+		}
+	} else if errors.Is(err, ErrInvalidCredentials) {
+		if err := LoginGuard.Failure(ctx, req.Username, req.IP); err != nil {
+			log.Printf("Error recording login attempt: %v", err)
+		}
+	}
+
+	if err == ErrMFARequired {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{
+			"message":         i18n.Translate(r.Context(), "mfa.required"),
+			"mfa_required":    true,
+			"challenge_token": token,
 		})
 		return
 	}
+	if err == ErrEmailNotVerified {
+		problem.Write(w, r, http.StatusForbidden, problem.EmailNotVerified)
+		return
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		problem.Write(w, r, http.StatusUnauthorized, problem.LoginFailed)
+		return
+	}
+	if err != nil {
+		// Database and session errors say nothing about the credentials
+		log.Printf("Error logging in: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": i18n.T("login_success"),
+		"message": i18n.Translate(r.Context(), "login_success"),
 		"token":   token,
 	})
 }
@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+const (
+	// magicLinkTTL bounds how long a magic link can be redeemed.
+	magicLinkTTL = 15 * time.Minute
+	// magicLinkInterval is the minimum time between two links for the same account.
+	magicLinkInterval = time.Minute
+)
+
+var (
+	// ErrMagicLinkUnavailable is returned when the account has not opted in to magic links.
+	ErrMagicLinkUnavailable = errors.New("magic_link_unavailable")
+	// ErrInvalidMagicLink is returned for unknown, expired or already used links.
+	ErrInvalidMagicLink = errors.New("invalid_magic_link")
+)
+
+// SetMagicLinkEnabled opts the user in or out of passwordless login. Opting
+// out invalidates the outstanding links.
+func (s *Service) SetMagicLinkEnabled(ctx context.Context, userID int, enabled bool) error {
+	return s.MagicLinks.SetEnabled(ctx, userID, enabled, time.Now().UTC())
+}
+
+// MagicLinkEnabled reports whether the user has opted in to passwordless login.
+func (s *Service) MagicLinkEnabled(ctx context.Context, userID int) (bool, error) {
+	return s.MagicLinks.Enabled(ctx, userID)
+}
+
+// SendMagicLink emails a single-use login link to the account with that email,
+// when it has opted in. Only the SHA-256 hash of the token is stored.
+func (s *Service) SendMagicLink(ctx context.Context, email, ip string) error {
+	userID, err := s.Users.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	enabled, err := s.MagicLinks.Enabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrMagicLinkUnavailable
+	}
+
+	now := time.Now().UTC()
+	recent, err := s.MagicLinks.SentSince(ctx, userID, now.Add(-magicLinkInterval))
+	if err != nil {
+		return err
+	}
+	if recent {
+		return ErrVerificationThrottled
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return err
+	}
+	link := Link{TokenHash: hashToken(token), IP: ip, CreatedAt: now, ExpiresAt: now.Add(magicLinkTTL)}
+	return s.MagicLinks.Create(ctx, userID, link, Email{
+		Key:      "magic_link:" + link.TokenHash,
+		To:       email,
+		Locale:   s.recipientLocale(ctx, userID),
+		Template: "magic_link",
+		Data:     map[string]any{"URL": publicURL("/auth/magic-link/verify?token=" + token)},
+	})
+}
+
+// RedeemMagicLink consumes a magic link and signs the user in. The other
+// outstanding links of the account are invalidated. Following the link proves
+// ownership of the address, so it also verifies the email. Like LoginUser, it
+// returns a challenge token with ErrMFARequired when 2FA is enabled.
+func (s *Service) RedeemMagicLink(ctx context.Context, token, userAgent, ip string) (string, error) {
+	userID, err := s.MagicLinks.Redeem(ctx, hashToken(token), time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+
+	mfa, err := s.MFA.State(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if mfa.Enabled {
+		challenge, err := auth.GenerateChallengeToken(userID)
+		if err != nil {
+			return "", err
+		}
+		return challenge, ErrMFARequired
+	}
+	return s.createSession(ctx, userID, userAgent, ip)
+}
+
+// MagicLink emails a login link. It answers the same way whether or not
+// the address belongs to an account that opted in.
+func (h *Handler) MagicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	var req models.UserRequest
+	if !validate.Decode(w, r, &req) {
+		return
+	}
+
+	err := h.Service.SendMagicLink(r.Context(), strings.TrimSpace(req.Email), auth.ClientIP(r))
+	if err != nil && err != sql.ErrNoRows && err != ErrMagicLinkUnavailable && err != ErrVerificationThrottled {
+		log.Printf("Error sending magic link: %v", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "magic_link.sent")})
+}
+
+// MagicLinkVerify redeems a magic link for a JWT. The token is read from
+// the JSON body or, for links opened directly, from the query string.
+func (h *Handler) MagicLinkVerify(w http.ResponseWriter, r *http.Request) {
+	var req models.MagicLinkVerifyRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Token = r.URL.Query().Get("token")
+	case http.MethodPost:
+		if !validate.Decode(w, r, &req) {
+			return
+		}
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	token, err := h.Service.RedeemMagicLink(r.Context(), req.Token, r.UserAgent(), auth.ClientIP(r))
+	switch err {
+	case nil:
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "login_success"), "token": token})
+	case ErrMFARequired:
+		respondWithJSON(w, http.StatusOK, map[string]any{
+			"message":         i18n.Translate(r.Context(), "mfa.required"),
+			"mfa_required":    true,
+			"challenge_token": token,
+		})
+	case ErrInvalidMagicLink:
+		problem.Write(w, r, http.StatusUnauthorized, problem.InvalidMagicLink)
+	default:
+		log.Printf("Error redeeming magic link: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.LoginFailed)
+	}
+}
+
+// MagicLinkSettings reads (GET) or changes (PUT) the user's opt-in to magic links.
+func (h *Handler) MagicLinkSettings(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
+		return
+	}
+
+	var settings models.MagicLinkSettings
+	switch r.Method {
+	case http.MethodGet:
+		enabled, err := h.Service.MagicLinkEnabled(r.Context(), userID)
+		if err != nil {
+			problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
+			return
+		}
+		settings.Enabled = enabled
+	case http.MethodPut, http.MethodPost:
+		if !validate.Decode(w, r, &settings) {
+			return
+		}
+		if err := h.Service.SetMagicLinkEnabled(r.Context(), userID, settings.Enabled); err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.UpdateFailed)
+			return
+		}
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, settings)
+}
@@ -0,0 +1,248 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Link is a single-use link sent by email, stored by the hash of its token.
+type Link struct {
+	TokenHash string
+	IP        string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// MagicLinkRepository stores the opt-in of the accounts to passwordless login
+// and the magic links sent to them.
+type MagicLinkRepository interface {
+	// Enabled reports whether the user opted in, sql.ErrNoRows when there is
+	// no such user.
+	Enabled(ctx context.Context, userID int) (bool, error)
+	// SetEnabled opts the user in or out; opting out spends the outstanding
+	// links at the given time.
+	SetEnabled(ctx context.Context, userID int, enabled bool, at time.Time) error
+	// SentSince reports whether a link was created for the user after since.
+	SentSince(ctx context.Context, userID int, since time.Time) (bool, error)
+	// Create stores a link of the user and queues its email, in one transaction.
+	Create(ctx context.Context, userID int, link Link, email Email) error
+	// Redeem spends the link with the hash if it is unused and unexpired at
+	// the given time, together with the other outstanding links of its
+	// account, and marks the email of the account verified. It returns the
+	// account, or ErrInvalidMagicLink when the link cannot be used or the
+	// account opted out.
+	Redeem(ctx context.Context, tokenHash string, at time.Time) (int, error)
+}
+
+// SQLMagicLinkRepository stores magic links in the magic_links table and the
+// opt-in in the users table.
+type SQLMagicLinkRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLMagicLinkRepository creates a repository over db.
+func NewSQLMagicLinkRepository(db *sql.DB) *SQLMagicLinkRepository {
+	return &SQLMagicLinkRepository{DB: db}
+}
+
+func (r *SQLMagicLinkRepository) Enabled(ctx context.Context, userID int) (bool, error) {
+	var enabled bool
+	err := r.DB.QueryRowContext(ctx, "SELECT magic_link_enabled FROM users WHERE id = ?", userID).Scan(&enabled)
+	return enabled, err
+}
+
+func (r *SQLMagicLinkRepository) SetEnabled(ctx context.Context, userID int, enabled bool, at time.Time) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET magic_link_enabled = ? WHERE id = ?", enabled, userID); err != nil {
+		return err
+	}
+	if !enabled {
+		_, err := tx.ExecContext(ctx, "UPDATE magic_links SET used_at = ? WHERE user_id = ? AND used_at IS NULL", at, userID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLMagicLinkRepository) SentSince(ctx context.Context, userID int, since time.Time) (bool, error) {
+	var recent bool
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM magic_links WHERE user_id = ? AND created_at > ?)", userID, since,
+	).Scan(&recent)
+	return recent, err
+}
+
+func (r *SQLMagicLinkRepository) Create(ctx context.Context, userID int, link Link, email Email) error {
+	return insertLink(ctx, r.DB, "magic_links", userID, link, email)
+}
+
+func (r *SQLMagicLinkRepository) Redeem(ctx context.Context, tokenHash string, at time.Time) (int, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Spending the link first is the guard against concurrent use of it
+	spent, err := affected(tx.ExecContext(ctx,
+		"UPDATE magic_links SET used_at = ? WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		at, tokenHash, at,
+	))
+	if err != nil {
+		return 0, err
+	}
+	if !spent {
+		return 0, ErrInvalidMagicLink
+	}
+
+	var userID int
+	var enabled bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT u.id, u.magic_link_enabled
+		FROM magic_links m JOIN users u ON u.id = m.user_id
+		WHERE m.token_hash = ?`, tokenHash,
+	).Scan(&userID, &enabled)
+	if err == sql.ErrNoRows || (err == nil && !enabled) {
+		return 0, ErrInvalidMagicLink
+	} else if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE magic_links SET used_at = ? WHERE user_id = ? AND used_at IS NULL", at, userID,
+	); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL", at, userID,
+	); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+// insertLink stores a link of the user in table, magic_links or
+// password_resets, and queues its email in the same transaction.
+func insertLink(ctx context.Context, conn *sql.DB, table string, userID int, link Link, email Email) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO "+table+" (user_id, token_hash, ip, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		userID, link.TokenHash, link.IP, link.CreatedAt, link.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+	if err := email.Queue(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// memoryLink is a magic link or password reset kept by a MemoryUserRepository.
+type memoryLink struct {
+	Link
+	userID int
+	usedAt *time.Time
+}
+
+// usable reports whether the link can still be used at the given time.
+func (l *memoryLink) usable(at time.Time) bool {
+	return l.usedAt == nil && l.ExpiresAt.After(at)
+}
+
+// spendLinks marks the unused links of the user used at the given time.
+func spendLinks(links []*memoryLink, userID int, at time.Time) {
+	for _, link := range links {
+		if link.userID == userID && link.usedAt == nil {
+			link.usedAt = &at
+		}
+	}
+}
+
+// MemoryMagicLinkRepository keeps magic links in a MemoryUserRepository, for tests.
+type MemoryMagicLinkRepository struct {
+	users *MemoryUserRepository
+}
+
+// NewMemoryMagicLinkRepository creates a repository over the accounts of users.
+func NewMemoryMagicLinkRepository(users *MemoryUserRepository) *MemoryMagicLinkRepository {
+	return &MemoryMagicLinkRepository{users: users}
+}
+
+func (r *MemoryMagicLinkRepository) Enabled(ctx context.Context, userID int) (bool, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	account, ok := r.users.accounts[userID]
+	if !ok {
+		return false, sql.ErrNoRows
+	}
+	return account.magicLinkEnabled, nil
+}
+
+func (r *MemoryMagicLinkRepository) SetEnabled(ctx context.Context, userID int, enabled bool, at time.Time) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	if account, ok := r.users.accounts[userID]; ok {
+		account.magicLinkEnabled = enabled
+	}
+	if !enabled {
+		spendLinks(r.users.magicLinks, userID, at)
+	}
+	return nil
+}
+
+func (r *MemoryMagicLinkRepository) SentSince(ctx context.Context, userID int, since time.Time) (bool, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, link := range r.users.magicLinks {
+		if link.userID == userID && link.CreatedAt.After(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryMagicLinkRepository) Create(ctx context.Context, userID int, link Link, email Email) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	r.users.magicLinks = append(r.users.magicLinks, &memoryLink{Link: link, userID: userID})
+	r.users.emails = append(r.users.emails, email)
+	return nil
+}
+
+func (r *MemoryMagicLinkRepository) Redeem(ctx context.Context, tokenHash string, at time.Time) (int, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, link := range r.users.magicLinks {
+		if link.TokenHash != tokenHash || !link.usable(at) {
+			continue
+		}
+		account, ok := r.users.accounts[link.userID]
+		if !ok || !account.magicLinkEnabled {
+			return 0, ErrInvalidMagicLink
+		}
+		spendLinks(r.users.magicLinks, link.userID, at)
+		if account.emailVerifiedAt == nil {
+			account.emailVerifiedAt = &at
+		}
+		return link.userID, nil
+	}
+	return 0, ErrInvalidMagicLink
+}
@@ -0,0 +1,65 @@
+package user_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMagicLink verifies that magic links are opt-in, stored hashed and single use
+func TestMagicLink(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	userID, _ := loginTestUser(t, service, testDB)
+	ctx := context.Background()
+
+	// Accounts that did not opt in get no link
+	assert.Equal(t, user.ErrMagicLinkUnavailable, service.SendMagicLink(ctx, testEmail, "203.0.113.1"))
+
+	assert.NoError(t, service.SetMagicLinkEnabled(ctx, userID, true))
+	assert.NoError(t, service.SendMagicLink(ctx, testEmail, "203.0.113.1"))
+	assert.Equal(t, user.ErrVerificationThrottled, service.SendMagicLink(ctx, testEmail, "203.0.113.1"))
+
+	// Only the hash of the emailed token is stored
+	var stored string
+	assert.NoError(t, testDB.QueryRow("SELECT token_hash FROM magic_links WHERE user_id = ?", userID).Scan(&stored))
+	assert.Len(t, stored, 64)
+
+	token := "known-token"
+	sum := sha256.Sum256([]byte(token))
+	now := time.Now().UTC()
+	_, err := testDB.Exec(
+		"INSERT INTO magic_links (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		userID, hex.EncodeToString(sum[:]), now, now.Add(time.Minute),
+	)
+	assert.NoError(t, err)
+
+	jwt, err := service.RedeemMagicLink(ctx, token, "test-agent", "203.0.113.1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, jwt)
+
+	// The link and the other outstanding ones are spent
+	_, err = service.RedeemMagicLink(ctx, token, "test-agent", "203.0.113.1")
+	assert.Equal(t, user.ErrInvalidMagicLink, err)
+	var unused int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM magic_links WHERE used_at IS NULL").Scan(&unused))
+	assert.Equal(t, 0, unused)
+
+	// Expired links are rejected
+	expired := "expired-token"
+	sum = sha256.Sum256([]byte(expired))
+	_, err = testDB.Exec(
+		"INSERT INTO magic_links (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		userID, hex.EncodeToString(sum[:]), now.Add(-time.Hour), now.Add(-time.Minute),
+	)
+	assert.NoError(t, err)
+	_, err = service.RedeemMagicLink(ctx, expired, "test-agent", "203.0.113.1")
+	assert.Equal(t, user.ErrInvalidMagicLink, err)
+}
@@ -0,0 +1,75 @@
+package user
+
+import (
+	"context"
+	"log"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/mail"
+	"task-manager/backend-go/internal/outbox"
+)
+
+// Mail settings, set from configuration in main. Emails are queued in the
+// outbox for the worker using Mailer; without a Mailer they are logged and dropped.
+var (
+	Mailer mail.Mailer
+	// MailFrom is the sender of every email, such as "Task Manager <noreply@example.com>".
+	MailFrom string
+	// PublicAPIURL is the base URL of the API used in links, such as "https://api.example.com".
+	PublicAPIURL string
+)
+
+// EmailTemplates renders the emails sent by the package.
+var EmailTemplates = mustLoadTemplates()
+
+// mustLoadTemplates parses the templates embedded in the binary.
+func mustLoadTemplates() *mail.Templates {
+	templates, err := mail.EmbeddedTemplates()
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// publicURL builds a link to the public API for the given path and query.
+func publicURL(path string) string {
+	return PublicAPIURL + path
+}
+
+// recipientLocale returns the language of the emails sent to the user: their
+// preferred one, else the locale of the request that triggers the email, which
+// is the default locale for background jobs.
+func (s *Service) recipientLocale(ctx context.Context, userID int) string {
+	if locale := s.PreferredLocale(ctx, userID); locale != "" {
+		return locale
+	}
+	return i18n.LocaleFromContext(ctx)
+}
+
+// Email is a message to send once the change that triggers it is stored.
+// Repositories queue it in the same transaction, so the email goes out only
+// if the change commits.
+type Email struct {
+	// Key makes the email idempotent.
+	Key      string
+	To       string
+	Locale   string
+	Template string
+	Data     map[string]any
+}
+
+// Queue renders the template in the locale of the email, falling back to the
+// default translations for missing keys, and adds it to the outbox through db.
+func (e Email) Queue(ctx context.Context, db outbox.Execer) error {
+	if Mailer == nil {
+		log.Printf("Email not configured, %s to %s not sent", e.Template, e.To)
+		return nil
+	}
+
+	msg, err := EmailTemplates.Render(e.Template, func(key string) string { return i18n.Lookup(e.Locale, key) }, e.Data)
+	if err != nil {
+		return err
+	}
+	msg.From = MailFrom
+	msg.To = []string{e.To}
+	return outbox.Enqueue(ctx, db, e.Key, msg)
+}
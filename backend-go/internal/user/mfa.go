@@ -0,0 +1,339 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/totp"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+// mfaIssuer is the issuer name shown by authenticator apps.
+const mfaIssuer = "Task Manager"
+
+// recoveryCodeCount is the number of one-time recovery codes issued on confirmation.
+const recoveryCodeCount = 10
+
+// challengeAttempts is the number of codes accepted for one MFA challenge token.
+const challengeAttempts = 3
+
+var (
+	// ErrMFARequired is returned by LoginUser together with a challenge token
+	// when the account has two-factor authentication enabled.
+	ErrMFARequired = errors.New("mfa_required")
+	// ErrMFAAlreadyEnabled is returned when enrolling an account that already uses 2FA.
+	ErrMFAAlreadyEnabled = errors.New("mfa_already_enabled")
+	// ErrMFANotEnrolled is returned when confirming or disabling without a TOTP secret.
+	ErrMFANotEnrolled = errors.New("mfa_not_enrolled")
+	// ErrInvalidMFACode is returned when a TOTP or recovery code is wrong or already used.
+	ErrInvalidMFACode = errors.New("invalid_mfa_code")
+	// ErrInvalidChallenge is returned when the MFA challenge token is invalid or expired.
+	ErrInvalidChallenge = errors.New("invalid_mfa_challenge")
+)
+
+// EnrollMFA generates a new TOTP secret for the user and returns it with its otpauth:// URI.
+// Two-factor authentication stays disabled until ConfirmMFA succeeds.
+func (s *Service) EnrollMFA(ctx context.Context, userID int) (string, string, error) {
+	state, err := s.MFA.State(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if state.Enabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.MFA.Enroll(ctx, userID, secret); err != nil {
+		return "", "", err
+	}
+
+	return secret, s.TOTP.URI(mfaIssuer, state.Email, secret), nil
+}
+
+// ConfirmMFA enables two-factor authentication once the user proves the
+// authenticator works, and returns freshly generated recovery codes. Wrong
+// codes are throttled like those of VerifyMFA.
+func (s *Service) ConfirmMFA(ctx context.Context, userID int, code string) ([]string, error) {
+	if err := checkMFAAttempts(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	state, err := s.MFA.State(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if state.Secret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	counter, ok := s.TOTP.Validate(state.Secret, strings.TrimSpace(code))
+	if err := recordMFAAttempt(ctx, userID, "", ok); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = generateRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	enabled, err := s.MFA.Enable(ctx, userID, state.Secret, counter, hashes, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		// A concurrent request confirmed or enrolled again in the meantime
+		return nil, ErrMFAAlreadyEnabled
+	}
+	return codes, nil
+}
+
+// DisableMFA turns off two-factor authentication after checking a current
+// code, which is spent in the same transaction. Wrong codes are throttled
+// like those of VerifyMFA.
+func (s *Service) DisableMFA(ctx context.Context, userID int, code string) error {
+	if err := checkMFAAttempts(ctx, userID); err != nil {
+		return err
+	}
+
+	state, err := s.MFA.State(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !state.Enabled || state.Secret == "" {
+		return ErrMFANotEnrolled
+	}
+
+	disabled, err := s.MFA.Disable(ctx, userID, s.mfaCode(state.Secret, code))
+	if err != nil {
+		return err
+	}
+	return recordMFAAttempt(ctx, userID, "", disabled)
+}
+
+// VerifyMFA completes a two-factor login: it checks the challenge token issued
+// by LoginUser and the TOTP or recovery code, then starts a normal session.
+// Wrong codes are throttled per user by LoginGuard, which returns a
+// *loginguard.LockedError, and a challenge accepts challengeAttempts codes and
+// is spent by a successful login.
+func (s *Service) VerifyMFA(ctx context.Context, req *models.MFAVerifyRequest) (string, error) {
+	userID, err := auth.ParseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		return "", ErrInvalidChallenge
+	}
+
+	if err := checkMFAAttempts(ctx, userID); err != nil {
+		return "", err
+	}
+	challenge := hashToken(req.ChallengeToken)
+	if ok, err := LoginGuard.UseChallenge(ctx, challenge, challengeAttempts); err != nil {
+		return "", err
+	} else if !ok {
+		return "", ErrInvalidChallenge
+	}
+
+	if err := s.verifyMFACode(ctx, userID, req.Code); err != nil {
+		if err == ErrInvalidMFACode {
+			recordMFAAttempt(ctx, userID, req.IP, false)
+		}
+		return "", err
+	}
+
+	if err := LoginGuard.SpendChallenge(ctx, challenge, time.Now().Add(auth.ChallengeTTL)); err != nil {
+		return "", err
+	}
+	recordMFAAttempt(ctx, userID, req.IP, true)
+	return s.createSession(ctx, userID, req.UserAgent, req.IP)
+}
+
+// verifyMFACode spends a TOTP code that was not used before, or an unused recovery code.
+func (s *Service) verifyMFACode(ctx context.Context, userID int, code string) error {
+	state, err := s.MFA.State(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !state.Enabled || state.Secret == "" {
+		return ErrMFANotEnrolled
+	}
+
+	used, err := s.MFA.UseCode(ctx, userID, s.mfaCode(state.Secret, code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// mfaCode resolves code to the time step of a valid TOTP code, or else to a
+// recovery code.
+func (s *Service) mfaCode(secret, code string) MFACode {
+	code = strings.TrimSpace(code)
+	if counter, ok := s.TOTP.Validate(secret, code); ok {
+		return MFACode{Counter: counter}
+	}
+	return MFACode{RecoveryHash: hashToken(normalizeRecoveryCode(code)), At: time.Now().UTC()}
+}
+
+// checkMFAAttempts returns the *loginguard.LockedError of LoginGuard while
+// the user has entered too many wrong codes.
+func checkMFAAttempts(ctx context.Context, userID int) error {
+	if err := LoginGuard.CheckMFA(ctx, userID); err != nil {
+		if _, ok := err.(*loginguard.LockedError); ok {
+			return err
+		}
+		log.Printf("Error checking MFA attempts: %v", err)
+	}
+	return nil
+}
+
+// recordMFAAttempt tells LoginGuard whether a code of the user was accepted,
+// and returns ErrInvalidMFACode when it was not.
+func recordMFAAttempt(ctx context.Context, userID int, ip string, accepted bool) error {
+	if !accepted {
+		if err := LoginGuard.FailureMFA(ctx, userID, ip); err != nil {
+			log.Printf("Error recording MFA attempt: %v", err)
+		}
+		return ErrInvalidMFACode
+	}
+	if err := LoginGuard.SuccessMFA(ctx, userID); err != nil {
+		log.Printf("Error clearing MFA attempts: %v", err)
+	}
+	return nil
+}
+
+// generateRecoveryCode creates a random code formatted as xxxxx-xxxxx.
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 7)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode makes recovery codes comparable regardless of case and separators.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// MFA manages two-factor enrollment for the authenticated user:
+// POST /api/user/mfa/enroll, POST /api/user/mfa/confirm and DELETE /api/user/mfa.
+func (h *Handler) MFA(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/user/mfa"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && action == "enroll":
+		secret, uri, err := h.Service.EnrollMFA(r.Context(), userID)
+		if err == ErrMFAAlreadyEnabled {
+			problem.Write(w, r, http.StatusConflict, problem.MFAAlreadyEnabled)
+			return
+		} else if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.MFAEnrollFailed)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"secret": secret, "otpauth_uri": uri})
+
+	case r.Method == http.MethodPost && action == "confirm":
+		var req models.MFACodeRequest
+		if !validate.Decode(w, r, &req) {
+			return
+		}
+		codes, err := h.Service.ConfirmMFA(r.Context(), userID, req.Code)
+		if err != nil {
+			respondWithMFAError(w, r, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]any{
+			"message":        i18n.Translate(r.Context(), "mfa.success.enabled"),
+			"recovery_codes": codes,
+		})
+
+	case r.Method == http.MethodDelete && action == "":
+		var req models.MFACodeRequest
+		if !validate.Decode(w, r, &req) {
+			return
+		}
+		if err := h.Service.DisableMFA(r.Context(), userID, req.Code); err != nil {
+			respondWithMFAError(w, r, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "mfa.success.disabled")})
+
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+	}
+}
+
+// MFAVerify exchanges a challenge token and a TOTP or recovery code for a JWT.
+func (h *Handler) MFAVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	var req models.MFAVerifyRequest
+	if !validate.Decode(w, r, &req) {
+		return
+	}
+	req.UserAgent = r.UserAgent()
+	req.IP = auth.ClientIP(r)
+
+	token, err := h.Service.VerifyMFA(r.Context(), &req)
+	if err != nil {
+		respondWithMFAError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": i18n.Translate(r.Context(), "login_success"),
+		"token":   token,
+	})
+}
+
+// respondWithMFAError maps two-factor errors to HTTP responses.
+func respondWithMFAError(w http.ResponseWriter, r *http.Request, err error) {
+	if locked, ok := err.(*loginguard.LockedError); ok {
+		respondWithLockout(w, r, locked)
+		return
+	}
+
+	switch err {
+	case ErrMFANotEnrolled:
+		problem.Write(w, r, http.StatusBadRequest, problem.MFANotEnrolled)
+	case ErrMFAAlreadyEnabled:
+		problem.Write(w, r, http.StatusConflict, problem.MFAAlreadyEnabled)
+	case ErrInvalidMFACode:
+		problem.Write(w, r, http.StatusUnauthorized, problem.InvalidMFACode)
+	case ErrInvalidChallenge:
+		problem.Write(w, r, http.StatusUnauthorized, problem.InvalidToken)
+	case sql.ErrNoRows:
+		problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
+	default:
+		problem.Write(w, r, http.StatusInternalServerError, problem.MFAVerifyFailed)
+	}
+}
@@ -0,0 +1,222 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"task-manager/backend-go/db"
+)
+
+// MFAState is the two-factor configuration of an account.
+type MFAState struct {
+	Email string
+	// Secret is the TOTP secret, "" before enrollment.
+	Secret  string
+	Enabled bool
+}
+
+// MFACode is a second factor to spend: the time step of a valid TOTP code,
+// or the hash of a recovery code when RecoveryHash is set.
+type MFACode struct {
+	Counter      int64
+	RecoveryHash string
+	At           time.Time
+}
+
+// MFARepository stores the TOTP secrets and recovery codes of the accounts.
+type MFARepository interface {
+	// State returns the two-factor configuration of the user, sql.ErrNoRows
+	// when there is no such user.
+	State(ctx context.Context, userID int) (MFAState, error)
+	// Enroll stores a new TOTP secret, not enabled until Enable.
+	Enroll(ctx context.Context, userID int, secret string) error
+	// Enable turns on two-factor authentication with the time step of the
+	// confirming code and replaces the recovery codes with codeHashes. It
+	// reports false when it is already enabled or secret was replaced by
+	// another enrollment.
+	Enable(ctx context.Context, userID int, secret string, counter int64, codeHashes []string, at time.Time) (bool, error)
+	// Disable spends code and turns off two-factor authentication, deleting
+	// the recovery codes, in one transaction. It reports false when the code
+	// was already used.
+	Disable(ctx context.Context, userID int, code MFACode) (bool, error)
+	// UseCode spends code. It reports false when the TOTP time step or a
+	// later one was already used, so that a code cannot be replayed, also by
+	// two concurrent requests, or when there is no such unused recovery code.
+	UseCode(ctx context.Context, userID int, code MFACode) (bool, error)
+}
+
+// SQLMFARepository stores two-factor settings in the users and
+// mfa_recovery_codes tables.
+type SQLMFARepository struct {
+	DB *sql.DB
+}
+
+// NewSQLMFARepository creates a repository over db.
+func NewSQLMFARepository(db *sql.DB) *SQLMFARepository {
+	return &SQLMFARepository{DB: db}
+}
+
+func (r *SQLMFARepository) State(ctx context.Context, userID int) (MFAState, error) {
+	var state MFAState
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx, "SELECT email, totp_secret, totp_enabled_at FROM users WHERE id = ?", userID).
+		Scan(&state.Email, &secret, &enabledAt)
+	state.Secret = secret.String
+	state.Enabled = enabledAt.Valid
+	return state, err
+}
+
+func (r *SQLMFARepository) Enroll(ctx context.Context, userID int, secret string) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE users SET totp_secret = ?, totp_last_counter = NULL WHERE id = ?", secret, userID)
+	return err
+}
+
+func (r *SQLMFARepository) Enable(ctx context.Context, userID int, secret string, counter int64, codeHashes []string, at time.Time) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := affected(tx.ExecContext(ctx,
+		"UPDATE users SET totp_enabled_at = ?, totp_last_counter = ? WHERE id = ? AND totp_secret = ? AND totp_enabled_at IS NULL",
+		at, counter, userID, secret,
+	)); !ok || err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return false, err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+func (r *SQLMFARepository) Disable(ctx context.Context, userID int, code MFACode) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := useMFACode(ctx, tx, userID, code); !ok || err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL WHERE id = ?", userID,
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *SQLMFARepository) UseCode(ctx context.Context, userID int, code MFACode) (bool, error) {
+	return useMFACode(ctx, r.DB, userID, code)
+}
+
+// useMFACode spends code on a *sql.DB or inside a *sql.Tx.
+func useMFACode(ctx context.Context, q db.Queryer, userID int, code MFACode) (bool, error) {
+	if code.RecoveryHash != "" {
+		return affected(q.ExecContext(ctx,
+			"UPDATE mfa_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+			code.At, userID, code.RecoveryHash,
+		))
+	}
+	return affected(q.ExecContext(ctx,
+		"UPDATE users SET totp_last_counter = ? WHERE id = ? AND (totp_last_counter IS NULL OR totp_last_counter < ?)",
+		code.Counter, userID, code.Counter,
+	))
+}
+
+// MemoryMFARepository keeps two-factor settings with the accounts of a
+// MemoryUserRepository, for tests.
+type MemoryMFARepository struct {
+	users *MemoryUserRepository
+}
+
+// NewMemoryMFARepository creates a repository over the accounts of users.
+func NewMemoryMFARepository(users *MemoryUserRepository) *MemoryMFARepository {
+	return &MemoryMFARepository{users: users}
+}
+
+func (r *MemoryMFARepository) State(ctx context.Context, userID int) (MFAState, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	account, ok := r.users.accounts[userID]
+	if !ok {
+		return MFAState{}, sql.ErrNoRows
+	}
+	return MFAState{Email: account.Email, Secret: account.mfaSecret, Enabled: account.mfaEnabledAt != nil}, nil
+}
+
+func (r *MemoryMFARepository) Enroll(ctx context.Context, userID int, secret string) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	if account, ok := r.users.accounts[userID]; ok {
+		account.mfaSecret, account.mfaLastCounter = secret, nil
+	}
+	return nil
+}
+
+func (r *MemoryMFARepository) Enable(ctx context.Context, userID int, secret string, counter int64, codeHashes []string, at time.Time) (bool, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	account, ok := r.users.accounts[userID]
+	if !ok || account.mfaSecret != secret || account.mfaEnabledAt != nil {
+		return false, nil
+	}
+	account.recoveryCodes = make(map[string]bool)
+	for _, hash := range codeHashes {
+		account.recoveryCodes[hash] = false
+	}
+	account.mfaEnabledAt, account.mfaLastCounter = &at, &counter
+	return true, nil
+}
+
+func (r *MemoryMFARepository) Disable(ctx context.Context, userID int, code MFACode) (bool, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	account, ok := r.users.accounts[userID]
+	if !ok || !account.useMFACode(code) {
+		return false, nil
+	}
+	account.recoveryCodes = make(map[string]bool)
+	account.mfaSecret, account.mfaEnabledAt, account.mfaLastCounter = "", nil, nil
+	return true, nil
+}
+
+func (r *MemoryMFARepository) UseCode(ctx context.Context, userID int, code MFACode) (bool, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	account, ok := r.users.accounts[userID]
+	return ok && account.useMFACode(code), nil
+}
+
+// useMFACode spends code, reporting false when it was already used.
+func (a *memoryAccount) useMFACode(code MFACode) bool {
+	if code.RecoveryHash != "" {
+		if used, exists := a.recoveryCodes[code.RecoveryHash]; !exists || used {
+			return false
+		}
+		a.recoveryCodes[code.RecoveryHash] = true
+		return true
+	}
+	if a.mfaLastCounter != nil && *a.mfaLastCounter >= code.Counter {
+		return false
+	}
+	a.mfaLastCounter = &code.Counter
+	return true
+}
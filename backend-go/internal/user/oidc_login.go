@@ -0,0 +1,257 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/oidc"
+	"task-manager/backend-go/internal/problem"
+	"time"
+)
+
+const (
+	// purposeOIDCFlow marks the signed cookie carrying state, nonce and PKCE verifier.
+	purposeOIDCFlow = "oidc"
+	// oidcFlowCookie is the name of that cookie.
+	oidcFlowCookie = "oidc_flow"
+	// oidcFlowTTL bounds how long the user may take at the provider.
+	oidcFlowTTL = 10 * time.Minute
+)
+
+// OIDCProvider is the external identity provider; OIDC login is disabled when nil.
+var OIDCProvider *oidc.Provider
+
+// OIDCPostLoginURL is where the browser is sent after an OIDC login, with the
+// token in the URL fragment. When empty, the callback answers with JSON.
+var OIDCPostLoginURL string
+
+// ErrOIDCEmailUnverified is returned when a new identity has no verified email to provision an account with.
+var ErrOIDCEmailUnverified = errors.New("oidc_email_unverified")
+
+// ErrOIDCAccountUnverified is returned when the local account with the email
+// of a new identity has not verified it. Whoever registered it may not own the
+// address, so the identity is not linked to it.
+var ErrOIDCAccountUnverified = errors.New("oidc_account_unverified")
+
+// LoginWithOIDC signs in the user behind a verified ID token. Known identities
+// log straight in; otherwise a local account that verified the same email is
+// linked, or a new account (without password) is provisioned.
+// Like LoginUser, it returns a challenge token with ErrMFARequired when 2FA is enabled.
+func (s *Service) LoginWithOIDC(ctx context.Context, issuer string, claims *oidc.Claims, userAgent, ip string) (string, error) {
+	userID, err := s.Identities.User(ctx, issuer, claims.Subject)
+	if err == sql.ErrNoRows {
+		if userID, err = s.linkOrProvisionOIDCUser(ctx, issuer, claims); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	mfa, err := s.MFA.State(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if mfa.Enabled {
+		challenge, err := auth.GenerateChallengeToken(userID)
+		if err != nil {
+			return "", err
+		}
+		return challenge, ErrMFARequired
+	}
+
+	return s.createSession(ctx, userID, userAgent, ip)
+}
+
+// linkOrProvisionOIDCUser attaches a new identity to the account with the same
+// email, or creates an account for it. Both the provider and the account must
+// have verified the email.
+func (s *Service) linkOrProvisionOIDCUser(ctx context.Context, issuer string, claims *oidc.Claims) (int, error) {
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return 0, ErrOIDCEmailUnverified
+	}
+
+	usernames, err := usernameCandidates(claims)
+	if err != nil {
+		return 0, err
+	}
+	name, surname := oidcNames(claims)
+	identity := Identity{Provider: issuer, Subject: claims.Subject, Email: email, CreatedAt: time.Now().UTC()}
+	return s.Identities.Link(ctx, identity, OIDCAccount{Name: name, Surname: surname, Usernames: usernames})
+}
+
+// usernameCandidates derives the usernames a provisioned account may take from
+// the preferred username or the email: the sanitized name itself, then the
+// name with random suffixes.
+func usernameCandidates(claims *oidc.Claims) ([]string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = sanitizeUsername(base)
+	if base == "" {
+		base = "user"
+	}
+
+	candidates := []string{base}
+	for len(candidates) < 10 {
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return nil, err
+		}
+		suffix := fmt.Sprintf("%04d", n.Int64())
+		candidates = append(candidates, truncate(base, 50-len(suffix))+suffix)
+	}
+	return candidates, nil
+}
+
+// sanitizeUsername keeps letters, digits, dots, dashes and underscores.
+func sanitizeUsername(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return truncate(b.String(), 50)
+}
+
+// oidcNames splits the profile claims into the name and surname columns.
+func oidcNames(claims *oidc.Claims) (string, string) {
+	name, surname := claims.GivenName, claims.FamilyName
+	if name == "" && claims.Name != "" {
+		parts := strings.SplitN(claims.Name, " ", 2)
+		name = parts[0]
+		if surname == "" && len(parts) > 1 {
+			surname = parts[1]
+		}
+	}
+	if name == "" {
+		name = sanitizeUsername(strings.SplitN(claims.Email, "@", 2)[0])
+	}
+	return truncate(name, 20), truncate(surname, 50)
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// OIDCLogin starts the authorization code flow: it stores state, nonce
+// and PKCE verifier in a signed cookie and redirects to the provider.
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if OIDCProvider == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.OIDCDisabled)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.TokenGenerationFailed)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	flow, err := auth.GeneratePurposeToken(purposeOIDCFlow, map[string]any{
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+	}, oidcFlowTTL)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.TokenGenerationFailed)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    flow,
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcFlowTTL / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, OIDCProvider.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// OIDCCallback completes the flow: it checks the state against the
+// cookie, exchanges the code and signs the user in.
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if OIDCProvider == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.OIDCDisabled)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.OIDCInvalidState)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcFlowCookie, Path: "/auth/oidc", MaxAge: -1, HttpOnly: true})
+
+	flow, err := auth.ParsePurposeToken(purposeOIDCFlow, cookie.Value)
+	query := r.URL.Query()
+	if err != nil || query.Get("state") == "" || flow["state"] != query.Get("state") {
+		problem.Write(w, r, http.StatusBadRequest, problem.OIDCInvalidState)
+		return
+	}
+	if query.Get("error") != "" || query.Get("code") == "" {
+		problem.Write(w, r, http.StatusUnauthorized, problem.LoginFailed)
+		return
+	}
+
+	nonce, _ := flow["nonce"].(string)
+	verifier, _ := flow["verifier"].(string)
+	claims, err := OIDCProvider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		problem.Write(w, r, http.StatusUnauthorized, problem.LoginFailed)
+		return
+	}
+
+	token, err := h.Service.LoginWithOIDC(r.Context(), OIDCProvider.Issuer(), claims, r.UserAgent(), auth.ClientIP(r))
+	field := "token"
+	switch {
+	case err == ErrMFARequired:
+		field = "challenge_token"
+	case err == ErrOIDCEmailUnverified:
+		problem.Write(w, r, http.StatusForbidden, problem.OIDCEmailUnverified)
+		return
+	case err == ErrOIDCAccountUnverified:
+		problem.Write(w, r, http.StatusConflict, problem.OIDCAccountUnverified)
+		return
+	case err != nil:
+		log.Printf("OIDC login failed: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.LoginFailed)
+		return
+	}
+
+	if OIDCPostLoginURL != "" {
+		// JWTs are URL-safe, so the token can be placed in the fragment as is
+		http.Redirect(w, r, OIDCPostLoginURL+"#"+field+"="+token, http.StatusFound)
+		return
+	}
+
+	response := map[string]any{"message": i18n.Translate(r.Context(), "login_success"), field: token}
+	if field == "challenge_token" {
+		response["message"] = i18n.Translate(r.Context(), "mfa.required")
+		response["mfa_required"] = true
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,137 @@
+package user_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/oidc"
+	"task-manager/backend-go/internal/oidc/oidctest"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const oidcCallbackURL = "http://localhost:8080/auth/oidc/callback"
+
+// oidcLogin drives the login and callback handlers through the stand-in provider
+// and returns the token issued by the callback.
+func oidcLogin(t *testing.T, handler *user.Handler) string {
+	rec := httptest.NewRecorder()
+	handler.OIDCLogin(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	cookies := rec.Result().Cookies()
+
+	// The provider authenticates immediately and redirects back with code and state
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(rec.Header().Get("Location"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	callback := httptest.NewRequest(http.MethodGet, resp.Header.Get("Location"), nil)
+	for _, c := range cookies {
+		callback.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	handler.OIDCCallback(rec, callback)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var body map[string]any
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	token, _ := body["token"].(string)
+	return token
+}
+
+// TestOIDCLogin_LinksAndProvisions verifies linking by verified email and auto-provisioning
+func TestOIDCLogin_LinksAndProvisions(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	server := oidctest.NewServer("task-manager", "s3cret")
+	defer server.Close()
+
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "task-manager",
+		ClientSecret: "s3cret",
+		RedirectURL:  oidcCallbackURL,
+	}, nil)
+	assert.NoError(t, err)
+	user.OIDCProvider = provider
+	defer func() { user.OIDCProvider = nil }()
+
+	// Existing password account with the same verified email gets linked
+	service := user.NewService(testDB)
+	handler := user.NewHandler(service)
+	passwordUserID, _ := loginTestUser(t, service, testDB)
+	_, err = testDB.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ?", passwordUserID)
+	assert.NoError(t, err)
+	server.SetUser(oidctest.User{Subject: "sub-thor", Email: testEmail, EmailVerified: true})
+
+	userID, err := auth.ParseToken(oidcLogin(t, handler))
+	assert.NoError(t, err)
+	assert.Equal(t, passwordUserID, userID)
+
+	// The identity is remembered, and password login keeps working
+	userID, err = auth.ParseToken(oidcLogin(t, handler))
+	assert.NoError(t, err)
+	assert.Equal(t, passwordUserID, userID)
+	_, err = service.LoginUser(context.Background(), &models.LoginRequest{Username: testUsername, Password: testPassword})
+	assert.NoError(t, err)
+
+	// Unknown identity with a new email gets a passwordless account
+	server.SetUser(oidctest.User{Subject: "sub-loki", Email: "loki@example.com", EmailVerified: true, PreferredUsername: testUsername, GivenName: "Loki"})
+	userID, err = auth.ParseToken(oidcLogin(t, handler))
+	assert.NoError(t, err)
+	assert.NotEqual(t, passwordUserID, userID)
+
+	var username, name string
+	err = testDB.QueryRow("SELECT username, name FROM users WHERE id = ?", userID).Scan(&username, &name)
+	assert.NoError(t, err)
+	assert.NotEqual(t, testUsername, username, "provisioned username must be unique")
+	assert.Equal(t, "Loki", name)
+
+	_, err = service.LoginUser(context.Background(), &models.LoginRequest{Username: username, Password: ""})
+	assert.Error(t, err)
+}
+
+// TestOIDCLogin_UnverifiedEmailNotLinked ensures unverified emails never take over an account
+func TestOIDCLogin_UnverifiedEmailNotLinked(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	service := user.NewService(testDB)
+	loginTestUser(t, service, testDB)
+
+	_, err := service.LoginWithOIDC(context.Background(), "https://idp.example.com", &oidc.Claims{
+		Email:         testEmail,
+		EmailVerified: false,
+	}, "", "")
+	assert.ErrorIs(t, err, user.ErrOIDCEmailUnverified)
+}
+
+// TestOIDCLogin_UnverifiedAccountNotLinked ensures an identity is not linked to
+// an account registered by someone who never proved they own the email
+func TestOIDCLogin_UnverifiedAccountNotLinked(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+
+	service := user.NewService(testDB)
+	loginTestUser(t, service, testDB)
+
+	_, err := service.LoginWithOIDC(context.Background(), "https://idp.example.com", &oidc.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-thor"},
+		Email:            testEmail,
+		EmailVerified:    true,
+	}, "", "")
+	assert.ErrorIs(t, err, user.ErrOIDCAccountUnverified)
+
+	var identities int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM user_identities").Scan(&identities))
+	assert.Equal(t, 0, identities)
+}
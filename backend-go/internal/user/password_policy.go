@@ -0,0 +1,25 @@
+package user
+
+import (
+	"net/http"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/passwordpolicy"
+	"task-manager/backend-go/internal/problem"
+)
+
+// PasswordPolicy applies to every new password; set from configuration in main.
+var PasswordPolicy = passwordpolicy.Default()
+
+// respondWithPasswordError answers 400 with one localized field error per
+// failed rule of the password in field.
+func respondWithPasswordError(w http.ResponseWriter, r *http.Request, field string, err *passwordpolicy.ValidationError) {
+	p := problem.New(r, http.StatusBadRequest, problem.PasswordNotSecure)
+	for _, rule := range err.Rules {
+		p.Errors = append(p.Errors, problem.FieldError{
+			Field:  field,
+			Code:   rule,
+			Detail: i18n.Translate(r.Context(), "password.rule."+rule),
+		})
+	}
+	p.Write(w)
+}
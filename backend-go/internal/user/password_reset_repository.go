@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PasswordResetRepository stores the password reset links sent to the accounts.
+type PasswordResetRepository interface {
+	// Outstanding counts the unused resets of the user that expire after now.
+	Outstanding(ctx context.Context, userID int, now time.Time) (int, error)
+	// Create stores a reset of the user and queues its email, in one transaction.
+	Create(ctx context.Context, userID int, reset Link, email Email) error
+	// Find returns the account of the unused reset with the hash that expires
+	// after now, sql.ErrNoRows when there is none.
+	Find(ctx context.Context, tokenHash string, now time.Time) (int, error)
+	// Redeem spends the reset with the hash, sets the password of its account
+	// and spends the other outstanding resets of the account, in one
+	// transaction. It returns ErrInvalidResetToken when the reset was spent
+	// since Find.
+	Redeem(ctx context.Context, tokenHash string, userID int, passwordHash []byte, at time.Time) error
+}
+
+// SQLPasswordResetRepository stores resets in the password_resets table.
+type SQLPasswordResetRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLPasswordResetRepository creates a repository over db.
+func NewSQLPasswordResetRepository(db *sql.DB) *SQLPasswordResetRepository {
+	return &SQLPasswordResetRepository{DB: db}
+}
+
+func (r *SQLPasswordResetRepository) Outstanding(ctx context.Context, userID int, now time.Time) (int, error) {
+	var outstanding int
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM password_resets WHERE user_id = ? AND used_at IS NULL AND expires_at > ?", userID, now,
+	).Scan(&outstanding)
+	return outstanding, err
+}
+
+func (r *SQLPasswordResetRepository) Create(ctx context.Context, userID int, reset Link, email Email) error {
+	return insertLink(ctx, r.DB, "password_resets", userID, reset, email)
+}
+
+func (r *SQLPasswordResetRepository) Find(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	var userID int
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT user_id FROM password_resets WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now,
+	).Scan(&userID)
+	return userID, err
+}
+
+func (r *SQLPasswordResetRepository) Redeem(ctx context.Context, tokenHash string, userID int, passwordHash []byte, at time.Time) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Spending the reset is the guard against concurrent use of the same link
+	spent, err := affected(tx.ExecContext(ctx,
+		"UPDATE password_resets SET used_at = ? WHERE token_hash = ? AND used_at IS NULL", at, tokenHash,
+	))
+	if err != nil {
+		return err
+	}
+	if !spent {
+		return ErrInvalidResetToken
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", passwordHash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL", at, userID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MemoryPasswordResetRepository keeps resets in a MemoryUserRepository, for tests.
+type MemoryPasswordResetRepository struct {
+	users *MemoryUserRepository
+}
+
+// NewMemoryPasswordResetRepository creates a repository over the accounts of users.
+func NewMemoryPasswordResetRepository(users *MemoryUserRepository) *MemoryPasswordResetRepository {
+	return &MemoryPasswordResetRepository{users: users}
+}
+
+func (r *MemoryPasswordResetRepository) Outstanding(ctx context.Context, userID int, now time.Time) (int, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	outstanding := 0
+	for _, reset := range r.users.resets {
+		if reset.userID == userID && reset.usable(now) {
+			outstanding++
+		}
+	}
+	return outstanding, nil
+}
+
+func (r *MemoryPasswordResetRepository) Create(ctx context.Context, userID int, reset Link, email Email) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	r.users.resets = append(r.users.resets, &memoryLink{Link: reset, userID: userID})
+	r.users.emails = append(r.users.emails, email)
+	return nil
+}
+
+func (r *MemoryPasswordResetRepository) Find(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, reset := range r.users.resets {
+		if reset.TokenHash == tokenHash && reset.usable(now) {
+			return reset.userID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (r *MemoryPasswordResetRepository) Redeem(ctx context.Context, tokenHash string, userID int, passwordHash []byte, at time.Time) error {
+	r.users.mu.Lock()
+	defer r.users.mu.Unlock()
+
+	for _, reset := range r.users.resets {
+		if reset.TokenHash != tokenHash || reset.usedAt != nil {
+			continue
+		}
+		if account, ok := r.users.accounts[userID]; ok {
+			account.password = passwordHash
+		}
+		spendLinks(r.users.resets, userID, at)
+		return nil
+	}
+	return ErrInvalidResetToken
+}
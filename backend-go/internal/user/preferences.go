@@ -0,0 +1,178 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+// First days of the week.
+const (
+	WeekStartMonday = "monday"
+	WeekStartSunday = "sunday"
+)
+
+// Default orders of the task list.
+const (
+	TaskSortCreatedAsc  = "created_asc"
+	TaskSortCreatedDesc = "created_desc"
+	TaskSortDueDate     = "due_date"
+	TaskSortTitle       = "title"
+	TaskSortStatus      = "status"
+)
+
+// dateLayouts maps the date formats users can pick to Go layouts.
+var dateLayouts = map[string]string{
+	"YYYY-MM-DD": time.DateOnly,
+	"DD/MM/YYYY": "02/01/2006",
+	"MM/DD/YYYY": "01/02/2006",
+	"DD.MM.YYYY": "02.01.2006",
+}
+
+// ErrInvalidPreferences is returned for an unknown locale, time zone, week
+// start, date format or task order.
+var ErrInvalidPreferences = errors.New("invalid_preferences")
+
+// DefaultPreferences are the preferences of users who never changed them.
+func DefaultPreferences() models.UserPreferences {
+	return models.UserPreferences{
+		TimeZone:   "UTC",
+		WeekStart:  WeekStartMonday,
+		DateFormat: "YYYY-MM-DD",
+		TaskSort:   TaskSortCreatedAsc,
+	}
+}
+
+// Preferences returns the preferences of the user, the defaults when never set.
+func (s *Service) Preferences(ctx context.Context, userID int) (models.UserPreferences, error) {
+	prefs, err := s.Users.Preferences(ctx, userID)
+	if err == sql.ErrNoRows {
+		return DefaultPreferences(), nil
+	}
+	return prefs, err
+}
+
+// SetPreferences validates and stores the preferences of the user. The locale
+// is stored as the catalog locale it matches, such as "ca" for "ca-ES".
+func (s *Service) SetPreferences(ctx context.Context, userID int, prefs models.UserPreferences) (models.UserPreferences, error) {
+	if prefs.Locale != "" {
+		if i18n.Default == nil || i18n.Default.Match(prefs.Locale) == "" {
+			return prefs, ErrInvalidPreferences
+		}
+		prefs.Locale = i18n.Default.Match(prefs.Locale)
+	}
+	if prefs.TimeZone == "" || prefs.TimeZone == "Local" {
+		return prefs, ErrInvalidPreferences
+	}
+	if _, err := time.LoadLocation(prefs.TimeZone); err != nil {
+		return prefs, ErrInvalidPreferences
+	}
+	if prefs.WeekStart != WeekStartMonday && prefs.WeekStart != WeekStartSunday {
+		return prefs, ErrInvalidPreferences
+	}
+	if _, ok := dateLayouts[prefs.DateFormat]; !ok {
+		return prefs, ErrInvalidPreferences
+	}
+	switch prefs.TaskSort {
+	case TaskSortCreatedAsc, TaskSortCreatedDesc, TaskSortDueDate, TaskSortTitle, TaskSortStatus:
+	default:
+		return prefs, ErrInvalidPreferences
+	}
+
+	if err := s.Users.SavePreferences(ctx, userID, prefs); err != nil {
+		return prefs, err
+	}
+	s.locales.set(userID, prefs.Locale, time.Now().Add(localeCacheTTL))
+	return prefs, nil
+}
+
+// localeCacheTTL is how long PreferredLocale remembers the language of a
+// user, and so how late a change made through another replica shows.
+const localeCacheTTL = time.Minute
+
+// PreferredLocale returns the language the user chose, "" when they follow
+// the browser. It lets AuthMiddleware translate the requests of the user, so
+// it is cached for localeCacheTTL.
+func (s *Service) PreferredLocale(ctx context.Context, userID int) string {
+	now := time.Now()
+	if locale, ok := s.locales.get(userID, now); ok {
+		return locale
+	}
+
+	prefs, err := s.Preferences(ctx, userID)
+	if err != nil {
+		log.Printf("Error reading preferences of user %d: %v", userID, err)
+		return ""
+	}
+	s.locales.set(userID, prefs.Locale, now.Add(localeCacheTTL))
+	return prefs.Locale
+}
+
+// Location returns the time zone of the preferences, UTC when it is unknown.
+func Location(prefs models.UserPreferences) *time.Location {
+	loc, err := time.LoadLocation(prefs.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// FirstWeekday returns the day weeks start on for the user.
+func FirstWeekday(prefs models.UserPreferences) time.Weekday {
+	if prefs.WeekStart == WeekStartSunday {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+// FormatDate formats a date in the date format of the user.
+func FormatDate(prefs models.UserPreferences, date time.Time) string {
+	layout, ok := dateLayouts[prefs.DateFormat]
+	if !ok {
+		layout = time.DateOnly
+	}
+	return date.Format(layout)
+}
+
+// Preferences reads (GET) or changes (PUT) the preferences of the
+// authenticated user at /api/user/preferences. Fields left out of a PUT keep
+// their current value.
+func (h *Handler) Preferences(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
+		return
+	}
+
+	prefs, err := h.Service.Preferences(r.Context(), userID)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		if !validate.Decode(w, r, &prefs) {
+			return
+		}
+		prefs, err = h.Service.SetPreferences(r.Context(), userID, prefs)
+		if err == ErrInvalidPreferences {
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidPreferences)
+			return
+		} else if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.UpdateFailed)
+			return
+		}
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
@@ -0,0 +1,176 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/mail"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loadTranslations loads the embedded catalog for the test and restores the previous one afterwards.
+func loadTranslations(t *testing.T) {
+	previous, previousMessages, previousLocale := i18n.Default, i18n.Messages, i18n.DefaultLocale
+	assert.NoError(t, i18n.LoadMessages("es"))
+	t.Cleanup(func() { i18n.Default, i18n.Messages, i18n.DefaultLocale = previous, previousMessages, previousLocale })
+}
+
+// TestPreferences verifies validation, storage and the preferred locale
+func TestPreferences(t *testing.T) {
+	loadTranslations(t)
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	userID, _ := loginTestUser(t, service, testDB)
+	ctx := context.Background()
+
+	prefs, err := service.Preferences(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.DefaultPreferences(), prefs)
+	assert.Equal(t, "", service.PreferredLocale(ctx, userID))
+
+	invalid := []func(*models.UserPreferences){
+		func(p *models.UserPreferences) { p.Locale = "fr" },
+		func(p *models.UserPreferences) { p.TimeZone = "Mars/Olympus" },
+		func(p *models.UserPreferences) { p.TimeZone = "" },
+		func(p *models.UserPreferences) { p.WeekStart = "friday" },
+		func(p *models.UserPreferences) { p.DateFormat = "YY/M/D" },
+		func(p *models.UserPreferences) { p.TaskSort = "random" },
+	}
+	for _, change := range invalid {
+		prefs := user.DefaultPreferences()
+		change(&prefs)
+		_, err := service.SetPreferences(ctx, userID, prefs)
+		assert.Equal(t, user.ErrInvalidPreferences, err, "%+v", prefs)
+	}
+
+	prefs = models.UserPreferences{
+		Locale:     "ca-ES",
+		TimeZone:   "America/New_York",
+		WeekStart:  user.WeekStartSunday,
+		DateFormat: "DD/MM/YYYY",
+		TaskSort:   user.TaskSortDueDate,
+	}
+	saved, err := service.SetPreferences(ctx, userID, prefs)
+	assert.NoError(t, err)
+	assert.Equal(t, "ca", saved.Locale)
+	stored, err := service.Preferences(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, saved, stored)
+	assert.Equal(t, "ca", service.PreferredLocale(ctx, userID))
+
+	// Updating keeps a single row
+	saved.Locale = ""
+	_, err = service.SetPreferences(ctx, userID, saved)
+	assert.NoError(t, err)
+	assert.Equal(t, "", service.PreferredLocale(ctx, userID))
+
+	date := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "05/10/2026", user.FormatDate(stored, date))
+	assert.Equal(t, "2026-10-05", user.FormatDate(user.DefaultPreferences(), date))
+	assert.Equal(t, time.Sunday, user.FirstWeekday(stored))
+	assert.Equal(t, "America/New_York", user.Location(stored).String())
+}
+
+// TestDigestFollowsPreferences verifies that digests use the language, time
+// zone, week start and date format of the user
+func TestDigestFollowsPreferences(t *testing.T) {
+	loadTranslations(t)
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	userID, _ := loginTestUser(t, service, testDB)
+	ctx := context.Background()
+
+	previousMailer, previousFrom := user.Mailer, user.MailFrom
+	user.Mailer, user.MailFrom = mail.NewMemoryMailer(), "noreply@example.com"
+	defer func() { user.Mailer, user.MailFrom = previousMailer, previousFrom }()
+
+	_, err := testDB.Exec("UPDATE users SET email_verified_at = ? WHERE id = ?", time.Now().UTC(), userID)
+	assert.NoError(t, err)
+	_, err = testDB.Exec("INSERT INTO tasks (user_id, title, status, due_date) VALUES (?, ?, 'pending', ?)", userID, "Overdue", "2026-10-12")
+	assert.NoError(t, err)
+
+	_, err = service.SetPreferences(ctx, userID, models.UserPreferences{
+		Locale:     "ca",
+		TimeZone:   "America/New_York",
+		WeekStart:  user.WeekStartSunday,
+		DateFormat: "DD/MM/YYYY",
+		TaskSort:   user.TaskSortCreatedAsc,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, service.SetDigestSettings(ctx, userID, models.DigestSettings{Frequency: user.DigestWeekly, Hour: 8}))
+
+	// Monday is not the first day of this user's week
+	queued, err := service.SendDigests(ctx, time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queued)
+	// 07:00 on Sunday in New York is too early, 09:00 is not
+	queued, err = service.SendDigests(ctx, time.Date(2026, 10, 25, 11, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, queued)
+	queued, err = service.SendDigests(ctx, time.Date(2026, 10, 25, 13, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, queued)
+
+	var subject, text string
+	assert.NoError(t, testDB.QueryRow("SELECT subject, text_body FROM email_outbox WHERE idempotency_key = ?", "digest:1:2026-10-25").
+		Scan(&subject, &text))
+	assert.Equal(t, i18n.Lookup("ca", "digest_email_subject_weekly"), subject)
+	assert.Contains(t, text, "Overdue (12/10/2026)")
+}
+
+// countingUsers counts the preference reads that reach the repository.
+type countingUsers struct {
+	user.UserRepository
+	reads int
+}
+
+func (r *countingUsers) Preferences(ctx context.Context, userID int) (models.UserPreferences, error) {
+	r.reads++
+	return r.UserRepository.Preferences(ctx, userID)
+}
+
+// countingSessions counts the last-seen updates that reach the repository.
+type countingSessions struct {
+	user.SessionRepository
+	touches int
+}
+
+func (r *countingSessions) Touch(ctx context.Context, sessionID, ip string, now, since time.Time) error {
+	r.touches++
+	return r.SessionRepository.Touch(ctx, sessionID, ip, now, since)
+}
+
+// TestPerRequestLookupsAreCached verifies that the locale read and the
+// last-seen update made on every authenticated request skip the repository
+// once remembered, and that a preference change is seen at once.
+func TestPerRequestLookupsAreCached(t *testing.T) {
+	loadTranslations(t)
+	service := user.NewMemoryService()
+	users := &countingUsers{UserRepository: service.Users}
+	sessions := &countingSessions{SessionRepository: service.Sessions}
+	service.Users, service.Sessions = users, sessions
+	ctx := context.Background()
+
+	assert.Equal(t, "", service.PreferredLocale(ctx, 1))
+	assert.Equal(t, "", service.PreferredLocale(ctx, 1))
+	assert.Equal(t, 1, users.reads)
+
+	prefs := user.DefaultPreferences()
+	prefs.Locale = "ca"
+	_, err := service.SetPreferences(ctx, 1, prefs)
+	assert.NoError(t, err)
+	assert.Equal(t, "ca", service.PreferredLocale(ctx, 1))
+	assert.Equal(t, 1, users.reads)
+
+	service.Touch(ctx, "session-1", "127.0.0.1")
+	service.Touch(ctx, "session-1", "127.0.0.1")
+	service.Touch(ctx, "session-2", "127.0.0.1")
+	assert.Equal(t, 2, sessions.touches)
+}
@@ -6,88 +6,61 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"task-manager/backend-go/db"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/passwordpolicy"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
 	"task-manager/backend-go/models"
-	"unicode"
 )
 
-// isValidPassword checks password strength according to security rules:
-// minimum 8 characters, at least one uppercase, one lowercase,
-// one digit, and one special character.
-func isValidPassword(password string) bool {
-	if len(password) < 8 {
-		return false
-	}
-
-	var hasUpper, hasLower, hasNumber, hasSpecial bool
-
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsDigit(char):
-			hasNumber = true
-		case unicode.IsPunct(char), unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-
-	return hasUpper && hasLower && hasNumber && hasSpecial
-}
-
-/* RegisterHandler processes user registration HTTP requests.
- Validates method, parses input, checks password strength,
- registers the user, and returns appropriate responses.
+/* Register processes user registration HTTP requests.
+ Validates method, parses input, checks the password against PasswordPolicy,
+ registers the user, sends the email verification link,
+ and returns appropriate responses.
 */
-func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("1 register")
 	if r.Method != http.MethodPost {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("method_not_allowed"),
-		})
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
 		return
 	}
 
 	var req models.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("invalid_request_data"),
-		})
+	if !validate.Decode(w, r, &req) {
 		return
 	}
 
-	if !isValidPassword(req.Password) {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("password_not_secure"),
-		})
+	if err := PasswordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		if invalid, ok := err.(*passwordpolicy.ValidationError); ok {
+			respondWithPasswordError(w, r, "password", invalid)
+			return
+		}
+		log.Println("Password policy error:", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.RegistrationFailed)
 		return
 	}
 
-	service := NewService(db.DB)
-	if err := service.RegisterUser(context.Background(), &req); err != nil {
+	userID, err := h.Service.RegisterUser(context.Background(), &req)
+	if err != nil {
 		log.Println("User registration error:", err)
-		w.Header().Set("Content-Type", "application/json")
 
-		if err.Error() == "user_or_email_exists" {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": i18n.T("user_already_exists"),
-			})
+		if err == ErrUserExists {
+			problem.Write(w, r, http.StatusConflict, problem.UserExists)
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("register_failed"),
-		})
+		problem.Write(w, r, http.StatusInternalServerError, problem.RegistrationFailed)
 		return
 	}
+
+	// The account exists now; a failed verification email can be resent later
+	if err := h.Service.SendVerificationEmail(context.Background(), userID); err != nil {
+		log.Println("Verification email error:", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": i18n.Translate(r.Context(), "user_registered"),
+	})
 }
@@ -0,0 +1,994 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"task-manager/backend-go/db"
+	"task-manager/backend-go/models"
+)
+
+// ErrUserExists is returned when the username or email of a new account is taken.
+var ErrUserExists = errors.New("user_or_email_exists")
+
+// UserRepository stores accounts and their preferences. The SQL implementation
+// backs the server; the in-memory one lets tests run the HTTP API without a
+// database.
+type UserRepository interface {
+	// Create stores a new account and returns its ID, or ErrUserExists.
+	Create(ctx context.Context, req *models.RegisterRequest, passwordHash []byte) (int, error)
+	// Summary returns the profile of the user, sql.ErrNoRows when there is none.
+	Summary(ctx context.Context, userID int) (models.UserSummary, error)
+	UpdateProfile(ctx context.Context, userID int, name, surname string) error
+	// FindByEmail returns the ID of the account with the email, sql.ErrNoRows
+	// when there is none.
+	FindByEmail(ctx context.Context, email string) (int, error)
+	// FindByUsername returns the ID of the account with the username, compared
+	// case-insensitively, sql.ErrNoRows when there is none.
+	FindByUsername(ctx context.Context, username string) (int, error)
+	// Preferences returns the stored preferences, sql.ErrNoRows when never set.
+	Preferences(ctx context.Context, userID int) (models.UserPreferences, error)
+	SavePreferences(ctx context.Context, userID int, prefs models.UserPreferences) error
+	// Credentials returns what a login checks for the account with the
+	// username, sql.ErrNoRows when there is none.
+	Credentials(ctx context.Context, username string) (Credentials, error)
+	// PasswordHash returns the password hash of the account, nil when it has
+	// no password, or sql.ErrNoRows.
+	PasswordHash(ctx context.Context, userID int) ([]byte, error)
+	// SetPassword replaces the password hash and spends the outstanding
+	// password resets of the account, in one transaction.
+	SetPassword(ctx context.Context, userID int, passwordHash []byte, at time.Time) error
+	// EmailTaken reports whether an account other than exceptID uses the email.
+	EmailTaken(ctx context.Context, email string, exceptID int) (bool, error)
+	// ChangeEmail switches the account from oldEmail to newEmail, verified at
+	// the given time. It reports false when the account no longer has oldEmail.
+	ChangeEmail(ctx context.Context, userID int, oldEmail, newEmail string, at time.Time) (bool, error)
+	// VerifyEmail marks the email of the account verified at the given time.
+	// It reports false when the account has another email or already verified it.
+	VerifyEmail(ctx context.Context, userID int, email string, at time.Time) (bool, error)
+	// Verification returns the state of the email verification of the account.
+	Verification(ctx context.Context, userID int) (Verification, error)
+	// MarkVerificationSent records when a verification email was sent and
+	// queues it, in the same transaction.
+	MarkVerificationSent(ctx context.Context, userID int, sentAt time.Time, email Email) error
+	// QueueEmails queues the emails, all of them or none.
+	QueueEmails(ctx context.Context, emails ...Email) error
+	// ScheduleDeletion marks the account for deletion at the given time and
+	// revokes its personal access tokens.
+	ScheduleDeletion(ctx context.Context, userID int, at time.Time) error
+	// CancelDeletion clears the pending deletion of the account, if any.
+	CancelDeletion(ctx context.Context, userID int) error
+	// DueDeletions returns the accounts scheduled for deletion at or before now.
+	DueDeletions(ctx context.Context, now time.Time) ([]int, error)
+	// Delete removes the account with the rows it owns and the emails queued
+	// for its address. Its lockouts are kept, without the link to the account.
+	Delete(ctx context.Context, userID int) error
+}
+
+// Credentials are what LoginUser checks before signing a user in.
+type Credentials struct {
+	UserID int
+	// PasswordHash is nil for accounts provisioned through OIDC.
+	PasswordHash  []byte
+	MFAEnabled    bool
+	EmailVerified bool
+}
+
+// Verification is the email verification state of an account. SentAt is zero
+// when no verification email was sent yet.
+type Verification struct {
+	Email    string
+	Verified bool
+	SentAt   time.Time
+}
+
+// SQLUserRepository stores accounts in the users and user_preferences tables.
+type SQLUserRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLUserRepository creates a repository over db.
+func NewSQLUserRepository(db *sql.DB) *SQLUserRepository {
+	return &SQLUserRepository{DB: db}
+}
+
+func (r *SQLUserRepository) Create(ctx context.Context, req *models.RegisterRequest, passwordHash []byte) (int, error) {
+	var exists bool
+	err := r.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE username = ? OR email = ?)", req.Username, req.Email).
+		Scan(&exists)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, ErrUserExists
+	}
+
+	id, err := db.DialectOf(r.DB).Insert(ctx, r.DB,
+		"INSERT INTO users (name, surname, username, email, password) VALUES (?, ?, ?, ?, ?)",
+		req.Name, req.Surname, req.Username, req.Email, passwordHash,
+	)
+	return int(id), err
+}
+
+func (r *SQLUserRepository) Summary(ctx context.Context, userID int) (models.UserSummary, error) {
+	var user models.UserSummary
+	var emailVerifiedAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx, "SELECT username, email, name, surname, created_at, email_verified_at FROM users WHERE id = ?", userID).
+		Scan(&user.Username, &user.Email, &user.Name, &user.Surname, &user.Created, &emailVerifiedAt)
+	user.EmailVerified = emailVerifiedAt.Valid
+	return user, err
+}
+
+func (r *SQLUserRepository) UpdateProfile(ctx context.Context, userID int, name, surname string) error {
+	_, err := r.DB.ExecContext(ctx, "UPDATE users SET name = ?, surname = ? WHERE id = ?", name, surname, userID)
+	return err
+}
+
+func (r *SQLUserRepository) FindByEmail(ctx context.Context, email string) (int, error) {
+	var id int
+	err := r.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE email = ?", email).Scan(&id)
+	return id, err
+}
+
+func (r *SQLUserRepository) FindByUsername(ctx context.Context, username string) (int, error) {
+	var id int
+	err := r.DB.QueryRowContext(ctx, "SELECT id FROM users WHERE LOWER(username) = ?", strings.ToLower(username)).Scan(&id)
+	return id, err
+}
+
+func (r *SQLUserRepository) Preferences(ctx context.Context, userID int) (models.UserPreferences, error) {
+	var prefs models.UserPreferences
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT locale, time_zone, week_start, date_format, task_sort FROM user_preferences WHERE user_id = ?", userID,
+	).Scan(&prefs.Locale, &prefs.TimeZone, &prefs.WeekStart, &prefs.DateFormat, &prefs.TaskSort)
+	return prefs, err
+}
+
+func (r *SQLUserRepository) SavePreferences(ctx context.Context, userID int, prefs models.UserPreferences) error {
+	columns := []string{"locale", "time_zone", "week_start", "date_format", "task_sort"}
+	query := db.DialectOf(r.DB).Upsert("user_preferences", []string{"user_id"}, append([]string{"user_id"}, columns...), columns)
+	_, err := r.DB.ExecContext(ctx, query, userID, prefs.Locale, prefs.TimeZone, prefs.WeekStart, prefs.DateFormat, prefs.TaskSort)
+	return err
+}
+
+func (r *SQLUserRepository) Credentials(ctx context.Context, username string) (Credentials, error) {
+	var creds Credentials
+	var mfaEnabledAt, emailVerifiedAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx, "SELECT id, password, totp_enabled_at, email_verified_at FROM users WHERE username = ?", username).
+		Scan(&creds.UserID, &creds.PasswordHash, &mfaEnabledAt, &emailVerifiedAt)
+	creds.MFAEnabled = mfaEnabledAt.Valid
+	creds.EmailVerified = emailVerifiedAt.Valid
+	return creds, err
+}
+
+func (r *SQLUserRepository) PasswordHash(ctx context.Context, userID int) ([]byte, error) {
+	var hash []byte
+	err := r.DB.QueryRowContext(ctx, "SELECT password FROM users WHERE id = ?", userID).Scan(&hash)
+	return hash, err
+}
+
+func (r *SQLUserRepository) SetPassword(ctx context.Context, userID int, passwordHash []byte, at time.Time) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", passwordHash, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL", at, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLUserRepository) EmailTaken(ctx context.Context, email string, exceptID int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = ? AND id <> ?)", email, exceptID).Scan(&exists)
+	return exists, err
+}
+
+func (r *SQLUserRepository) ChangeEmail(ctx context.Context, userID int, oldEmail, newEmail string, at time.Time) (bool, error) {
+	res, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET email = ?, email_verified_at = ? WHERE id = ? AND email = ?",
+		newEmail, at, userID, oldEmail,
+	)
+	return affected(res, err)
+}
+
+func (r *SQLUserRepository) VerifyEmail(ctx context.Context, userID int, email string, at time.Time) (bool, error) {
+	res, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET email_verified_at = ? WHERE id = ? AND email = ? AND email_verified_at IS NULL",
+		at, userID, email,
+	)
+	return affected(res, err)
+}
+
+func (r *SQLUserRepository) Verification(ctx context.Context, userID int) (Verification, error) {
+	var verification Verification
+	var verifiedAt, sentAt sql.NullTime
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT email, email_verified_at, verification_sent_at FROM users WHERE id = ?", userID,
+	).Scan(&verification.Email, &verifiedAt, &sentAt)
+	verification.Verified = verifiedAt.Valid
+	verification.SentAt = sentAt.Time
+	return verification, err
+}
+
+func (r *SQLUserRepository) MarkVerificationSent(ctx context.Context, userID int, sentAt time.Time, email Email) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET verification_sent_at = ? WHERE id = ?", sentAt, userID); err != nil {
+		return err
+	}
+	if err := email.Queue(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLUserRepository) QueueEmails(ctx context.Context, emails ...Email) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, email := range emails {
+		if err := email.Queue(ctx, tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLUserRepository) ScheduleDeletion(ctx context.Context, userID int, at time.Time) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET deletion_scheduled_at = ? WHERE id = ?", at, userID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE personal_access_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now().UTC(), userID,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLUserRepository) CancelDeletion(ctx context.Context, userID int) error {
+	_, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = NULL WHERE id = ? AND deletion_scheduled_at IS NOT NULL", userID,
+	)
+	return err
+}
+
+func (r *SQLUserRepository) DueDeletions(ctx context.Context, now time.Time) ([]int, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT id FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// Delete removes the rows explicitly, so it does not depend on foreign key support.
+func (r *SQLUserRepository) Delete(ctx context.Context, userID int) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var email string
+	if err := tx.QueryRowContext(ctx, "SELECT email FROM users WHERE id = ?", userID).Scan(&email); err != nil {
+		return err
+	}
+	for _, table := range userTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM email_outbox WHERE recipients = ?", email); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE login_lockouts SET user_id = NULL WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// affected reports whether the statement that returned res changed any row.
+func affected(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// memoryAccount is an account kept by MemoryUserRepository.
+type memoryAccount struct {
+	models.UserSummary
+	createdAt        time.Time
+	password         []byte
+	emailVerifiedAt  *time.Time
+	verificationSent time.Time
+	deletionAt       *time.Time
+	preferences      *models.UserPreferences
+	magicLinkEnabled bool
+	mfaSecret        string
+	mfaEnabledAt     *time.Time
+	mfaLastCounter   *int64
+	// recoveryCodes maps the hashes of the recovery codes to whether they were used.
+	recoveryCodes map[string]bool
+}
+
+// summary returns the profile of the account.
+func (a *memoryAccount) summary() models.UserSummary {
+	summary := a.UserSummary
+	summary.Created = a.createdAt.Format(time.DateTime)
+	summary.EmailVerified = a.emailVerifiedAt != nil
+	return summary
+}
+
+// MemoryUserRepository keeps accounts in process memory, for tests. It is the
+// in-memory database: the other in-memory repositories keep their rows in it,
+// under its lock, so that deleting an account deletes them too. Queued emails
+// are recorded instead of rendered, and read with Emails.
+type MemoryUserRepository struct {
+	mu       sync.Mutex
+	accounts map[int]*memoryAccount
+	nextID   int
+
+	accessTokens []*memoryAccessToken
+	magicLinks   []*memoryLink
+	resets       []*memoryLink
+	lockouts     []*memoryLockout
+	identities   []*memoryIdentity
+	exports      []*memoryExport
+	digests      map[int]*memoryDigest
+	emails       []Email
+}
+
+// NewMemoryUserRepository creates an empty in-memory repository.
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		accounts: make(map[int]*memoryAccount),
+		digests:  make(map[int]*memoryDigest),
+	}
+}
+
+// Emails returns the emails queued so far, oldest first.
+func (r *MemoryUserRepository) Emails() []Email {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Email(nil), r.emails...)
+}
+
+// findByEmail returns the account with the email, if any. The lock must be held.
+func (r *MemoryUserRepository) findByEmail(email string) (int, *memoryAccount) {
+	for id, account := range r.accounts {
+		if account.Email == email {
+			return id, account
+		}
+	}
+	return 0, nil
+}
+
+// findByUsername returns the account with the username, if any. The lock must be held.
+func (r *MemoryUserRepository) findByUsername(username string) (int, *memoryAccount) {
+	for id, account := range r.accounts {
+		if account.Username == username {
+			return id, account
+		}
+	}
+	return 0, nil
+}
+
+// insert stores a new account and returns its ID. The lock must be held.
+func (r *MemoryUserRepository) insert(account *memoryAccount) int {
+	account.createdAt = time.Now().UTC()
+	account.recoveryCodes = make(map[string]bool)
+	r.nextID++
+	r.accounts[r.nextID] = account
+	return r.nextID
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, req *models.RegisterRequest, passwordHash []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, account := range r.accounts {
+		if account.Username == req.Username || account.Email == req.Email {
+			return 0, ErrUserExists
+		}
+	}
+	return r.insert(&memoryAccount{
+		UserSummary: models.UserSummary{Username: req.Username, Email: req.Email, Name: req.Name, Surname: req.Surname},
+		password:    passwordHash,
+	}), nil
+}
+
+func (r *MemoryUserRepository) Summary(ctx context.Context, userID int) (models.UserSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[userID]
+	if !ok {
+		return models.UserSummary{}, sql.ErrNoRows
+	}
+	return account.summary(), nil
+}
+
+func (r *MemoryUserRepository) UpdateProfile(ctx context.Context, userID int, name, surname string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if account, ok := r.accounts[userID]; ok {
+		account.Name, account.Surname = name, surname
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, account := r.findByEmail(email)
+	if account == nil {
+		return 0, sql.ErrNoRows
+	}
+	return id, nil
+}
+
+func (r *MemoryUserRepository) FindByUsername(ctx context.Context, username string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, account := range r.accounts {
+		if strings.EqualFold(account.Username, username) {
+			return id, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (r *MemoryUserRepository) Preferences(ctx context.Context, userID int) (models.UserPreferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[userID]
+	if !ok || account.preferences == nil {
+		return models.UserPreferences{}, sql.ErrNoRows
+	}
+	return *account.preferences, nil
+}
+
+func (r *MemoryUserRepository) SavePreferences(ctx context.Context, userID int, prefs models.UserPreferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if account, ok := r.accounts[userID]; ok {
+		account.preferences = &prefs
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) Credentials(ctx context.Context, username string) (Credentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, account := r.findByUsername(username)
+	if account == nil {
+		return Credentials{}, sql.ErrNoRows
+	}
+	return Credentials{
+		UserID:        id,
+		PasswordHash:  account.password,
+		MFAEnabled:    account.mfaEnabledAt != nil,
+		EmailVerified: account.emailVerifiedAt != nil,
+	}, nil
+}
+
+func (r *MemoryUserRepository) PasswordHash(ctx context.Context, userID int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[userID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return account.password, nil
+}
+
+func (r *MemoryUserRepository) SetPassword(ctx context.Context, userID int, passwordHash []byte, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if account, ok := r.accounts[userID]; ok {
+		account.password = passwordHash
+		spendLinks(r.resets, userID, at)
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) EmailTaken(ctx context.Context, email string, exceptID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, account := r.findByEmail(email)
+	return account != nil && id != exceptID, nil
+}
+
+func (r *MemoryUserRepository) ChangeEmail(ctx context.Context, userID int, oldEmail, newEmail string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[userID]
+	if !ok || account.Email != oldEmail {
+		return false, nil
+	}
+	account.Email = newEmail
+	account.emailVerifiedAt = &at
+	return true, nil
+}
+
+func (r *MemoryUserRepository) VerifyEmail(ctx context.Context, userID int, email string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[userID]
+	if !ok || account.Email != email || account.emailVerifiedAt != nil {
+		return false, nil
+	}
+	account.emailVerifiedAt = &at
+	return true, nil
+}
+
+func (r *MemoryUserRepository) Verification(ctx context.Context, userID int) (Verification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[userID]
+	if !ok {
+		return Verification{}, sql.ErrNoRows
+	}
+	return Verification{Email: account.Email, Verified: account.emailVerifiedAt != nil, SentAt: account.verificationSent}, nil
+}
+
+func (r *MemoryUserRepository) MarkVerificationSent(ctx context.Context, userID int, sentAt time.Time, email Email) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if account, ok := r.accounts[userID]; ok {
+		account.verificationSent = sentAt
+	}
+	r.emails = append(r.emails, email)
+	return nil
+}
+
+func (r *MemoryUserRepository) QueueEmails(ctx context.Context, emails ...Email) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.emails = append(r.emails, emails...)
+	return nil
+}
+
+func (r *MemoryUserRepository) ScheduleDeletion(ctx context.Context, userID int, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if account, ok := r.accounts[userID]; ok {
+		account.deletionAt = &at
+	}
+	now := time.Now().UTC()
+	for _, token := range r.accessTokens {
+		if token.userID == userID && token.revokedAt == nil {
+			token.revokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) CancelDeletion(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if account, ok := r.accounts[userID]; ok {
+		account.deletionAt = nil
+	}
+	return nil
+}
+
+func (r *MemoryUserRepository) DueDeletions(ctx context.Context, now time.Time) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var userIDs []int
+	for id, account := range r.accounts {
+		if account.deletionAt != nil && !account.deletionAt.After(now) {
+			userIDs = append(userIDs, id)
+		}
+	}
+	sort.Ints(userIDs)
+	return userIDs, nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.accounts, userID)
+	delete(r.digests, userID)
+	r.accessTokens = deleteRows(r.accessTokens, func(t *memoryAccessToken) bool { return t.userID == userID })
+	r.magicLinks = deleteRows(r.magicLinks, func(l *memoryLink) bool { return l.userID == userID })
+	r.resets = deleteRows(r.resets, func(l *memoryLink) bool { return l.userID == userID })
+	r.identities = deleteRows(r.identities, func(i *memoryIdentity) bool { return i.userID == userID })
+	r.exports = deleteRows(r.exports, func(e *memoryExport) bool { return e.userID == userID })
+	r.emails = deleteRows(r.emails, func(e Email) bool { return e.To == account.Email })
+	for _, lockout := range r.lockouts {
+		if lockout.UserID == userID {
+			lockout.UserID = 0
+		}
+	}
+	return nil
+}
+
+// deleteRows returns rows without the ones matching del.
+func deleteRows[T any](rows []T, del func(T) bool) []T {
+	kept := rows[:0]
+	for _, row := range rows {
+		if !del(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// SessionRepository stores the sessions issued at login, whose IDs are the
+// IDs of their JWTs.
+type SessionRepository interface {
+	// Create records a new session of the user.
+	Create(ctx context.Context, userID int, session models.Session) error
+	// Get returns a session of the user that is not revoked, or ErrSessionNotFound.
+	Get(ctx context.Context, userID int, sessionID string) (models.Session, error)
+	// List returns the sessions of the user that are not revoked, newest first.
+	List(ctx context.Context, userID int) ([]models.Session, error)
+	// Revoke revokes an active session of the user at the given time and
+	// returns its expiry, or ErrSessionNotFound.
+	Revoke(ctx context.Context, userID int, sessionID string, at time.Time) (time.Time, error)
+	// RevokeAll revokes the active sessions of the user except exceptID and
+	// returns the expiry of each revoked session by ID.
+	RevokeAll(ctx context.Context, userID int, exceptID string, at time.Time) (map[string]time.Time, error)
+	// History returns every session of the user, revoked and expired ones
+	// included, oldest first.
+	History(ctx context.Context, userID int) ([]SessionRecord, error)
+	// Active reports whether the session exists, is not revoked and has not
+	// expired at now.
+	Active(ctx context.Context, sessionID string, now time.Time) (bool, error)
+	// Touch sets the last-seen time and IP of a session unless it was last
+	// seen after since.
+	Touch(ctx context.Context, sessionID, ip string, now, since time.Time) error
+}
+
+// SessionRecord is a session with the time it was revoked, nil while active.
+type SessionRecord struct {
+	models.Session
+	RevokedAt *time.Time
+}
+
+// SQLSessionRepository stores sessions in the sessions table.
+type SQLSessionRepository struct {
+	DB *sql.DB
+}
+
+// NewSQLSessionRepository creates a repository over db.
+func NewSQLSessionRepository(db *sql.DB) *SQLSessionRepository {
+	return &SQLSessionRepository{DB: db}
+}
+
+func (r *SQLSessionRepository) Create(ctx context.Context, userID int, session models.Session) error {
+	query := `INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.DB.ExecContext(ctx, query, session.ID, userID, session.Device, session.IP, session.CreatedAt, session.LastSeenAt, session.ExpiresAt)
+	return err
+}
+
+func (r *SQLSessionRepository) Get(ctx context.Context, userID int, sessionID string) (models.Session, error) {
+	sessions, err := r.query(ctx, "WHERE id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID)
+	if err != nil {
+		return models.Session{}, err
+	}
+	if len(sessions) == 0 {
+		return models.Session{}, ErrSessionNotFound
+	}
+	return sessions[0], nil
+}
+
+func (r *SQLSessionRepository) List(ctx context.Context, userID int) ([]models.Session, error) {
+	return r.query(ctx, "WHERE user_id = ? AND revoked_at IS NULL ORDER BY created_at DESC", userID)
+}
+
+// query returns the sessions matching the WHERE clause and its arguments.
+func (r *SQLSessionRepository) query(ctx context.Context, where string, args ...any) ([]models.Session, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT id, user_agent, ip, created_at, last_seen_at, expires_at FROM sessions "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		var device, ip sql.NullString
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&session.ID, &device, &ip, &session.CreatedAt, &lastSeen, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		session.Device = device.String
+		session.IP = ip.String
+		if lastSeen.Valid {
+			session.LastSeenAt = &lastSeen.Time
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *SQLSessionRepository) Revoke(ctx context.Context, userID int, sessionID string, at time.Time) (time.Time, error) {
+	var expiresAt time.Time
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT expires_at FROM sessions WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		sessionID, userID,
+	).Scan(&expiresAt)
+	if err == sql.ErrNoRows {
+		return expiresAt, ErrSessionNotFound
+	} else if err != nil {
+		return expiresAt, err
+	}
+
+	_, err = r.DB.ExecContext(ctx, "UPDATE sessions SET revoked_at = ? WHERE id = ?", at, sessionID)
+	return expiresAt, err
+}
+
+// RevokeAll reads and revokes the sessions in one transaction, so the expiries
+// returned are exactly those of the sessions it revoked.
+func (r *SQLSessionRepository) RevokeAll(ctx context.Context, userID int, exceptID string, at time.Time) (map[string]time.Time, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, expires_at FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND id <> ?",
+		userID, exceptID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := scanExpiries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL AND id <> ?",
+		at, userID, exceptID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return revoked, tx.Commit()
+}
+
+func (r *SQLSessionRepository) History(ctx context.Context, userID int) ([]SessionRecord, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at FROM sessions WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []SessionRecord{}
+	for rows.Next() {
+		var session SessionRecord
+		var device, ip sql.NullString
+		var lastSeen, revokedAt sql.NullTime
+		if err := rows.Scan(&session.ID, &device, &ip, &session.CreatedAt, &lastSeen, &session.ExpiresAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		session.Device = device.String
+		session.IP = ip.String
+		if lastSeen.Valid {
+			session.LastSeenAt = &lastSeen.Time
+		}
+		if revokedAt.Valid {
+			session.RevokedAt = &revokedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *SQLSessionRepository) Active(ctx context.Context, sessionID string, now time.Time) (bool, error) {
+	var active int
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT 1 FROM sessions WHERE id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, now,
+	).Scan(&active)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *SQLSessionRepository) Touch(ctx context.Context, sessionID, ip string, now, since time.Time) error {
+	_, err := r.DB.ExecContext(ctx,
+		"UPDATE sessions SET last_seen_at = ?, ip = ? WHERE id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)",
+		now, ip, sessionID, since,
+	)
+	return err
+}
+
+// scanExpiries reads rows of session IDs and expiries, then closes them.
+func scanExpiries(rows *sql.Rows) (map[string]time.Time, error) {
+	defer rows.Close()
+
+	expiries := map[string]time.Time{}
+	for rows.Next() {
+		var id string
+		var expiresAt time.Time
+		if err := rows.Scan(&id, &expiresAt); err != nil {
+			return nil, err
+		}
+		expiries[id] = expiresAt
+	}
+	return expiries, rows.Err()
+}
+
+// memorySession is a session kept by MemorySessionRepository.
+type memorySession struct {
+	SessionRecord
+	userID int
+}
+
+// MemorySessionRepository keeps sessions in process memory, for tests.
+type MemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]*memorySession
+}
+
+// NewMemorySessionRepository creates an empty in-memory repository.
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{sessions: make(map[string]*memorySession)}
+}
+
+func (r *MemorySessionRepository) Create(ctx context.Context, userID int, session models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.ID] = &memorySession{SessionRecord: SessionRecord{Session: session}, userID: userID}
+	return nil
+}
+
+func (r *MemorySessionRepository) Get(ctx context.Context, userID int, sessionID string) (models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok || session.userID != userID || session.RevokedAt != nil {
+		return models.Session{}, ErrSessionNotFound
+	}
+	return session.Session, nil
+}
+
+func (r *MemorySessionRepository) List(ctx context.Context, userID int) ([]models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := []models.Session{}
+	for _, session := range r.sessions {
+		if session.userID == userID && session.RevokedAt == nil {
+			sessions = append(sessions, session.Session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Revoke(ctx context.Context, userID int, sessionID string, at time.Time) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok || session.userID != userID || session.RevokedAt != nil {
+		return time.Time{}, ErrSessionNotFound
+	}
+	session.RevokedAt = &at
+	return session.ExpiresAt, nil
+}
+
+func (r *MemorySessionRepository) RevokeAll(ctx context.Context, userID int, exceptID string, at time.Time) (map[string]time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	revoked := map[string]time.Time{}
+	for id, session := range r.sessions {
+		if session.userID == userID && session.RevokedAt == nil && id != exceptID {
+			session.RevokedAt = &at
+			revoked[id] = session.ExpiresAt
+		}
+	}
+	return revoked, nil
+}
+
+func (r *MemorySessionRepository) History(ctx context.Context, userID int) ([]SessionRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sessions := []SessionRecord{}
+	for _, session := range r.sessions {
+		if session.userID == userID {
+			sessions = append(sessions, session.SessionRecord)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+func (r *MemorySessionRepository) Active(ctx context.Context, sessionID string, now time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	return ok && session.RevokedAt == nil && session.ExpiresAt.After(now), nil
+}
+
+func (r *MemorySessionRepository) Touch(ctx context.Context, sessionID, ip string, now, since time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if ok && (session.LastSeenAt == nil || session.LastSeenAt.Before(since)) {
+		session.LastSeenAt = &now
+		session.IP = ip
+	}
+	return nil
+}
@@ -1,10 +1,16 @@
 package user
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"task-manager/backend-go/db"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/passwordpolicy"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -12,79 +18,74 @@ import (
 
 // ResetPasswordRequest represents the expected JSON payload for password reset requests.
 type ResetPasswordRequest struct {
-	Token    string `json:"token"`
-	Password string `json:"password"`
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
-// ResetPasswordHandler handles HTTP requests to reset a user's password.
-// It validates method, input data, token validity, password strength,
-// hashes new password and updates it in the database.
-func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": i18n.T("method_not_allowed")})
-		return
-	}
+// ErrInvalidResetToken is returned for unknown, expired or already used reset tokens.
+var ErrInvalidResetToken = errors.New("invalid_reset_token")
 
-	var req ResetPasswordRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": i18n.T("invalid_request_data")})
-		return
-	}
+// ResetPassword sets a new password from a reset token. The password is checked
+// against PasswordPolicy before the token is spent, so a rejected password does
+// not burn the link. Using a token invalidates every other outstanding reset of
+// the account and revokes its sessions.
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) error {
+	tokenHash := hashToken(token)
+	now := time.Now().UTC()
 
-	if req.Token == "" || req.Password == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": i18n.T("missing_token_or_password")})
-		return
+	userID, err := s.PasswordResets.Find(ctx, tokenHash, now)
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	} else if err != nil {
+		return err
+	}
+	user, err := s.Users.Summary(ctx, userID)
+	if err != nil {
+		return err
 	}
 
-	// Validate token and expiration time from DB
-	var userID int
-	var expirationTime time.Time
-	err := db.DB.QueryRow(
-		"SELECT id, password_reset_expiration FROM users WHERE password_reset_token = ?", req.Token,
-	).Scan(&userID, &expirationTime)
+	if err := PasswordPolicy.Validate(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+	hashedPwd, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": i18n.T("invalid_or_expired_token")})
-		return
+		return err
 	}
 
-	if time.Now().After(expirationTime) {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": i18n.T("token_expired")})
-		return
+	if err := s.PasswordResets.Redeem(ctx, tokenHash, userID, hashedPwd, now); err != nil {
+		return err
 	}
 
-	// Validate new password strength using shared utility (assumed implemented elsewhere)
-	if !isValidPassword(req.Password) {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": i18n.T("password_requirements"),
-		})
+	// Invalidate every session issued with the old password
+	return s.RevokeAllSessions(ctx, userID, "")
+}
+
+// ResetPassword handles HTTP requests to reset a user's password.
+// It validates method and input data, then sets the new password through
+// ResetPassword.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
 		return
 	}
 
-	// Hash the new password securely
-	hashedPwd, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": i18n.T("password_hash_error")})
+	var req ResetPasswordRequest
+	if !validate.Decode(w, r, &req) {
 		return
 	}
 
-	// Update user password and clear reset token/expiration in DB
-	_, err = db.DB.Exec(
-		"UPDATE users SET password = ?, password_reset_token = NULL, password_reset_expiration = NULL WHERE id = ?",
-		hashedPwd, userID,
-	)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": i18n.T("password_update_error")})
+	err := h.Service.ResetPassword(r.Context(), req.Token, req.Password)
+	if invalid, ok := err.(*passwordpolicy.ValidationError); ok {
+		respondWithPasswordError(w, r, "password", invalid)
 		return
 	}
-
-	// Success response
-	json.NewEncoder(w).Encode(map[string]string{"message": i18n.T("password_updated_successfully")})
+	switch err {
+	case nil:
+		json.NewEncoder(w).Encode(map[string]string{"message": i18n.Translate(r.Context(), "password_updated_successfully")})
+	case ErrInvalidResetToken:
+		problem.Write(w, r, http.StatusUnauthorized, problem.InvalidToken)
+	default:
+		log.Printf("Error resetting password: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.PasswordUpdateFailed)
+	}
 }
@@ -0,0 +1,121 @@
+package user_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/mail"
+	"task-manager/backend-go/internal/outbox"
+	"task-manager/backend-go/internal/passwordpolicy"
+	"task-manager/backend-go/internal/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// insertResetToken stores a reset link for token, as RequestPasswordReset would.
+func insertResetToken(t *testing.T, testDB *sql.DB, userID int, token string) {
+	sum := sha256.Sum256([]byte(token))
+	now := time.Now().UTC()
+	_, err := testDB.Exec(
+		"INSERT INTO password_resets (user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		userID, hex.EncodeToString(sum[:]), now, now.Add(time.Minute),
+	)
+	assert.NoError(t, err)
+}
+
+// TestForgotPasswordHandler_SameResponse verifies that unknown emails cannot be told apart
+func TestForgotPasswordHandler_SameResponse(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	handler := user.NewHandler(service)
+	userID, _ := loginTestUser(t, service, testDB)
+
+	mailer := mail.NewMemoryMailer()
+	user.Mailer, user.MailFrom, user.PublicAPIURL = mailer, "noreply@example.com", "https://api.example.com"
+	defer func() { user.Mailer, user.PublicAPIURL = nil, "" }()
+
+	post := func(email string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/forgot-password", strings.NewReader(`{"email":"`+email+`"}`))
+		rec := httptest.NewRecorder()
+		handler.ForgotPassword(rec, req)
+		return rec
+	}
+
+	known := post(testEmail)
+	unknown := post("nobody@example.com")
+	assert.Equal(t, http.StatusOK, known.Code)
+	assert.Equal(t, known.Code, unknown.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+	// Only the hash of the token is stored, for the known account only, once
+	// the request is processed in the background
+	assert.Eventually(t, func() bool {
+		var count int
+		err := testDB.QueryRow("SELECT COUNT(*) FROM password_resets WHERE user_id = ?", userID).Scan(&count)
+		return err == nil && count == 1
+	}, time.Second, 10*time.Millisecond)
+	var count int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM password_resets").Scan(&count))
+	assert.Equal(t, 1, count)
+	var stored string
+	assert.NoError(t, testDB.QueryRow("SELECT token_hash FROM password_resets").Scan(&stored))
+
+	// The emailed link carries the token whose hash was stored
+	sentCount, err := outbox.NewWorker(testDB, mailer).RunOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sentCount)
+	if !assert.Len(t, mailer.Messages(), 1) {
+		return
+	}
+	sent := mailer.Messages()[0]
+	assert.Equal(t, []string{testEmail}, sent.To)
+	_, token, found := strings.Cut(sent.Text, "/resetPasswordRequest?token=")
+	assert.True(t, found)
+	token, _, _ = strings.Cut(token, "\n")
+	sum := sha256.Sum256([]byte(token))
+	assert.Equal(t, hex.EncodeToString(sum[:]), stored)
+	assert.Contains(t, sent.HTML, `<a href="https://api.example.com/resetPasswordRequest?token=`+token+`"`)
+}
+
+// TestResetPassword verifies that reset tokens are single use and spend the other outstanding ones
+func TestResetPassword(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	userID, _ := loginTestUser(t, service, testDB)
+	ctx := context.Background()
+
+	// Outstanding requests are capped
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, service.RequestPasswordReset(ctx, testEmail, "203.0.113.1"))
+	}
+	var count int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM password_resets WHERE user_id = ?", userID).Scan(&count))
+	assert.Equal(t, 3, count)
+	assert.NoError(t, service.RequestPasswordReset(ctx, "nobody@example.com", "203.0.113.1"))
+
+	insertResetToken(t, testDB, userID, "first-token")
+	insertResetToken(t, testDB, userID, "second-token")
+
+	// A password rejected by the policy does not spend the link
+	var invalid *passwordpolicy.ValidationError
+	assert.ErrorAs(t, service.ResetPassword(ctx, "first-token", "weak"), &invalid)
+
+	newPassword := "N3w-Passw0rd!"
+	assert.NoError(t, service.ResetPassword(ctx, "first-token", newPassword))
+	assert.Equal(t, user.ErrInvalidResetToken, service.ResetPassword(ctx, "first-token", newPassword))
+	assert.Equal(t, user.ErrInvalidResetToken, service.ResetPassword(ctx, "second-token", newPassword))
+	assert.Equal(t, user.ErrInvalidResetToken, service.ResetPassword(ctx, "unknown-token", newPassword))
+
+	var active int
+	assert.NoError(t, testDB.QueryRow("SELECT COUNT(*) FROM sessions WHERE user_id = ? AND revoked_at IS NULL", userID).Scan(&active))
+	assert.Equal(t, 0, active)
+}
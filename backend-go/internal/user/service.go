@@ -4,71 +4,131 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"task-manager/backend-go/internal/auth"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/totp"
 	"task-manager/backend-go/models"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Service struct {
-	DB *sql.DB
+	// DB is the database of the outbox, read by the admin endpoints.
+	DB   *sql.DB
+	TOTP *totp.Generator
+	// Users stores accounts and preferences, over DB unless replaced.
+	Users UserRepository
+	// Sessions stores the sessions issued at login, over DB unless replaced.
+	Sessions SessionRepository
+	// The other repositories hold the rest of the account data, over DB
+	// unless replaced.
+	MFA            MFARepository
+	AccessTokens   AccessTokenRepository
+	MagicLinks     MagicLinkRepository
+	PasswordResets PasswordResetRepository
+	Lockouts       LockoutRepository
+	Identities     IdentityRepository
+	Exports        ExportRepository
+	Digests        DigestRepository
+
+	// touched holds the sessions whose last-seen time was written recently,
+	// and locales the languages of the users, read by every authenticated request.
+	touched expiringCache[string, struct{}]
+	locales expiringCache[int, string]
 }
 
 func NewService(db *sql.DB) *Service {
-	return &Service{DB: db}
+	return &Service{
+		DB:             db,
+		TOTP:           totp.New(),
+		Users:          NewSQLUserRepository(db),
+		Sessions:       NewSQLSessionRepository(db),
+		MFA:            NewSQLMFARepository(db),
+		AccessTokens:   NewSQLAccessTokenRepository(db),
+		MagicLinks:     NewSQLMagicLinkRepository(db),
+		PasswordResets: NewSQLPasswordResetRepository(db),
+		Lockouts:       NewSQLLockoutRepository(db),
+		Identities:     NewSQLIdentityRepository(db),
+		Exports:        NewSQLExportRepository(db),
+		Digests:        NewSQLDigestRepository(db),
+	}
 }
 
-// RegisterUser handles user registration logic.
-// It hashes the password and inserts the user into the database.
-func (s *Service) RegisterUser(ctx context.Context, req *models.RegisterRequest) error {
-    // Primero verifica si username o email ya existen:
-    var exists bool
-    queryCheck := `SELECT EXISTS(SELECT 1 FROM users WHERE username = ? OR email = ?)`
-    err := s.DB.QueryRowContext(ctx, queryCheck, req.Username, req.Email).Scan(&exists)
-    if err != nil {
-        return err
-    }
-    if exists {
-        return errors.New("user_or_email_exists")
-    }
+// NewMemoryService creates a service over in-memory repositories, for tests
+// that run without a database. Its Users is a *MemoryUserRepository, which
+// records the emails the service queues.
+func NewMemoryService() *Service {
+	users := NewMemoryUserRepository()
+	return &Service{
+		TOTP:           totp.New(),
+		Users:          users,
+		Sessions:       NewMemorySessionRepository(),
+		MFA:            NewMemoryMFARepository(users),
+		AccessTokens:   NewMemoryAccessTokenRepository(users),
+		MagicLinks:     NewMemoryMagicLinkRepository(users),
+		PasswordResets: NewMemoryPasswordResetRepository(users),
+		Lockouts:       NewMemoryLockoutRepository(users),
+		Identities:     NewMemoryIdentityRepository(users),
+		Exports:        NewMemoryExportRepository(users),
+		Digests:        NewMemoryDigestRepository(users),
+	}
+}
 
+// RegisterUser handles user registration logic.
+// It hashes the password and stores the user, returning the ID of the new
+// account or ErrUserExists when the username or email is taken.
+func (s *Service) RegisterUser(ctx context.Context, req *models.RegisterRequest) (int, error) {
     hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
     if err != nil {
-        return err
-    }
-
-    query := `INSERT INTO users (name, surname, username, email, password) VALUES (?, ?, ?, ?, ?)`
-    _, err = s.DB.ExecContext(ctx, query, req.Name, req.Surname, req.Username, req.Email, hashedPassword)
-    if err != nil {
-        return err
+        return 0, err
     }
 
-    return nil
+    return s.Users.Create(ctx, req, hashedPassword)
 }
 
+// ErrInvalidCredentials is wrapped by the errors LoginUser returns for an
+// unknown username or a wrong password, the failures LoginGuard counts.
+var ErrInvalidCredentials = errors.New("invalid_credentials")
 
 // LoginUser verifies user credentials and returns a JWT token upon success.
+// The token is recorded as a new session for the client in req.
+// When the account has 2FA enabled it returns a challenge token together with
+// ErrMFARequired instead; the login is completed by VerifyMFA. Unverified
+// accounts get ErrEmailNotVerified when EmailVerificationPolicy blocks them.
 func (s *Service) LoginUser(ctx context.Context, req *models.LoginRequest) (string, error) {
-	var hashedPassword string
-	var userID int
-
-	err := s.DB.QueryRowContext(ctx, "SELECT id, password FROM users WHERE username = ?", req.Username).
-		Scan(&userID, &hashedPassword)
+	creds, err := s.Users.Credentials(ctx, req.Username)
 
 	if err == sql.ErrNoRows {
-		return "", errors.New(i18n.T("user.error.not_found"))
+		return "", fmt.Errorf("%s: %w", i18n.T("user.error.not_found"), ErrInvalidCredentials)
 
 	} else if err != nil {
 		return "", err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
-		return "", errors.New(i18n.T("user.error.incorrect_password"))
+	// Accounts provisioned through OIDC have no password
+	if creds.PasswordHash == nil {
+		return "", fmt.Errorf("%s: %w", i18n.T("user.error.incorrect_password"), ErrInvalidCredentials)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(creds.PasswordHash, []byte(req.Password)); err != nil {
+		return "", fmt.Errorf("%s: %w", i18n.T("user.error.incorrect_password"), ErrInvalidCredentials)
+
+	}
+
+	if !creds.EmailVerified && EmailVerificationPolicy == VerificationBlockLogin {
+		return "", ErrEmailNotVerified
+	}
 
+	if creds.MFAEnabled {
+		challenge, err := auth.GenerateChallengeToken(creds.UserID)
+		if err != nil {
+			return "", err
+		}
+		return challenge, ErrMFARequired
 	}
 
-	tokenString, err := auth.GenerateJWT(userID)
+	tokenString, err := s.createSession(ctx, creds.UserID, req.UserAgent, req.IP)
 	if err != nil {
 		return "", err
 	}
@@ -78,7 +138,10 @@ func (s *Service) LoginUser(ctx context.Context, req *models.LoginRequest) (stri
 
 // UpdateUser updates user's name and surname based on user ID.
 func (s *Service) UpdateUser(userID int, name, surname string) error {
-	query := `UPDATE users SET name = ?, surname = ? WHERE id = ?`
-	_, err := s.DB.Exec(query, name, surname, userID)
-	return err
+	return s.Users.UpdateProfile(context.Background(), userID, name, surname)
+}
+
+// UserSummary returns the profile of the user, sql.ErrNoRows when there is none.
+func (s *Service) UserSummary(ctx context.Context, userID int) (models.UserSummary, error) {
+	return s.Users.Summary(ctx, userID)
 }
@@ -3,13 +3,16 @@ package user_test
 import (
 	"context"
 	"database/sql"
+	"task-manager/backend-go/db/dbtest"
+	"task-manager/backend-go/internal/auth"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/loginguard"
 	"task-manager/backend-go/internal/user"
 	"task-manager/backend-go/models"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
-	_ "modernc.org/sqlite"
 )
 
 const (
@@ -20,25 +23,16 @@ const (
 	testEmail    = "thorbar@example.com"
 )
 
-// setupTestDB creates an in-memory SQLite database with users table for testing.
+// setupTestDB creates a database with the schema of the application for testing,
+// with a fresh LoginGuard without backoff so that wrong passwords do not slow
+// down the next step of the test.
 func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite", ":memory:")
-	assert.NoError(t, err)
-
-	schema := `
-	CREATE TABLE users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		surname TEXT NOT NULL,
-		username TEXT NOT NULL UNIQUE,
-		email TEXT NOT NULL UNIQUE,
-		password TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);`
-	_, err = db.Exec(schema)
-	assert.NoError(t, err)
+	previous := user.LoginGuard
+	user.LoginGuard = loginguard.New(loginguard.NewMemoryStore())
+	user.LoginGuard.Account.BaseDelay = 0
+	t.Cleanup(func() { user.LoginGuard = previous })
 
-	return db
+	return dbtest.Open(t)
 }
 
 // TestRegisterUser verifies user registration stores data correctly.
@@ -56,7 +50,7 @@ func TestRegisterUser(t *testing.T) {
 		Password: testPassword,
 	}
 
-	err := service.RegisterUser(context.Background(), req)
+	_, err := service.RegisterUser(context.Background(), req)
 	assert.NoError(t, err)
 
 	var count int
@@ -73,7 +67,7 @@ func TestLoginUser_Success(t *testing.T) {
 	service := user.NewService(db)
 
 	// Register user first
-	err := service.RegisterUser(context.Background(), &models.RegisterRequest{
+	_, err := service.RegisterUser(context.Background(), &models.RegisterRequest{
 		Name:     testName,
 		Surname:  testSurname,
 		Username: testUsername,
@@ -99,7 +93,7 @@ func TestLoginUser_WrongPassword(t *testing.T) {
 	service := user.NewService(db)
 
 	// Register user
-	err := service.RegisterUser(context.Background(), &models.RegisterRequest{
+	_, err := service.RegisterUser(context.Background(), &models.RegisterRequest{
 		Name:     testName,
 		Surname:  testSurname,
 		Username: testUsername,
@@ -148,12 +142,12 @@ func TestRegisterUser_DuplicateUsername(t *testing.T) {
 		Password: testPassword,
 	}
 
-	err := service.RegisterUser(context.Background(), req)
+	_, err := service.RegisterUser(context.Background(), req)
 	assert.NoError(t, err)
 
 	// Change email, try duplicate username
 	req.Email = "other@example.com"
-	err = service.RegisterUser(context.Background(), req)
+	_, err = service.RegisterUser(context.Background(), req)
 	assert.Error(t, err) // Expected to fail due to duplicate username
 }
 
@@ -172,12 +166,12 @@ func TestRegisterUser_DuplicateEmail(t *testing.T) {
 		Password: testPassword,
 	}
 
-	err := service.RegisterUser(context.Background(), req)
+	_, err := service.RegisterUser(context.Background(), req)
 	assert.NoError(t, err)
 
 	// Change username, try duplicate email
 	req.Username = "loki_dos"
-	err = service.RegisterUser(context.Background(), req)
+	_, err = service.RegisterUser(context.Background(), req)
 	assert.Error(t, err) // Expected to fail due to duplicate email
 }
 
@@ -189,7 +183,7 @@ func TestUpdateUser_Success(t *testing.T) {
 	service := user.NewService(db)
 
 	// Step 1: Register user
-	err := service.RegisterUser(context.Background(), &models.RegisterRequest{
+	_, err := service.RegisterUser(context.Background(), &models.RegisterRequest{
 		Name:     testName,
 		Surname:  testSurname,
 		Username: testUsername,
@@ -220,3 +214,227 @@ func TestUpdateUser_Success(t *testing.T) {
 	assert.Equal(t, newName, updatedName)
 	assert.Equal(t, newSurname, updatedSurname)
 }
+
+// loginTestUser registers the test user and logs in once, returning the user ID and token.
+func loginTestUser(t *testing.T, service *user.Service, db *sql.DB) (int, string) {
+	_, err := service.RegisterUser(context.Background(), &models.RegisterRequest{
+		Name:     testName,
+		Surname:  testSurname,
+		Username: testUsername,
+		Email:    testEmail,
+		Password: testPassword,
+	})
+	assert.NoError(t, err)
+
+	var userID int
+	err = db.QueryRow("SELECT id FROM users WHERE username = ?", testUsername).Scan(&userID)
+	assert.NoError(t, err)
+
+	token, err := service.LoginUser(context.Background(), &models.LoginRequest{
+		Username:  testUsername,
+		Password:  testPassword,
+		UserAgent: "test-agent",
+		IP:        "127.0.0.1",
+	})
+	assert.NoError(t, err)
+
+	return userID, token
+}
+
+// TestLoginUser_RecordsSession verifies that login creates a listable session.
+func TestLoginUser_RecordsSession(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := user.NewService(db)
+	userID, _ := loginTestUser(t, service, db)
+
+	sessions, err := service.ListSessions(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "test-agent", sessions[0].Device)
+	assert.Equal(t, "127.0.0.1", sessions[0].IP)
+}
+
+// TestRevokeSession ensures a revoked session's token is no longer accepted.
+func TestRevokeSession(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := user.NewService(db)
+	userID, token := loginTestUser(t, service, db)
+
+	claims, err := auth.ParseClaims(token)
+	assert.NoError(t, err)
+
+	err = service.RevokeSession(context.Background(), userID, claims.ID)
+	assert.NoError(t, err)
+
+	_, err = auth.ParseToken(token)
+	assert.Error(t, err)
+
+	sessions, err := service.ListSessions(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// Revoking twice or revoking another user's session reports not found
+	assert.ErrorIs(t, service.RevokeSession(context.Background(), userID, claims.ID), user.ErrSessionNotFound)
+}
+
+// TestRevokeAllSessions ensures every token of the user is invalidated.
+func TestRevokeAllSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := user.NewService(db)
+	userID, first := loginTestUser(t, service, db)
+	second, err := service.LoginUser(context.Background(), &models.LoginRequest{
+		Username: testUsername,
+		Password: testPassword,
+	})
+	assert.NoError(t, err)
+
+	err = service.RevokeAllSessions(context.Background(), userID, "")
+	assert.NoError(t, err)
+
+	_, err = auth.ParseToken(first)
+	assert.Error(t, err)
+	_, err = auth.ParseToken(second)
+	assert.Error(t, err)
+}
+
+// TestSessionDenylist ensures a session revoked through one service is refused
+// by every replica sharing the database, and that unknown sessions are refused.
+func TestSessionDenylist(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := user.NewService(db)
+	userID, token := loginTestUser(t, service, db)
+	claims, err := auth.ParseClaims(token)
+	assert.NoError(t, err)
+
+	// Another replica has no memory of the revocation
+	replica := user.NewSessionDenylist(user.NewSQLSessionRepository(db))
+	assert.False(t, replica.IsRevoked(claims.ID))
+	assert.True(t, replica.IsRevoked("unknown"))
+
+	assert.NoError(t, service.RevokeSession(context.Background(), userID, claims.ID))
+	assert.True(t, replica.IsRevoked(claims.ID))
+}
+
+// TestMFA_EnrollLoginAndRecovery walks through 2FA enrollment and login using a fixed clock.
+func TestMFA_EnrollLoginAndRecovery(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// No backoff after the replayed code
+	service := user.NewService(db)
+	previous := user.LoginGuard
+	user.LoginGuard = user.NewLoginGuard(loginguard.NewMemoryStore(), service)
+	user.LoginGuard.MFA.BaseDelay = 0
+	defer func() { user.LoginGuard = previous }()
+
+	now := time.Unix(1700000000, 0)
+	service.TOTP.Now = func() time.Time { return now }
+
+	userID, _ := loginTestUser(t, service, db)
+
+	secret, uri, err := service.EnrollMFA(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Contains(t, uri, "otpauth://totp/")
+
+	code, err := service.TOTP.Code(secret, now)
+	assert.NoError(t, err)
+	recoveryCodes, err := service.ConfirmMFA(context.Background(), userID, code)
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, 10)
+
+	// Password login now yields a challenge instead of a session token
+	challenge, err := service.LoginUser(context.Background(), &models.LoginRequest{
+		Username: testUsername,
+		Password: testPassword,
+	})
+	assert.ErrorIs(t, err, user.ErrMFARequired)
+	_, err = auth.ParseToken(challenge)
+	assert.Error(t, err, "challenge token must not grant API access")
+
+	// The code used for confirmation cannot be replayed
+	_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: code})
+	assert.ErrorIs(t, err, user.ErrInvalidMFACode)
+
+	now = now.Add(30 * time.Second)
+	code, err = service.TOTP.Code(secret, now)
+	assert.NoError(t, err)
+	token, err := service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: code})
+	assert.NoError(t, err)
+	parsedID, err := auth.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, parsedID)
+
+	// The challenge is spent by the login
+	_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: recoveryCodes[0]})
+	assert.ErrorIs(t, err, user.ErrInvalidChallenge)
+
+	// Recovery codes work exactly once
+	for _, want := range []error{nil, user.ErrInvalidMFACode} {
+		challenge, err = service.LoginUser(context.Background(), &models.LoginRequest{Username: testUsername, Password: testPassword})
+		assert.ErrorIs(t, err, user.ErrMFARequired)
+		_, err = service.VerifyMFA(context.Background(), &models.MFAVerifyRequest{ChallengeToken: challenge, Code: recoveryCodes[0]})
+		assert.Equal(t, want, err)
+	}
+
+	// Disabling spends the code together with the settings
+	assert.ErrorIs(t, service.DisableMFA(context.Background(), userID, recoveryCodes[0]), user.ErrInvalidMFACode)
+	assert.NoError(t, service.DisableMFA(context.Background(), userID, recoveryCodes[1]))
+	var remaining int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ?", userID).Scan(&remaining))
+	assert.Equal(t, 0, remaining)
+	_, err = service.ConfirmMFA(context.Background(), userID, code)
+	assert.ErrorIs(t, err, user.ErrMFANotEnrolled)
+}
+
+// TestAccessTokens verifies creation, verification and revocation of personal access tokens.
+func TestAccessTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := user.NewService(db)
+	userID, _ := loginTestUser(t, service, db)
+
+	_, err := service.CreateAccessToken(context.Background(), userID, &models.CreateAccessTokenRequest{
+		Name:   "ci",
+		Scopes: []string{"admin"},
+	})
+	assert.ErrorIs(t, err, user.ErrInvalidAccessTokenRequest)
+
+	created, err := service.CreateAccessToken(context.Background(), userID, &models.CreateAccessTokenRequest{
+		Name:   "ci",
+		Scopes: []string{auth.ScopeTasksRead, auth.ScopeTasksWrite},
+	})
+	assert.NoError(t, err)
+	assert.True(t, auth.IsAccessToken(created.Token))
+
+	// Only the hash is stored
+	var stored string
+	err = db.QueryRow("SELECT token_hash FROM personal_access_tokens WHERE id = ?", created.ID).Scan(&stored)
+	assert.NoError(t, err)
+	assert.NotEqual(t, created.Token, stored)
+
+	verified, err := service.VerifyAccessToken(context.Background(), created.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, verified.UserID)
+	assert.True(t, verified.HasScope(auth.ScopeTasksWrite))
+	assert.False(t, verified.HasScope(auth.ScopeUserRead))
+
+	tokens, err := service.ListAccessTokens(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+	assert.Empty(t, tokens[0].Token)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	err = service.RevokeAccessToken(context.Background(), userID, created.ID)
+	assert.NoError(t, err)
+	_, err = service.VerifyAccessToken(context.Background(), created.Token)
+	assert.ErrorIs(t, err, user.ErrInvalidAccessToken)
+}
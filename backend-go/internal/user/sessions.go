@@ -0,0 +1,198 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+// ErrSessionNotFound is returned when a session does not exist or belongs to another user.
+var ErrSessionNotFound = errors.New("session_not_found")
+
+// lastSeenInterval limits how often the last-seen time of a session is written.
+const lastSeenInterval = time.Minute
+
+// createSession issues a new JWT for the user and records it as a session.
+func (s *Service) createSession(ctx context.Context, userID int, userAgent, ip string) (string, error) {
+	issued, err := auth.IssueJWT(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	now := time.Now().UTC()
+	err = s.Sessions.Create(ctx, userID, models.Session{
+		ID:         issued.ID,
+		Device:     userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: &now,
+		ExpiresAt:  issued.ExpiresAt.UTC(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Signing in during the grace period keeps the account
+	if err := s.cancelAccountDeletion(ctx, userID); err != nil {
+		return "", err
+	}
+
+	return issued.Token, nil
+}
+
+// ListSessions returns the active (not revoked, not expired) sessions of the user.
+func (s *Service) ListSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	sessions, err := s.Sessions.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := []models.Session{}
+	for _, session := range sessions {
+		if session.ExpiresAt.After(now) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+// RevokeSession revokes a single session owned by the user.
+func (s *Service) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	expiresAt, err := s.Sessions.Revoke(ctx, userID, sessionID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	auth.Revoked.Revoke(sessionID, expiresAt)
+	return nil
+}
+
+// RevokeAllSessions revokes every active session of the user except exceptID (may be empty).
+func (s *Service) RevokeAllSessions(ctx context.Context, userID int, exceptID string) error {
+	revoked, err := s.Sessions.RevokeAll(ctx, userID, exceptID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	for id, expiresAt := range revoked {
+		auth.Revoked.Revoke(id, expiresAt)
+	}
+	return nil
+}
+
+// SessionDenylist is an auth.Denylist over the sessions of a repository, so a
+// session revoked on one replica is refused by all of them. Tokens whose
+// session is unknown, such as those of a deleted account, count as revoked.
+type SessionDenylist struct {
+	Sessions SessionRepository
+}
+
+// NewSessionDenylist creates a denylist over sessions.
+func NewSessionDenylist(sessions SessionRepository) *SessionDenylist {
+	return &SessionDenylist{Sessions: sessions}
+}
+
+// Revoke does nothing: the repository records revocations.
+func (d *SessionDenylist) Revoke(tokenID string, until time.Time) {}
+
+// IsRevoked reports whether the session of the token is no longer active.
+// Errors refuse the token.
+func (d *SessionDenylist) IsRevoked(tokenID string) bool {
+	active, err := d.Sessions.Active(context.Background(), tokenID, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error checking session %s: %v", tokenID, err)
+		return true
+	}
+	return !active
+}
+
+// Touch records the last-seen time and IP of a session, at most once per lastSeenInterval.
+// It implements auth.SessionTracker. Sessions this process wrote recently are
+// skipped without a database round-trip.
+func (s *Service) Touch(ctx context.Context, sessionID, ip string) {
+	now := time.Now().UTC()
+	if _, ok := s.touched.get(sessionID, now); ok {
+		return
+	}
+	s.touched.set(sessionID, struct{}{}, now.Add(lastSeenInterval))
+
+	if err := s.Sessions.Touch(ctx, sessionID, ip, now, now.Add(-lastSeenInterval)); err != nil {
+		log.Printf("Error updating session last seen: %v", err)
+	}
+}
+
+// Logout revokes the session of the token used in the request.
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+	sessionID, ok := auth.SessionIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, problem.SessionNotFound)
+		return
+	}
+
+	if err := h.Service.RevokeSession(r.Context(), userID, sessionID); err != nil && err != ErrSessionNotFound {
+		problem.Write(w, r, http.StatusInternalServerError, problem.LogoutFailed)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "auth.success.logged_out")})
+}
+
+// Sessions lists the active sessions of the user (GET /api/user/sessions),
+// revokes one of them (DELETE /api/user/sessions/{id}) or all of them (DELETE /api/user/sessions).
+func (h *Handler) Sessions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.UserIDFromContext(r.Context())
+	currentID, _ := auth.SessionIDFromContext(r.Context())
+	sessionID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/user/sessions"), "/")
+
+	switch {
+	case r.Method == http.MethodGet && sessionID == "":
+		sessions, err := h.Service.ListSessions(r.Context(), userID)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.SessionsQueryFailed)
+			return
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == currentID
+		}
+		respondWithJSON(w, http.StatusOK, map[string]any{"sessions": sessions})
+
+	case r.Method == http.MethodDelete && sessionID == "":
+		if err := h.Service.RevokeAllSessions(r.Context(), userID, ""); err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.SessionRevokeFailed)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "auth.success.sessions_revoked")})
+
+	case r.Method == http.MethodDelete:
+		err := h.Service.RevokeSession(r.Context(), userID, sessionID)
+		if err == ErrSessionNotFound {
+			problem.Write(w, r, http.StatusNotFound, problem.SessionNotFound)
+			return
+		} else if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.SessionRevokeFailed)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "auth.success.session_revoked")})
+
+	default:
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+	}
+}
@@ -0,0 +1,13 @@
+package user
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// hashToken returns the SHA-256 hex digest used to store high-entropy secrets
+// (recovery codes, tokens) so that a database leak does not expose them.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -4,100 +4,99 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
-	"task-manager/backend-go/db"
 	"task-manager/backend-go/internal/auth"
 	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
 	"task-manager/backend-go/models"
 )
 
-// UserRouter routes requests to the appropriate handler based on HTTP method.
-func UserRouter(w http.ResponseWriter, r *http.Request) {
+// Handler serves the account, authentication and admin endpoints through the
+// service it is constructed with in main.
+type Handler struct {
+	Service *Service
+}
+
+// NewHandler creates the handlers of the user API over service.
+func NewHandler(service *Service) *Handler {
+	return &Handler{Service: service}
+}
+
+// Router routes requests to the appropriate handler based on HTTP method.
+func (h *Handler) Router(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		GetUserHandler(w, r)
+		h.GetUser(w, r)
 	case http.MethodPost:
-		UpdateUserHandler(w, r)
+		h.UpdateUser(w, r)
+	case http.MethodDelete:
+		h.DeleteAccount(w, r)
 	default:
-		http.Error(w, i18n.T("http.error.method_not_allowed"), http.StatusMethodNotAllowed)
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
 	}
 }
 
-// GetUserHandler retrieves the user info if the token is valid.
-func GetUserHandler(w http.ResponseWriter, r *http.Request) {
-	var user models.UserSummary
-
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		respondWithError(w, http.StatusUnauthorized, "auth.error.token_not_provided")
+// GetUser retrieves the user info if the token is valid.
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
 		return
 	}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	userID, err := auth.ParseToken(token)
+	user, err := h.Service.UserSummary(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "auth.error.invalid_token")
+		problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
 		return
 	}
 
-	err = db.DB.QueryRow("SELECT username, email, name, surname, created_at FROM users WHERE id = ?", userID).
-		Scan(&user.Username, &user.Email, &user.Name, &user.Surname, &user.Created)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "user.error.not_found")
-		return
-	}
-
-
 	respondWithJSON(w, http.StatusOK, user)
 }
 
-// Handler groups dependencies for handlers (optional).
-type Handler struct {
-	Service *Service
-}
-
-// UpdateUserHandler updates user's name and surname after validating the token and request data.
-func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+// UpdateUser updates user's name and surname after validating the token and request data.
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	var req models.UpdateUserRequest
 
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "http.error.method_not_allowed")
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
 		return
 	}
 
-	token := r.Header.Get("Authorization")
-	if token == "" || !strings.HasPrefix(token, "Bearer ") {
-		respondWithError(w, http.StatusUnauthorized, "auth.error.token_not_provided")
+	userID, errCode := authenticatedUserID(r)
+	if errCode != "" {
+		problem.Write(w, r, http.StatusUnauthorized, errCode)
 		return
 	}
-	token = strings.TrimPrefix(token, "Bearer ")
 
-	userID, err := auth.ParseToken(token)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "auth.error.invalid_token")
+	if !validate.Decode(w, r, &req) {
 		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithError(w, http.StatusBadRequest, "http.error.invalid_data")
+	if err := h.Service.UpdateUser(userID, req.Name, req.Surname); err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.UpdateFailed)
 		return
 	}
 
-	service := NewService(db.DB)
-	if err := service.UpdateUser(userID, req.Name, req.Surname); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "user.error.update_failed")
-		return
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "user.success.updated")})
+}
+
+// authenticatedUserID returns the user ID set by AuthMiddleware, falling back to
+// parsing the Bearer token. On failure it returns the code of the error.
+func authenticatedUserID(r *http.Request) (int, problem.Code) {
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		return userID, ""
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.T("user.success.updated")})
-}
+	token := r.Header.Get("Authorization")
+	if token == "" || !strings.HasPrefix(token, "Bearer ") {
+		return 0, problem.TokenMissing
+	}
 
-// respondWithError writes a JSON error response with localized message.
-func respondWithError(w http.ResponseWriter, statusCode int, msgKey string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]string{
-		"error": i18n.T(msgKey),
-	})
+	userID, err := auth.ParseToken(strings.TrimPrefix(token, "Bearer "))
+	if err != nil {
+		return 0, problem.InvalidToken
+	}
+	return userID, ""
 }
 
 // respondWithJSON writes a JSON response with data.
@@ -0,0 +1,753 @@
+package user_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/loginguard"
+	"task-manager/backend-go/internal/oidc"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// strongPassword satisfies the default PasswordPolicy.
+const strongPassword = "Mjolnir-Forged-42"
+
+// memoryAPI serves the user API over in-memory repositories, without a database.
+type memoryAPI struct {
+	t       *testing.T
+	service *user.Service
+	handler http.Handler
+}
+
+// newMemoryAPI creates the API with a fresh login guard, so that failures do
+// not carry over between tests.
+func newMemoryAPI(t *testing.T) *memoryAPI {
+	loadTranslations(t)
+	service := user.NewMemoryService()
+
+	// No backoff, so that tests can run into lockouts right away
+	previous := user.LoginGuard
+	user.LoginGuard = user.NewLoginGuard(loginguard.NewMemoryStore(), service)
+	user.LoginGuard.Account.BaseDelay = 0
+	user.LoginGuard.MFA.BaseDelay = 0
+	t.Cleanup(func() { user.LoginGuard = previous })
+
+	previousRevoked := auth.Revoked
+	auth.Revoked = user.NewSessionDenylist(service.Sessions)
+	t.Cleanup(func() { auth.Revoked = previousRevoked })
+
+	previousDir := user.ExportDir
+	user.ExportDir = t.TempDir()
+	t.Cleanup(func() { user.ExportDir = previousDir })
+
+	handler := user.NewHandler(service)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", handler.Register)
+	mux.HandleFunc("/login", handler.Login)
+	mux.HandleFunc("/forgot-password", handler.ForgotPassword)
+	mux.HandleFunc("/reset-password", handler.ResetPassword)
+	mux.HandleFunc("/unlock-account", handler.UnlockAccount)
+	mux.HandleFunc("/verify-email", handler.VerifyEmail)
+	mux.HandleFunc("/verify-email/resend", handler.ResendVerification)
+	mux.HandleFunc("/confirm-email-change", handler.ConfirmEmailChange)
+	mux.HandleFunc("/export/download", handler.ExportDownload)
+	mux.HandleFunc("/auth/logout", auth.AuthMiddleware(handler.Logout))
+	mux.HandleFunc("/auth/mfa/verify", handler.MFAVerify)
+	mux.HandleFunc("/auth/magic-link", handler.MagicLink)
+	mux.HandleFunc("/auth/magic-link/verify", handler.MagicLinkVerify)
+	mux.HandleFunc("/api/user/", handler.Router)
+	mux.HandleFunc("/api/user/preferences", handler.Preferences)
+	mux.HandleFunc("/api/user/sessions", auth.AuthMiddleware(handler.Sessions))
+	mux.HandleFunc("/api/user/sessions/", auth.AuthMiddleware(handler.Sessions))
+	mux.HandleFunc("/api/user/mfa", auth.AuthMiddleware(handler.MFA))
+	mux.HandleFunc("/api/user/mfa/", auth.AuthMiddleware(handler.MFA))
+	mux.HandleFunc("/api/user/password", auth.AuthMiddleware(handler.ChangePassword))
+	mux.HandleFunc("/api/user/email", auth.AuthMiddleware(handler.ChangeEmail))
+	mux.HandleFunc("/api/user/magic-link", auth.AuthMiddleware(handler.MagicLinkSettings))
+	mux.HandleFunc("/api/user/export", auth.AuthMiddleware(handler.Export))
+	mux.HandleFunc("/api/user/export/", auth.AuthMiddleware(handler.Export))
+	mux.HandleFunc("/api/user/tokens", auth.AuthMiddleware(handler.RequireVerifiedEmail(handler.AccessTokens)))
+	mux.HandleFunc("/api/user/tokens/", auth.AuthMiddleware(handler.RequireVerifiedEmail(handler.AccessTokens)))
+	return &memoryAPI{t: t, service: service, handler: mux}
+}
+
+// do sends a request as the owner of token, if any, and decodes the JSON
+// response into out.
+func (a *memoryAPI) do(method, path, token, body string, out any) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	if out != nil {
+		assert.NoError(a.t, json.NewDecoder(rec.Body).Decode(out), rec.Body.String())
+	}
+	return rec.Code
+}
+
+// register creates the test account through the API.
+func (a *memoryAPI) register() {
+	body := `{"name":"` + testName + `","surname":"` + testSurname + `","username":"` + testUsername +
+		`","email":"` + testEmail + `","password":"` + strongPassword + `"}`
+	assert.Equal(a.t, http.StatusCreated, a.do(http.MethodPost, "/register", "", body, nil))
+}
+
+// login signs the test account in and returns the session token.
+func (a *memoryAPI) login() string {
+	var resp map[string]string
+	body := `{"username":"` + testUsername + `","password":"` + strongPassword + `"}`
+	assert.Equal(a.t, http.StatusOK, a.do(http.MethodPost, "/login", "", body, &resp))
+	assert.NotEmpty(a.t, resp["token"])
+	return resp["token"]
+}
+
+// emailToken returns the token in the link of the last email sent with the template.
+func (a *memoryAPI) emailToken(template string) string {
+	emails := a.service.Users.(*user.MemoryUserRepository).Emails()
+	for i := len(emails) - 1; i >= 0; i-- {
+		if emails[i].Template != template {
+			continue
+		}
+		link, err := url.Parse(emails[i].Data["URL"].(string))
+		assert.NoError(a.t, err)
+		return link.Query().Get("token")
+	}
+	a.t.Fatalf("no %s email sent", template)
+	return ""
+}
+
+// verify follows the verification link sent on registration.
+func (a *memoryAPI) verify() {
+	assert.Equal(a.t, http.StatusOK, a.do(http.MethodGet, "/verify-email?token="+a.emailToken("verify_email"), "", "", nil))
+}
+
+// TestHandlerRegister verifies that registration creates the account
+func TestHandlerRegister(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+
+	profile, err := a.service.UserSummary(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, testUsername, profile.Username)
+	assert.Equal(t, testEmail, profile.Email)
+	assert.False(t, profile.EmailVerified)
+}
+
+// TestHandlerRegister_Duplicate verifies that a taken username answers 409
+func TestHandlerRegister_Duplicate(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+
+	var failure problem.Problem
+	body := `{"name":"Loki","surname":"Laufeyson","username":"` + testUsername + `","email":"loki@example.com","password":"` + strongPassword + `"}`
+	assert.Equal(t, http.StatusConflict, a.do(http.MethodPost, "/register", "", body, &failure))
+	assert.Equal(t, problem.UserExists, failure.Code)
+}
+
+// TestHandlerRegister_WeakPassword verifies that PasswordPolicy is enforced
+func TestHandlerRegister_WeakPassword(t *testing.T) {
+	a := newMemoryAPI(t)
+
+	var failure problem.Problem
+	body := `{"name":"` + testName + `","surname":"` + testSurname + `","username":"` + testUsername +
+		`","email":"` + testEmail + `","password":"` + testPassword + `"}`
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodPost, "/register", "", body, &failure))
+	assert.Equal(t, "password", failure.Errors[0].Field)
+
+	_, err := a.service.UserSummary(context.Background(), 1)
+	assert.Error(t, err)
+}
+
+// TestHandlerLogin verifies that a login issues a token for a new session
+func TestHandlerLogin(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	userID, err := auth.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, userID)
+
+	sessions, err := a.service.ListSessions(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+// TestHandlerLogin_WrongPassword verifies that wrong credentials answer 401
+func TestHandlerLogin_WrongPassword(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+
+	var failure problem.Problem
+	body := `{"username":"` + testUsername + `","password":"wrong-password"}`
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/login", "", body, &failure))
+	assert.Equal(t, problem.LoginFailed, failure.Code)
+}
+
+// TestHandlerLogin_UnknownUser verifies that unknown usernames answer like wrong passwords
+func TestHandlerLogin_UnknownUser(t *testing.T) {
+	a := newMemoryAPI(t)
+
+	var failure problem.Problem
+	body := `{"username":"nobody","password":"` + strongPassword + `"}`
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/login", "", body, &failure))
+	assert.Equal(t, problem.LoginFailed, failure.Code)
+}
+
+// TestHandlerSessions_List verifies that the current session is marked
+func TestHandlerSessions_List(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	first := a.login()
+	second := a.login()
+
+	var resp struct {
+		Sessions []models.Session `json:"sessions"`
+	}
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/sessions", second, "", &resp))
+	assert.Len(t, resp.Sessions, 2)
+
+	claims, err := auth.ParseClaims(first)
+	assert.NoError(t, err)
+	for _, session := range resp.Sessions {
+		assert.Equal(t, session.ID != claims.ID, session.Current)
+	}
+}
+
+// TestHandlerSessions_Revoke verifies that a revoked session can no longer be used
+func TestHandlerSessions_Revoke(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	first := a.login()
+	second := a.login()
+
+	claims, err := auth.ParseClaims(first)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, a.do(http.MethodDelete, "/api/user/sessions/"+claims.ID, second, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/user/sessions", first, "", nil))
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/sessions", second, "", nil))
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusNotFound, a.do(http.MethodDelete, "/api/user/sessions/"+claims.ID, second, "", &failure))
+	assert.Equal(t, problem.SessionNotFound, failure.Code)
+}
+
+// TestHandlerSessions_RevokeAll verifies that every session is revoked
+func TestHandlerSessions_RevokeAll(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	first := a.login()
+	second := a.login()
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodDelete, "/api/user/sessions", second, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/user/sessions", first, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/user/sessions", second, "", nil))
+}
+
+// TestHandlerLogout verifies that logging out revokes the session of the token
+func TestHandlerLogout(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/auth/logout", token, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/user/sessions", token, "", nil))
+}
+
+// TestHandlerGetUser verifies that the profile of the token's user is returned
+func TestHandlerGetUser(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	var profile models.UserSummary
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/", token, "", &profile))
+	assert.Equal(t, testUsername, profile.Username)
+	assert.Equal(t, testEmail, profile.Email)
+}
+
+// TestHandlerUpdateUser verifies that the name and surname are updated
+func TestHandlerUpdateUser(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/", token, `{"username":"thorbar","name":"tango","surname":"express"}`, nil))
+
+	var profile models.UserSummary
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/", token, "", &profile))
+	assert.Equal(t, "tango", profile.Name)
+	assert.Equal(t, "express", profile.Surname)
+}
+
+// TestHandlerUpdateUser_Validation verifies that invalid fields answer 400
+func TestHandlerUpdateUser_Validation(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	var failure problem.Problem
+	body := `{"name":"` + strings.Repeat("a", 21) + `","surname":"express"}`
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodPost, "/api/user/", token, body, &failure))
+	assert.Equal(t, problem.ValidationFailed, failure.Code)
+	assert.Equal(t, "name", failure.Errors[0].Field)
+}
+
+// TestHandlerDeleteAccount verifies that deletion is scheduled and signs the user out
+func TestHandlerDeleteAccount(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodDelete, "/api/user/", token, `{"password":"wrong-password"}`, &failure))
+	assert.Equal(t, problem.IncorrectPassword, failure.Code)
+
+	assert.Equal(t, http.StatusAccepted, a.do(http.MethodDelete, "/api/user/", token, `{"password":"`+strongPassword+`"}`, nil))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/user/sessions", token, "", nil))
+}
+
+// TestHandlerPreferences_Defaults verifies the preferences of a user who never set them
+func TestHandlerPreferences_Defaults(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	var prefs models.UserPreferences
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/preferences", token, "", &prefs))
+	assert.Equal(t, user.DefaultPreferences(), prefs)
+}
+
+// TestHandlerPreferences_Update verifies that saved preferences are normalised
+func TestHandlerPreferences_Update(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	var prefs models.UserPreferences
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPut, "/api/user/preferences", token, `{"locale":"es-ES","week_start":"sunday"}`, &prefs))
+	assert.Equal(t, "es", prefs.Locale)
+	assert.Equal(t, user.WeekStartSunday, prefs.WeekStart)
+	assert.Equal(t, "es", a.service.PreferredLocale(context.Background(), 1))
+}
+
+// TestHandlerPreferences_InvalidSort verifies that unknown task sorts answer 400
+func TestHandlerPreferences_InvalidSort(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodPut, "/api/user/preferences", token, `{"task_sort":"random"}`, &failure))
+	assert.Equal(t, "task_sort", failure.Errors[0].Field)
+}
+
+// TestHandlerVerifyEmail verifies the verification link sent on registration
+func TestHandlerVerifyEmail(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.emailToken("verify_email")
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodGet, "/verify-email?token=forged", "", "", &failure))
+	assert.Equal(t, problem.InvalidToken, failure.Code)
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/verify-email?token="+token, "", "", nil))
+	verified, err := a.service.IsEmailVerified(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.True(t, verified)
+
+	// Verified accounts get no new link, and the answer does not tell
+	sent := len(a.service.Users.(*user.MemoryUserRepository).Emails())
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/verify-email/resend", "", `{"email":"`+testEmail+`"}`, nil))
+	assert.Len(t, a.service.Users.(*user.MemoryUserRepository).Emails(), sent)
+}
+
+// TestHandlerMFA verifies enrollment, the second login step and disabling
+func TestHandlerMFA(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	now := time.Unix(1700000000, 0)
+	a.service.TOTP.Now = func() time.Time { return now }
+
+	var enrollment map[string]string
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/mfa/enroll", token, "", &enrollment))
+	code, err := a.service.TOTP.Code(enrollment["secret"], now)
+	assert.NoError(t, err)
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/api/user/mfa/confirm", token, `{"code":"000000"}`, &failure))
+	assert.Equal(t, problem.InvalidMFACode, failure.Code)
+
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/mfa/confirm", token, `{"code":"`+code+`"}`, &confirmed))
+	assert.Len(t, confirmed.RecoveryCodes, 10)
+
+	// The password alone now yields a challenge
+	var challenge map[string]any
+	body := `{"username":"` + testUsername + `","password":"` + strongPassword + `"}`
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/login", "", body, &challenge))
+	assert.Equal(t, true, challenge["mfa_required"])
+	challengeToken, _ := challenge["challenge_token"].(string)
+
+	var session map[string]string
+	body = `{"challenge_token":"` + challengeToken + `","code":"` + confirmed.RecoveryCodes[0] + `"}`
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/auth/mfa/verify", "", body, &session))
+	assert.NotEmpty(t, session["token"])
+
+	// The challenge is spent
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/auth/mfa/verify", "", body, nil))
+
+	now = now.Add(30 * time.Second)
+	code, err = a.service.TOTP.Code(enrollment["secret"], now)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, a.do(http.MethodDelete, "/api/user/mfa", session["token"], `{"code":"`+code+`"}`, nil))
+	a.login()
+}
+
+// TestHandlerMFA_Throttled verifies that confirming and disabling 2FA count
+// wrong codes like the login, and that a replayed code does not disable it
+func TestHandlerMFA_Throttled(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	now := time.Unix(1700000000, 0)
+	a.service.TOTP.Now = func() time.Time { return now }
+
+	var enrollment map[string]string
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/mfa/enroll", token, "", &enrollment))
+	code, err := a.service.TOTP.Code(enrollment["secret"], now)
+	assert.NoError(t, err)
+
+	for i := 0; i < loginguard.DefaultMFAPolicy.MaxFailures; i++ {
+		assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/api/user/mfa/confirm", token, `{"code":"000000"}`, nil))
+	}
+	var failure problem.Problem
+	assert.Equal(t, http.StatusTooManyRequests, a.do(http.MethodPost, "/api/user/mfa/confirm", token, `{"code":"`+code+`"}`, &failure))
+	assert.Equal(t, problem.AccountLocked, failure.Code)
+
+	assert.NoError(t, user.LoginGuard.SuccessMFA(context.Background(), 1))
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/mfa/confirm", token, `{"code":"`+code+`"}`, nil))
+
+	// The confirming code is spent, and counts as a wrong one
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodDelete, "/api/user/mfa", token, `{"code":"`+code+`"}`, nil))
+	for i := 1; i < loginguard.DefaultMFAPolicy.MaxFailures; i++ {
+		assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodDelete, "/api/user/mfa", token, `{"code":"000000"}`, nil))
+	}
+	now = now.Add(30 * time.Second)
+	code, err = a.service.TOTP.Code(enrollment["secret"], now)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, a.do(http.MethodDelete, "/api/user/mfa", token, `{"code":"`+code+`"}`, nil))
+
+	state, err := a.service.MFA.State(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.True(t, state.Enabled)
+}
+
+// TestHandlerAccessTokens verifies creating, using and revoking a personal access token
+func TestHandlerAccessTokens(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	// Unverified accounts cannot create tokens
+	var failure problem.Problem
+	body := `{"name":"ci","scopes":["` + auth.ScopeTasksRead + `"]}`
+	assert.Equal(t, http.StatusForbidden, a.do(http.MethodPost, "/api/user/tokens", token, body, &failure))
+	assert.Equal(t, problem.EmailNotVerified, failure.Code)
+	a.verify()
+
+	var created models.AccessToken
+	assert.Equal(t, http.StatusCreated, a.do(http.MethodPost, "/api/user/tokens", token, body, &created))
+	assert.True(t, auth.IsAccessToken(created.Token))
+
+	verified, err := a.service.VerifyAccessToken(context.Background(), created.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, verified.UserID)
+
+	var list struct {
+		Tokens []models.AccessToken `json:"tokens"`
+	}
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/tokens", token, "", &list))
+	assert.Len(t, list.Tokens, 1)
+	assert.Empty(t, list.Tokens[0].Token)
+
+	path := "/api/user/tokens/" + strconv.Itoa(created.ID)
+	assert.Equal(t, http.StatusOK, a.do(http.MethodDelete, path, token, "", nil))
+	assert.Equal(t, http.StatusNotFound, a.do(http.MethodDelete, path, token, "", &failure))
+	assert.Equal(t, problem.AccessTokenNotFound, failure.Code)
+	_, err = a.service.VerifyAccessToken(context.Background(), created.Token)
+	assert.ErrorIs(t, err, user.ErrInvalidAccessToken)
+}
+
+// TestHandlerMagicLink verifies that an opted-in account signs in with a one-time link
+func TestHandlerMagicLink(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	a.verify()
+	token := a.login()
+
+	var settings models.MagicLinkSettings
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPut, "/api/user/magic-link", token, `{"enabled":true}`, &settings))
+	assert.True(t, settings.Enabled)
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/auth/magic-link", "", `{"email":"`+testEmail+`"}`, nil))
+	link := a.emailToken("magic_link")
+
+	var session map[string]string
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/auth/magic-link/verify?token="+link, "", "", &session))
+	userID, err := auth.ParseToken(session["token"])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, userID)
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/auth/magic-link/verify?token="+link, "", "", &failure))
+	assert.Equal(t, problem.InvalidMagicLink, failure.Code)
+}
+
+// TestHandlerExport verifies that an export is built and downloaded once
+func TestHandlerExport(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	assert.Equal(t, http.StatusMethodNotAllowed, a.do(http.MethodGet, "/api/user/export", token, "", nil))
+
+	var first models.DataExport
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/export", token, "", &first))
+	assert.Equal(t, user.ExportReady, first.Status)
+
+	// Asking again reuses the export with a new link, which replaces the first one
+	var export models.DataExport
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/export", token, "", &export))
+	assert.Equal(t, first.ID, export.ID)
+	assert.NotEqual(t, first.DownloadURL, export.DownloadURL)
+	assert.Equal(t, http.StatusNotFound, a.do(http.MethodGet, first.DownloadURL, "", "", nil))
+
+	var status models.DataExport
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/export/"+strconv.Itoa(export.ID), token, "", &status))
+	assert.Equal(t, user.ExportReady, status.Status)
+
+	req := httptest.NewRequest(http.MethodGet, export.DownloadURL, nil)
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	reader, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, reader.File)
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusNotFound, a.do(http.MethodGet, export.DownloadURL, "", "", &failure))
+	assert.Equal(t, problem.ExportNotFound, failure.Code)
+
+	// Once downloaded, the next request builds a new export
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/export", token, "", &export))
+	assert.NotEqual(t, first.ID, export.ID)
+}
+
+// TestHandlerChangePassword verifies that the new password replaces the old one
+func TestHandlerChangePassword(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	other := a.login()
+	token := a.login()
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/api/user/password", token, `{"current_password":"wrong-password","new_password":"Stormbreaker-Forged-7"}`, &failure))
+	assert.Equal(t, problem.IncorrectPassword, failure.Code)
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/password", token, `{"current_password":"`+strongPassword+`","new_password":"Stormbreaker-Forged-7"}`, nil))
+
+	// Other sessions are signed out, the current one is kept
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/user/sessions", other, "", nil))
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/sessions", token, "", nil))
+
+	body := `{"username":"` + testUsername + `","password":"Stormbreaker-Forged-7"}`
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/login", "", body, nil))
+}
+
+// TestHandlerChangePassword_SpendsResets verifies that reset links sent before
+// a password change stop working
+func TestHandlerChangePassword_SpendsResets(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	assert.NoError(t, a.service.RequestPasswordReset(context.Background(), testEmail, "203.0.113.1"))
+	link := a.emailToken("password_reset")
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/api/user/password", token, `{"current_password":"`+strongPassword+`","new_password":"Stormbreaker-Forged-7"}`, nil))
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/reset-password", "", `{"token":"`+link+`","password":"Gungnir-Thrown-99"}`, &failure))
+	assert.Equal(t, problem.InvalidToken, failure.Code)
+}
+
+// TestHandlerChangePassword_Throttled verifies that wrong current passwords
+// count as failed logins and lock the account
+func TestHandlerChangePassword_Throttled(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	body := `{"current_password":"wrong-password","new_password":"Stormbreaker-Forged-7"}`
+	for i := 0; i < user.LoginGuard.Account.MaxFailures; i++ {
+		assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/api/user/password", token, body, nil))
+	}
+
+	var failure problem.Problem
+	body = `{"current_password":"` + strongPassword + `","new_password":"Stormbreaker-Forged-7"}`
+	assert.Equal(t, http.StatusTooManyRequests, a.do(http.MethodPost, "/api/user/password", token, body, &failure))
+	assert.Equal(t, problem.AccountLocked, failure.Code)
+	assert.Equal(t, http.StatusTooManyRequests, a.do(http.MethodDelete, "/api/user/", token, `{"password":"`+strongPassword+`"}`, &failure))
+}
+
+// TestHandlerChangeEmail verifies that the address changes once the new one is confirmed
+func TestHandlerChangeEmail(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	body := `{"email":"thor@asgard.io","current_password":"` + strongPassword + `"}`
+	assert.Equal(t, http.StatusAccepted, a.do(http.MethodPost, "/api/user/email", token, body, nil))
+
+	var profile models.UserSummary
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/", token, "", &profile))
+	assert.Equal(t, testEmail, profile.Email)
+
+	link := a.emailToken("change_email")
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/confirm-email-change?token="+link, "", "", nil))
+	assert.Equal(t, http.StatusOK, a.do(http.MethodGet, "/api/user/", token, "", &profile))
+	assert.Equal(t, "thor@asgard.io", profile.Email)
+	assert.True(t, profile.EmailVerified)
+
+	// The link stops working once the address has changed
+	var failure problem.Problem
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodGet, "/confirm-email-change?token="+link, "", "", &failure))
+	assert.Equal(t, problem.InvalidToken, failure.Code)
+}
+
+// TestHandlerResetPassword verifies that a reset link sets a new password once
+func TestHandlerResetPassword(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	token := a.login()
+
+	// The handler requests the reset in the background
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/forgot-password", "", `{"email":"nobody@example.com"}`, nil))
+	assert.NoError(t, a.service.RequestPasswordReset(context.Background(), testEmail, "203.0.113.1"))
+	link := a.emailToken("password_reset")
+
+	var failure problem.Problem
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodPost, "/reset-password", "", `{"token":"`+link+`","password":"`+testPassword+`"}`, &failure))
+	assert.Equal(t, "password", failure.Errors[0].Field)
+
+	body := `{"token":"` + link + `","password":"Stormbreaker-Forged-7"}`
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/reset-password", "", body, nil))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodPost, "/reset-password", "", body, &failure))
+	assert.Equal(t, problem.InvalidToken, failure.Code)
+
+	// Sessions issued with the old password are revoked
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, "/api/user/sessions", token, "", nil))
+	login := `{"username":"` + testUsername + `","password":"Stormbreaker-Forged-7"}`
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, "/login", "", login, nil))
+}
+
+// TestHandlerUnlockAccount verifies that the link emailed on lockout clears it
+func TestHandlerUnlockAccount(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+
+	var failure problem.Problem
+	body := `{"username":"` + testUsername + `","password":"wrong-password"}`
+	for i := 0; i < user.LoginGuard.Account.MaxFailures; i++ {
+		a.do(http.MethodPost, "/login", "", body, nil)
+	}
+	login := `{"username":"` + testUsername + `","password":"` + strongPassword + `"}`
+	assert.Equal(t, http.StatusTooManyRequests, a.do(http.MethodPost, "/login", "", login, &failure))
+	assert.Equal(t, problem.AccountLocked, failure.Code)
+
+	// Opening the link only asks for confirmation
+	link := a.emailToken("unlock_account")
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unlock-account?token="+link, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), `<form method="post" action="/unlock-account?token=`+link+`">`)
+	assert.Equal(t, http.StatusTooManyRequests, a.do(http.MethodPost, "/login", "", login, nil))
+
+	// The form gets a page back
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/unlock-account?token="+link, nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	a.handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.NotContains(t, rec.Body.String(), "<form")
+	a.login()
+
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodPost, "/unlock-account?token="+link, "", "", &failure))
+	assert.Equal(t, problem.InvalidToken, failure.Code)
+}
+
+// TestHandlerOIDCLogin verifies linking and provisioning on in-memory repositories
+func TestHandlerOIDCLogin(t *testing.T) {
+	a := newMemoryAPI(t)
+	a.register()
+	ctx := context.Background()
+
+	// The account does not own its address yet
+	claims := &oidc.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "sub-thor"},
+		Email:            testEmail,
+		EmailVerified:    true,
+	}
+	_, err := a.service.LoginWithOIDC(ctx, "https://idp.example.com", claims, "", "")
+	assert.ErrorIs(t, err, user.ErrOIDCAccountUnverified)
+
+	a.verify()
+	token, err := a.service.LoginWithOIDC(ctx, "https://idp.example.com", claims, "", "")
+	assert.NoError(t, err)
+	userID, err := auth.ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, userID)
+
+	// Unknown identities get a passwordless account with a free username
+	token, err = a.service.LoginWithOIDC(ctx, "https://idp.example.com", &oidc.Claims{
+		RegisteredClaims:  jwt.RegisteredClaims{Subject: "sub-loki"},
+		Email:             "loki@example.com",
+		EmailVerified:     true,
+		PreferredUsername: testUsername,
+		GivenName:         "Loki",
+	}, "", "")
+	assert.NoError(t, err)
+	userID, err = auth.ParseToken(token)
+	assert.NoError(t, err)
+	assert.NotEqual(t, 1, userID)
+
+	profile, err := a.service.UserSummary(ctx, userID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, testUsername, profile.Username)
+	assert.Equal(t, "Loki", profile.Name)
+	assert.True(t, profile.EmailVerified)
+}
@@ -0,0 +1,191 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+	"time"
+)
+
+// Policies for accounts whose email address has not been verified yet.
+const (
+	// VerificationOff lets unverified accounts use everything.
+	VerificationOff = "off"
+	// VerificationRestrict lets unverified accounts log in and read, but not modify tasks or create tokens.
+	VerificationRestrict = "restrict"
+	// VerificationBlockLogin refuses logins until the email is verified.
+	VerificationBlockLogin = "block"
+)
+
+const (
+	// purposeVerifyEmail marks the signed token in the verification link.
+	purposeVerifyEmail = "verify_email"
+	// verifyEmailTTL bounds how long the verification link stays valid.
+	verifyEmailTTL = 48 * time.Hour
+	// verificationResendInterval is the minimum time between two verification emails.
+	verificationResendInterval = 2 * time.Minute
+)
+
+// EmailVerificationPolicy applies to unverified accounts; set from configuration in main.
+var EmailVerificationPolicy = VerificationRestrict
+
+var (
+	// ErrEmailNotVerified is returned by LoginUser when the policy blocks unverified accounts.
+	ErrEmailNotVerified = errors.New("email_not_verified")
+	// ErrEmailAlreadyVerified is returned when asking to verify an address twice.
+	ErrEmailAlreadyVerified = errors.New("email_already_verified")
+	// ErrVerificationThrottled is returned when a verification email was sent too recently.
+	ErrVerificationThrottled = errors.New("verification_throttled")
+)
+
+// SendVerificationEmail emails the user a signed link confirming their address.
+// Emails to the same account are throttled.
+func (s *Service) SendVerificationEmail(ctx context.Context, userID int) error {
+	verification, err := s.Users.Verification(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if verification.Verified {
+		return ErrEmailAlreadyVerified
+	}
+
+	now := time.Now().UTC()
+	if !verification.SentAt.IsZero() && now.Sub(verification.SentAt) < verificationResendInterval {
+		return ErrVerificationThrottled
+	}
+
+	token, err := auth.GeneratePurposeToken(purposeVerifyEmail, map[string]any{
+		"user_id": userID,
+		"email":   verification.Email,
+	}, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.Users.MarkVerificationSent(ctx, userID, now, Email{
+		Key:      "verify_email:" + hashToken(token),
+		To:       verification.Email,
+		Locale:   s.recipientLocale(ctx, userID),
+		Template: "verify_email",
+		Data:     map[string]any{"URL": publicURL("/verify-email?token=" + token)},
+	})
+}
+
+// VerifyEmail marks the address in a verification token as verified. The
+// token only applies while the account still has that address.
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := auth.ParsePurposeToken(purposeVerifyEmail, token)
+	if err != nil {
+		return ErrInvalidChallenge
+	}
+	userID, _ := claims["user_id"].(float64)
+	email, _ := claims["email"].(string)
+
+	verified, err := s.Users.VerifyEmail(ctx, int(userID), email, time.Now().UTC())
+	if err != nil || verified {
+		return err
+	}
+
+	// Nothing updated: either already verified or the address has changed since
+	user, err := s.Users.Summary(ctx, int(userID))
+	if err == sql.ErrNoRows || (err == nil && user.Email != email) {
+		return ErrInvalidChallenge
+	} else if err != nil {
+		return err
+	}
+	return ErrEmailAlreadyVerified
+}
+
+// ResendVerificationEmail sends a new verification link to the account with
+// the email, sql.ErrNoRows when there is none.
+func (s *Service) ResendVerificationEmail(ctx context.Context, email string) error {
+	userID, err := s.Users.FindByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return err
+	}
+	return s.SendVerificationEmail(ctx, userID)
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address.
+func (s *Service) IsEmailVerified(ctx context.Context, userID int) (bool, error) {
+	user, err := s.Users.Summary(ctx, userID)
+	return user.EmailVerified, err
+}
+
+// RequireVerifiedEmail refuses changes from unverified accounts when the policy
+// is VerificationRestrict. It must run after AuthMiddleware; reads are allowed.
+func (h *Handler) RequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if EmailVerificationPolicy != VerificationRestrict || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		userID, ok := auth.UserIDFromContext(r.Context())
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		verified, err := h.Service.IsEmailVerified(r.Context(), userID)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		if !verified {
+			problem.Write(w, r, http.StatusForbidden, problem.EmailNotVerified)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// VerifyEmail confirms the address from the link sent by email.
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	err := h.Service.VerifyEmail(r.Context(), r.URL.Query().Get("token"))
+	switch err {
+	case nil:
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "email.verified")})
+	case ErrEmailAlreadyVerified:
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "email.already_verified")})
+	case ErrInvalidChallenge:
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidToken)
+	default:
+		log.Printf("Error verifying email: %v", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.EmailVerifyFailed)
+	}
+}
+
+// ResendVerification sends a new verification link to the given email.
+// It answers the same way whether or not the address belongs to an account.
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+		return
+	}
+
+	var req models.UserRequest
+	if !validate.Decode(w, r, &req) {
+		return
+	}
+
+	err := h.Service.ResendVerificationEmail(r.Context(), req.Email)
+	if err != nil && err != sql.ErrNoRows && err != ErrEmailAlreadyVerified && err != ErrVerificationThrottled {
+		log.Printf("Error resending verification email: %v", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.Translate(r.Context(), "email.verification_sent")})
+}
@@ -0,0 +1,84 @@
+package user_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"task-manager/backend-go/internal/auth"
+	"task-manager/backend-go/internal/user"
+	"task-manager/backend-go/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// verificationToken builds the token that the verification email would contain.
+func verificationToken(t *testing.T, userID int, email string) string {
+	token, err := auth.GeneratePurposeToken("verify_email", map[string]any{"user_id": userID, "email": email}, time.Hour)
+	assert.NoError(t, err)
+	return token
+}
+
+// TestVerifyEmail_BlockPolicy verifies that unverified accounts cannot log in under the block policy
+func TestVerifyEmail_BlockPolicy(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+
+	user.EmailVerificationPolicy = user.VerificationBlockLogin
+	defer func() { user.EmailVerificationPolicy = user.VerificationRestrict }()
+
+	_, err := service.RegisterUser(context.Background(), &models.RegisterRequest{
+		Name: testName, Surname: testSurname, Username: testUsername, Email: testEmail, Password: testPassword,
+	})
+	assert.NoError(t, err)
+	var userID int
+	assert.NoError(t, testDB.QueryRow("SELECT id FROM users WHERE username = ?", testUsername).Scan(&userID))
+
+	login := &models.LoginRequest{Username: testUsername, Password: testPassword}
+	_, err = service.LoginUser(context.Background(), login)
+	assert.Equal(t, user.ErrEmailNotVerified, err)
+
+	// Resending is throttled
+	assert.NoError(t, service.SendVerificationEmail(context.Background(), userID))
+	assert.Equal(t, user.ErrVerificationThrottled, service.SendVerificationEmail(context.Background(), userID))
+
+	// A link for another address does not verify the account
+	assert.Equal(t, user.ErrInvalidChallenge, service.VerifyEmail(context.Background(), verificationToken(t, userID, "old@example.com")))
+	assert.Equal(t, user.ErrInvalidChallenge, service.VerifyEmail(context.Background(), "garbage"))
+
+	token := verificationToken(t, userID, testEmail)
+	assert.NoError(t, service.VerifyEmail(context.Background(), token))
+	assert.Equal(t, user.ErrEmailAlreadyVerified, service.VerifyEmail(context.Background(), token))
+	assert.Equal(t, user.ErrEmailAlreadyVerified, service.SendVerificationEmail(context.Background(), userID))
+
+	_, err = service.LoginUser(context.Background(), login)
+	assert.NoError(t, err)
+}
+
+// TestRequireVerifiedEmail_RestrictPolicy verifies that unverified accounts are read-only
+func TestRequireVerifiedEmail_RestrictPolicy(t *testing.T) {
+	testDB := setupTestDB(t)
+	defer testDB.Close()
+	service := user.NewService(testDB)
+	userID, token := loginTestUser(t, service, testDB)
+
+	handler := auth.AuthMiddleware(user.NewHandler(service).RequireVerifiedEmail(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(method string) int {
+		req := httptest.NewRequest(method, "/api/tasks/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost))
+
+	assert.NoError(t, service.VerifyEmail(context.Background(), verificationToken(t, userID, testEmail)))
+	assert.Equal(t, http.StatusOK, do(http.MethodPost))
+}
@@ -0,0 +1,210 @@
+// Package validate decodes JSON request bodies and checks them against the
+// `validate` tags of the target struct, answering invalid requests with a
+// problem that lists a localized error per field.
+//
+// A tag is a comma-separated list of rules:
+//
+//	required      the field is not empty (blank strings and empty lists count as empty)
+//	min=N, max=N  length in characters of a string, or bounds of a number
+//	email         a bare email address such as "ada@example.com"
+//	oneof=a b c   one of the values separated by spaces
+//	date          a date written as YYYY-MM-DD
+//
+// Rules other than required are not checked on empty fields.
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+)
+
+// MaxBodyBytes bounds the size of request bodies; set from configuration in main.
+var MaxBodyBytes int64 = 1 << 20
+
+// Violation is a rule broken by a field of the request. Field is the JSON
+// name, Rule names the translation "validation.<rule>" and Params fill it in.
+type Violation struct {
+	Field  string
+	Rule   string
+	Params i18n.Params
+}
+
+// Decode reads the JSON body of the request into v, a pointer to a struct,
+// and validates it. Unknown fields, values of the wrong type, bodies larger
+// than MaxBodyBytes and broken rules are answered with a problem, in which
+// case Decode returns false and the handler must stop.
+func Decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		// A second value after the object
+		err = errors.New("trailing data")
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		var wrongType *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &tooLarge):
+			problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.PayloadTooLarge)
+		case errors.As(err, &wrongType) && wrongType.Field != "":
+			Write(w, r, []Violation{{Field: wrongType.Field, Rule: "type"}})
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+			Write(w, r, []Violation{{Field: field, Rule: "unknown_field"}})
+		default:
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidRequest)
+		}
+		return false
+	}
+
+	if violations := Struct(v); len(violations) > 0 {
+		Write(w, r, violations)
+		return false
+	}
+	return true
+}
+
+// Write answers the request with a validation problem listing the violations,
+// translated into the locale of the request.
+func Write(w http.ResponseWriter, r *http.Request, violations []Violation) {
+	p := problem.New(r, http.StatusBadRequest, problem.ValidationFailed)
+	for _, v := range violations {
+		p.Errors = append(p.Errors, problem.FieldError{
+			Field:  v.Field,
+			Code:   v.Rule,
+			Detail: i18n.TranslateWith(r.Context(), "validation."+v.Rule, v.Params),
+		})
+	}
+	p.Write(w)
+}
+
+// Struct checks the fields of v, a struct or a pointer to one, against their
+// `validate` tags. It panics on a malformed tag, which is a programming error.
+func Struct(v any) []Violation {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+
+	var violations []Violation
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || tag == "" {
+			continue
+		}
+		if violation, ok := check(value.Field(i), tag); !ok {
+			violation.Field = jsonName(field)
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// check applies the rules of tag to a field and returns the first one broken.
+func check(field reflect.Value, tag string) (Violation, bool) {
+	for field.Kind() == reflect.Pointer && !field.IsNil() {
+		field = field.Elem()
+	}
+	rules := strings.Split(tag, ",")
+	if isEmpty(field) {
+		for _, rule := range rules {
+			if rule == "required" {
+				return Violation{Rule: "required"}, false
+			}
+		}
+		return Violation{}, true
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+		case "min", "max":
+			if violation, ok := checkBound(field, name, param); !ok {
+				return violation, false
+			}
+		case "email":
+			if address, err := mail.ParseAddress(field.String()); err != nil || address.Address != field.String() {
+				return Violation{Rule: "email"}, false
+			}
+		case "oneof":
+			values := strings.Fields(param)
+			found := false
+			for _, allowed := range values {
+				found = found || field.String() == allowed
+			}
+			if !found {
+				return Violation{Rule: "oneof", Params: i18n.Params{"values": strings.Join(values, ", ")}}, false
+			}
+		case "date":
+			if _, err := time.Parse(time.DateOnly, field.String()); err != nil {
+				return Violation{Rule: "date"}, false
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q", rule))
+		}
+	}
+	return Violation{}, true
+}
+
+// checkBound checks the min or max rule: the length in characters of a
+// string, reported as min_length or max_length, or the value of a number.
+func checkBound(field reflect.Value, name, param string) (Violation, bool) {
+	limit, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("validate: %s=%q is not a number", name, param))
+	}
+
+	var n int64
+	rule := name
+	switch field.Kind() {
+	case reflect.String:
+		n = int64(utf8.RuneCountInString(field.String()))
+		rule += "_length"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = field.Int()
+	default:
+		panic(fmt.Sprintf("validate: %s does not apply to %s", name, field.Kind()))
+	}
+
+	if (name == "min" && n < int64(limit)) || (name == "max" && n > int64(limit)) {
+		return Violation{Rule: rule, Params: i18n.Params{"count": limit}}, false
+	}
+	return Violation{}, true
+}
+
+// isEmpty reports whether the field is nil, zero, blank or an empty list.
+func isEmpty(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(field.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return field.Len() == 0
+	default:
+		return field.IsZero()
+	}
+}
+
+// jsonName returns the name of the field in the JSON body.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
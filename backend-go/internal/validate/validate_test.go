@@ -0,0 +1,150 @@
+package validate_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"task-manager/backend-go/internal/i18n"
+	"task-manager/backend-go/internal/problem"
+	"task-manager/backend-go/internal/validate"
+	"task-manager/backend-go/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// taskRequest exercises the rules not used by the register request.
+type taskRequest struct {
+	Title   string   `json:"title" validate:"required,min=3"`
+	Status  string   `json:"status" validate:"oneof=pending completed"`
+	DueDate *string  `json:"due_date" validate:"date"`
+	Hour    int      `json:"hour" validate:"min=0,max=23"`
+	Tags    []string `json:"tags" validate:"required"`
+	Notes   string   `json:"notes"`
+}
+
+// TestStruct verifies each rule and that only required applies to empty fields
+func TestStruct(t *testing.T) {
+	valid := models.RegisterRequest{Name: "Ada", Surname: "Lovelace", Username: "ada", Email: "ada@example.com", Password: "x"}
+	assert.Empty(t, validate.Struct(valid))
+	assert.Empty(t, validate.Struct(&valid))
+
+	invalid := models.RegisterRequest{
+		Name:     strings.Repeat("ñ", 21),
+		Surname:  "  ",
+		Username: "ada",
+		Email:    "Ada <ada@example.com>",
+	}
+	assert.Equal(t, []validate.Violation{
+		{Field: "name", Rule: "max_length", Params: i18n.Params{"count": 20}},
+		{Field: "surname", Rule: "required"},
+		{Field: "email", Rule: "email"},
+		{Field: "password", Rule: "required"},
+	}, validate.Struct(invalid))
+
+	// Twenty multibyte characters fit in VARCHAR(20)
+	invalid.Name = strings.Repeat("ñ", 20)
+	assert.NotContains(t, validate.Struct(invalid), validate.Violation{Field: "name", Rule: "max_length", Params: i18n.Params{"count": 20}})
+
+	date := "2026-02-30"
+	assert.Equal(t, []validate.Violation{
+		{Field: "title", Rule: "min_length", Params: i18n.Params{"count": 3}},
+		{Field: "status", Rule: "oneof", Params: i18n.Params{"values": "pending, completed"}},
+		{Field: "due_date", Rule: "date"},
+		{Field: "hour", Rule: "max", Params: i18n.Params{"count": 23}},
+		{Field: "tags", Rule: "required"},
+	}, validate.Struct(taskRequest{Title: "ab", Status: "done", DueDate: &date, Hour: 24}))
+
+	date = "2026-02-28"
+	assert.Empty(t, validate.Struct(taskRequest{Title: "abc", Status: "completed", DueDate: &date, Hour: 0, Tags: []string{"a"}}))
+	assert.Empty(t, validate.Struct(taskRequest{Title: "abc", Tags: []string{"a"}}))
+	assert.Equal(t, []validate.Violation{{Field: "hour", Rule: "min", Params: i18n.Params{"count": 0}}},
+		validate.Struct(taskRequest{Title: "abc", Tags: []string{"a"}, Hour: -1}))
+
+	assert.Panics(t, func() {
+		validate.Struct(struct {
+			A string `validate:"uppercase"`
+		}{A: "a"})
+	})
+}
+
+// decode runs Decode on body with the locale middleware and returns the response.
+func decode(body string, v any) (*httptest.ResponseRecorder, bool) {
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	req.Header.Set("Accept-Language", "es")
+	rec := httptest.NewRecorder()
+	var ok bool
+	i18n.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok = validate.Decode(w, r, v)
+	})).ServeHTTP(rec, req)
+	return rec, ok
+}
+
+// TestDecode verifies the problems written for invalid bodies
+func TestDecode(t *testing.T) {
+	previous, previousMessages, previousLocale := i18n.Default, i18n.Messages, i18n.DefaultLocale
+	assert.NoError(t, i18n.LoadMessages("en"))
+	defer func() { i18n.Default, i18n.Messages, i18n.DefaultLocale = previous, previousMessages, previousLocale }()
+
+	var req models.RegisterRequest
+	rec, ok := decode(`{"name":"Ada","surname":"Lovelace","username":"ada","email":"ada@example.com","password":"x"}`, &req)
+	assert.True(t, ok)
+	assert.Equal(t, "ada@example.com", req.Email)
+	assert.Equal(t, 200, rec.Code)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   problem.Code
+		errors []problem.FieldError
+	}{
+		{
+			name:   "broken rules",
+			body:   `{"name":"Ada","surname":"Lovelace","username":"ada","email":"ada.lovelace@analytical-engine.org","password":""}`,
+			status: http.StatusBadRequest,
+			code:   problem.ValidationFailed,
+			errors: []problem.FieldError{
+				{Field: "email", Code: "max_length", Detail: "Debe tener como máximo 25 caracteres"},
+				{Field: "password", Code: "required", Detail: i18n.Lookup("es", "validation.required")},
+			},
+		},
+		{
+			name:   "unknown field",
+			body:   `{"name":"Ada","admin":true}`,
+			status: http.StatusBadRequest,
+			code:   problem.ValidationFailed,
+			errors: []problem.FieldError{{Field: "admin", Code: "unknown_field", Detail: i18n.Lookup("es", "validation.unknown_field")}},
+		},
+		{
+			name:   "wrong type",
+			body:   `{"name":42}`,
+			status: http.StatusBadRequest,
+			code:   problem.ValidationFailed,
+			errors: []problem.FieldError{{Field: "name", Code: "type", Detail: i18n.Lookup("es", "validation.type")}},
+		},
+		{name: "malformed", body: `{"name":`, status: http.StatusBadRequest, code: problem.InvalidRequest},
+		{name: "trailing data", body: `{"name":"Ada"} {}`, status: http.StatusBadRequest, code: problem.InvalidRequest},
+		{
+			name:   "too large",
+			body:   `{"name":"` + strings.Repeat("a", int(validate.MaxBodyBytes)) + `"}`,
+			status: http.StatusRequestEntityTooLarge,
+			code:   problem.PayloadTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, ok := decode(tt.body, &models.RegisterRequest{})
+			assert.False(t, ok)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+			var body problem.Problem
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			assert.Equal(t, tt.code, body.Code)
+			assert.Equal(t, tt.errors, body.Errors)
+		})
+	}
+}
@@ -4,17 +4,22 @@ import "time"
 
 // LoginRequest represents the payload for user login.
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+
+	// Client metadata recorded with the session, filled in by the handler.
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
 }
 
 // RegisterRequest represents the payload for user registration.
+// The lengths match the columns of the users table.
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Surname  string `json:"surname"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=20"`
+	Surname  string `json:"surname" validate:"required,max=50"`
+	Username string `json:"username" validate:"required,max=50"`
+	Email    string `json:"email" validate:"required,max=25,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 // Task represents a user task with metadata.
@@ -26,12 +31,14 @@ type Task struct {
 	Description string    `json:"description"`
 	Status      string    `json:"status"`
 	CreatedAt   time.Time `json:"created_at"`
+	// DueDate is the day the task is due, as "2006-01-02", or nil.
+	DueDate *string `json:"due_date,omitempty"`
 }
 
 // UserRequest represents user data submitted from the frontend for update.
 type UserRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	Username string `json:"username" validate:"max=50"`
+	Email    string `json:"email" validate:"required,max=25,email"`
 }
 
 // UserSummary represents the data returned from API after user login.
@@ -41,11 +48,116 @@ type UserSummary struct {
 	Name     string `json:"name"`
 	Surname  string `json:"surname"`
 	Created  string `json:"created_at"`
+	// EmailVerified tells whether the email address has been confirmed.
+	EmailVerified bool `json:"email_verified"`
 }
 
 // UpdateUserRequest represents the payload to update user profile data.
 type UpdateUserRequest struct {
-	Username string `json:"username"`
-	Name     string `json:"name"`
-	Surname  string `json:"surname"`
+	Username string `json:"username" validate:"max=50"`
+	Name     string `json:"name" validate:"required,max=20"`
+	Surname  string `json:"surname" validate:"required,max=50"`
+}
+
+// Session represents an active login session (one issued JWT) of a user.
+type Session struct {
+	ID         string     `json:"id"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+// MFACodeRequest carries a TOTP or recovery code for 2FA confirmation or removal.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFAVerifyRequest represents the second step of a two-factor login.
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
+
+// AccessToken represents a personal access token as listed to its owner.
+// The token value itself is only returned once, on creation.
+type AccessToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+// CreateAccessTokenRequest represents the payload to create a personal access token.
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"min=0,max=365"`
+}
+
+// ChangePasswordRequest represents the payload to change the password of the logged-in user.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// ChangeEmailRequest represents the payload to change the email of the logged-in user.
+type ChangeEmailRequest struct {
+	Email           string `json:"email" validate:"required,max=25,email"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+// DeleteAccountRequest represents the payload confirming an account deletion.
+// Accounts without a password leave it empty and confirm by signing in again.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// MagicLinkVerifyRequest represents the payload redeeming a magic login link.
+type MagicLinkVerifyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// MagicLinkSettings represents whether the user has opted in to magic link login.
+type MagicLinkSettings struct {
+	Enabled bool `json:"enabled"`
+}
+
+// DigestSettings represents how often the user receives the task digest email:
+// Frequency is "off", "daily" or "weekly", sent at Hour in the IANA TimeZone,
+// or in the time zone of the user's preferences when TimeZone is empty.
+type DigestSettings struct {
+	Frequency string `json:"frequency" validate:"required,oneof=off daily weekly"`
+	Hour      int    `json:"hour" validate:"min=0,max=23"`
+	TimeZone  string `json:"time_zone" validate:"max=64"`
+}
+
+// UserPreferences represents how the user wants content presented: Locale is
+// a language such as "ca" ("" follows the browser), TimeZone an IANA zone,
+// WeekStart "monday" or "sunday", DateFormat a pattern such as "DD/MM/YYYY"
+// and TaskSort the default order of the task list.
+type UserPreferences struct {
+	Locale     string `json:"locale" validate:"max=16"`
+	TimeZone   string `json:"time_zone" validate:"required,max=64"`
+	WeekStart  string `json:"week_start" validate:"required,oneof=monday sunday"`
+	DateFormat string `json:"date_format" validate:"required,oneof=YYYY-MM-DD DD/MM/YYYY MM/DD/YYYY DD.MM.YYYY"`
+	TaskSort   string `json:"task_sort" validate:"required,oneof=created_asc created_desc due_date title status"`
+}
+
+// DataExport represents a GDPR data export of a user.
+type DataExport struct {
+	ID        int       `json:"id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// DownloadURL is the one-time link, only returned when the export is created.
+	DownloadURL string `json:"download_url,omitempty"`
 }
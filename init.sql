@@ -1,27 +1,3 @@
+-- The server creates its tables at startup from backend-go/db/schema/mysql.sql
+-- and adds the columns of newer versions to existing tables.
 CREATE DATABASE IF NOT EXISTS task_manager;
-USE task_manager;
-
-CREATE TABLE IF NOT EXISTS users (
-  id INT PRIMARY KEY AUTO_INCREMENT,
-  username VARCHAR(50) NOT NULL,
-  password VARCHAR(60),
-  name VARCHAR(20) NOT NULL,
-  surname VARCHAR(50) NOT NULL,
-  email VARCHAR(25) NOT NULL,
-  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-  password_reset_token TEXT,
-  password_reset_expiration DATETIME,
-  INDEX idx_username (username),
-  INDEX idx_email (email)
-);
-
-CREATE TABLE IF NOT EXISTS tasks (
-  id INT PRIMARY KEY AUTO_INCREMENT,
-  user_id INT NOT NULL,
-  title VARCHAR(255) NOT NULL,
-  description TEXT,
-  status ENUM('pending', 'completed') DEFAULT 'pending',
-  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-  INDEX idx_user_id (user_id),
-  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-);